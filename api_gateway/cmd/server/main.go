@@ -10,6 +10,7 @@ import (
 	"syscall"
 
 	appcfg "github.com/Oniqq60/task_system_control/api_gateway/internal/config"
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/events"
 	"github.com/Oniqq60/task_system_control/api_gateway/internal/middleware"
 	"github.com/Oniqq60/task_system_control/api_gateway/internal/routers"
 	"github.com/Oniqq60/task_system_control/api_gateway/internal/services"
@@ -53,6 +54,27 @@ func run() error {
 	}
 	defer documentSvc.Close()
 
+	notificationSvc, err := services.NewNotificationService(cfg.NotificationGRPCAddr)
+	if err != nil {
+		return err
+	}
+	defer notificationSvc.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	hub := events.NewHub(cfg.EventsBuffer)
+	feed, err := events.NewFeed(hub, events.FeedConfig{
+		Brokers:           cfg.KafkaBrokers,
+		TaskTopic:         cfg.KafkaTaskTopic,
+		NotificationTopic: cfg.KafkaNotificationTopic,
+		Managers:          authSvc,
+		ManagerCacheTTL:   cfg.EventsManagerCacheTTL,
+	}, logger)
+	if err != nil {
+		return err
+	}
+
 	rateLimiter := middleware.NewRateLimiter(cfg.RateLimitRequests, cfg.RateLimitWindow)
 	cors := middleware.NewCORS(middleware.CORSOptions{
 		AllowedOrigins:   cfg.AllowedCORSOrigins,
@@ -63,6 +85,9 @@ func run() error {
 		Auth:                 authSvc,
 		Task:                 taskSvc,
 		Document:             documentSvc,
+		Notification:         notificationSvc,
+		Events:               hub,
+		EventsHeartbeat:      cfg.EventsHeartbeat,
 		JWTVerifier:          jwtVerifier,
 		Middleware:           []func(http.Handler) http.Handler{rateLimiter.Middleware, cors},
 		MaxDocumentBodyBytes: cfg.ForwardResponseLimit,
@@ -78,9 +103,13 @@ func run() error {
 		WriteTimeout: cfg.WriteTimeout,
 		IdleTimeout:  cfg.IdleTimeout,
 	}
+	server.RegisterOnShutdown(hub.Close)
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	go func() {
+		if err := feed.Run(ctx); err != nil {
+			logger.Printf("events feed stopped: %v", err)
+		}
+	}()
 
 	errCh := make(chan error, 1)
 	go func() {
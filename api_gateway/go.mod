@@ -5,14 +5,18 @@ go 1.23.4
 require (
 	github.com/Oniqq60/task_system_control/gen/proto/auth v0.0.0
 	github.com/Oniqq60/task_system_control/gen/proto/document v0.0.0
+	github.com/Oniqq60/task_system_control/gen/proto/notification v0.0.0
 	github.com/Oniqq60/task_system_control/gen/proto/task v0.0.0
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/joho/godotenv v1.5.1
+	github.com/segmentio/kafka-go v0.4.49
 	google.golang.org/grpc v1.64.0
 )
 
 require (
 	github.com/google/go-cmp v0.7.0 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	golang.org/x/net v0.43.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/text v0.28.0 // indirect
@@ -25,3 +29,5 @@ replace github.com/Oniqq60/task_system_control/gen/proto/auth => ../gen/proto/au
 replace github.com/Oniqq60/task_system_control/gen/proto/document => ../gen/proto/document
 
 replace github.com/Oniqq60/task_system_control/gen/proto/task => ../gen/proto/task
+
+replace github.com/Oniqq60/task_system_control/gen/proto/notification => ../gen/proto/notification
@@ -15,6 +15,7 @@ type Config struct {
 	AuthGRPCAddr         string
 	TaskGRPCAddr         string
 	DocumentGRPCAddr     string
+	NotificationGRPCAddr string
 	JWTSecret            string
 	RateLimitRequests    int
 	RateLimitWindow      time.Duration
@@ -24,6 +25,15 @@ type Config struct {
 	WriteTimeout         time.Duration
 	IdleTimeout          time.Duration
 	ForwardResponseLimit int64
+
+	// Поток событий GET /events
+	KafkaBrokers           []string
+	KafkaTaskTopic         string
+	KafkaNotificationTopic string
+	// EventsManagerCacheTTL - сколько помнить менеджера исполнителя задачи
+	EventsManagerCacheTTL time.Duration
+	EventsHeartbeat       time.Duration
+	EventsBuffer          int
 }
 
 func Load() (Config, error) {
@@ -34,6 +44,7 @@ func Load() (Config, error) {
 		AuthGRPCAddr:         getEnv("AUTH_GRPC_ADDR", "auth:9090"),
 		TaskGRPCAddr:         getEnv("TASK_GRPC_ADDR", "task:9091"),
 		DocumentGRPCAddr:     getEnv("DOCUMENT_GRPC_ADDR", "document:9093"),
+		NotificationGRPCAddr: getEnv("NOTIFICATION_GRPC_ADDR", "notification:9095"),
 		JWTSecret:            os.Getenv("JWT_SECRET"),
 		RateLimitRequests:    getEnvInt("RATE_LIMIT_REQUESTS", 60),
 		RateLimitWindow:      getEnvDuration("RATE_LIMIT_WINDOW", time.Minute),
@@ -43,6 +54,16 @@ func Load() (Config, error) {
 		WriteTimeout:         getEnvDuration("WRITE_TIMEOUT", 15*time.Second),
 		IdleTimeout:          getEnvDuration("IDLE_TIMEOUT", 60*time.Second),
 		ForwardResponseLimit: getEnvInt64("FORWARD_RESPONSE_LIMIT", 10*1024*1024),
+
+		KafkaBrokers:           parseCSVEnv("KAFKA_BROKERS"),
+		KafkaTaskTopic:         getEnv("KAFKA_TASK_TOPIC", "task-events"),
+		KafkaNotificationTopic: getEnv("KAFKA_NOTIFICATION_TOPIC", "notification-events"),
+		EventsManagerCacheTTL:  getEnvDuration("EVENTS_MANAGER_CACHE_TTL", time.Minute),
+		EventsHeartbeat:        getEnvDuration("EVENTS_HEARTBEAT", 25*time.Second),
+		EventsBuffer:           getEnvInt("EVENTS_BUFFER", 64),
+	}
+	if len(cfg.KafkaBrokers) == 0 {
+		cfg.KafkaBrokers = []string{"kafka:9092"}
 	}
 
 	if cfg.JWTSecret == "" {
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+const RoleAdmin = "admin"
+
+// Event - событие для подключённых клиентов
+type Event struct {
+	Type string
+	Data json.RawMessage
+}
+
+// Audience - кому доставить событие: перечисленным пользователям и, если Admins, всем admin
+type Audience struct {
+	UserIDs []string
+	Admins  bool
+}
+
+func (a Audience) includes(userID, role string) bool {
+	if a.Admins && role == RoleAdmin {
+		return true
+	}
+	for _, id := range a.UserIDs {
+		if id != "" && id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription - подключение клиента. Events закрывается при отписке или
+// если клиент не успевает читать события.
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+	userID string
+	role   string
+}
+
+// Hub раздаёт события подключениям этого экземпляра gateway
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	buffer int
+}
+
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	return &Hub{
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+func (h *Hub) Subscribe(userID, role string) *Subscription {
+	events := make(chan Event, h.buffer)
+	sub := &Subscription{
+		Events: events,
+		events: events,
+		userID: userID,
+		role:   strings.ToLower(role),
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Publish отправляет событие подписчикам из audience. Подписчик с заполненным буфером
+// отключается: клиент переподключится и перечитает состояние, а не получит поток с пропусками.
+func (h *Hub) Publish(event Event, audience Audience) {
+	var slow []*Subscription
+
+	h.mu.RLock()
+	for sub := range h.subs {
+		if !audience.includes(sub.userID, sub.role) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range slow {
+		h.Unsubscribe(sub)
+	}
+}
+
+// Close отключает всех подписчиков; вызывается при остановке сервера,
+// иначе открытые потоки не дадут завершить http.Server.Shutdown
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Connections возвращает количество подключений
+func (h *Hub) Connections() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
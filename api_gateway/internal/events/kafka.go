@@ -0,0 +1,238 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// taskEvent - поля события задачи (task/internal/task/kafka.go), нужные для выбора получателей
+type taskEvent struct {
+	Type      string `json:"type"`
+	UserID    string `json:"userId"`
+	CreatedBy string `json:"createdBy"`
+}
+
+// notificationEvent - поля уведомления (notification/internal/notification/notifier.go), нужные для выбора получателя
+type notificationEvent struct {
+	RecipientID string `json:"recipientId"`
+}
+
+const (
+	defaultTaskEventType  = "task.status_changed"
+	notificationEventType = "notification.created"
+
+	// feedRetryDelay - пауза перед повторным запросом партиций топика и чтением после ошибки
+	feedRetryDelay       = 5 * time.Second
+	managerLookupTimeout = 5 * time.Second
+)
+
+// ManagerResolver определяет менеджера сотрудника; пустая строка - менеджер не назначен
+type ManagerResolver interface {
+	ResolveManager(ctx context.Context, userID string) (string, error)
+}
+
+// FeedConfig - топики Kafka, из которых берутся события
+type FeedConfig struct {
+	Brokers           []string
+	TaskTopic         string
+	NotificationTopic string
+	// Managers определяет менеджера исполнителя задачи; nil - менеджер события задачи не получает
+	Managers ManagerResolver
+	// ManagerCacheTTL - сколько помнить менеджера сотрудника; 0 - одна минута
+	ManagerCacheTTL time.Duration
+}
+
+// Feed читает события из Kafka и публикует их в Hub. Каждый экземпляр gateway должен
+// получать все события, поэтому consumer group не используется: каждая партиция
+// читается с последнего offset, и ничего не коммитится.
+type Feed struct {
+	hub      *Hub
+	config   FeedConfig
+	managers ManagerResolver
+	logger   *log.Logger
+}
+
+func NewFeed(hub *Hub, config FeedConfig, logger *log.Logger) (*Feed, error) {
+	if len(config.Brokers) == 0 {
+		return nil, errors.New("kafka brokers are required")
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	feed := &Feed{hub: hub, config: config, logger: logger}
+	if config.Managers != nil {
+		ttl := config.ManagerCacheTTL
+		if ttl <= 0 {
+			ttl = time.Minute
+		}
+		feed.managers = newManagerCache(config.Managers, ttl)
+	}
+	return feed, nil
+}
+
+// Run читает все топики до отмены контекста. Партиции топика запрашиваются при запуске;
+// добавленные позже партиции читаются после перезапуска gateway.
+func (f *Feed) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, topic := range []string{f.config.TaskTopic, f.config.NotificationTopic} {
+		if topic == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			f.readTopic(ctx, topic)
+		}(topic)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (f *Feed) readTopic(ctx context.Context, topic string) {
+	var partitions []int
+	for {
+		var err error
+		if partitions, err = f.partitions(ctx, topic); err == nil {
+			break
+		}
+		f.logger.Printf("events feed lookup partitions of %s: %v", topic, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(feedRetryDelay):
+		}
+	}
+
+	f.logger.Printf("events feed subscribed to topic=%s partitions=%d", topic, len(partitions))
+	var wg sync.WaitGroup
+	for _, partition := range partitions {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   f.config.Brokers,
+			Topic:     topic,
+			Partition: partition,
+		})
+		// Новый экземпляр не пересылает старые события
+		if err := reader.SetOffset(kafka.LastOffset); err != nil {
+			f.logger.Printf("events feed %s[%d]: %v", topic, partition, err)
+			reader.Close()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer reader.Close()
+			f.read(ctx, reader)
+		}()
+	}
+	wg.Wait()
+}
+
+// partitions возвращает номера партиций топика, спрашивая брокеры по очереди
+func (f *Feed) partitions(ctx context.Context, topic string) ([]int, error) {
+	var err error
+	for _, broker := range f.config.Brokers {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", broker); err != nil {
+			continue
+		}
+		var partitions []kafka.Partition
+		partitions, err = conn.ReadPartitions(topic)
+		conn.Close()
+		if err != nil {
+			continue
+		}
+		if len(partitions) == 0 {
+			return nil, fmt.Errorf("topic %s has no partitions", topic)
+		}
+		ids := make([]int, 0, len(partitions))
+		for _, partition := range partitions {
+			ids = append(ids, partition.ID)
+		}
+		return ids, nil
+	}
+	return nil, err
+}
+
+func (f *Feed) read(ctx context.Context, reader *kafka.Reader) {
+	config := reader.Config()
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			f.logger.Printf("events feed read %s[%d]: %v", config.Topic, config.Partition, err)
+			// Пока Kafka недоступна, чтение сразу возвращает ошибку
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(feedRetryDelay):
+			}
+			continue
+		}
+		event, audience, err := f.decode(ctx, config.Topic, msg.Value)
+		if err != nil {
+			f.logger.Printf("events feed decode %s: %v", config.Topic, err)
+			continue
+		}
+		f.hub.Publish(event, audience)
+	}
+}
+
+// decode определяет тип события и получателей. Задачу видят исполнитель, его менеджер,
+// автор и admin, уведомление - только получатель.
+func (f *Feed) decode(ctx context.Context, topic string, value []byte) (Event, Audience, error) {
+	// В SSE data не должно быть переводов строк
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return Event{}, Audience{}, err
+	}
+	value = compact.Bytes()
+
+	if topic == f.config.TaskTopic {
+		var task taskEvent
+		if err := json.Unmarshal(value, &task); err != nil {
+			return Event{}, Audience{}, err
+		}
+		eventType := task.Type
+		if eventType == "" {
+			eventType = defaultTaskEventType
+		}
+		return Event{Type: eventType, Data: value}, Audience{
+			UserIDs: []string{task.UserID, task.CreatedBy, f.managerOf(ctx, task.UserID)},
+			Admins:  true,
+		}, nil
+	}
+
+	var notification notificationEvent
+	if err := json.Unmarshal(value, &notification); err != nil {
+		return Event{}, Audience{}, err
+	}
+	return Event{Type: notificationEventType, Data: value}, Audience{
+		UserIDs: []string{notification.RecipientID},
+	}, nil
+}
+
+// managerOf возвращает менеджера сотрудника. Если менеджера нет или его не удалось
+// определить, возвращается пустая строка: событие получат остальные
+func (f *Feed) managerOf(ctx context.Context, userID string) string {
+	if f.managers == nil || userID == "" {
+		return ""
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, managerLookupTimeout)
+	defer cancel()
+	managerID, err := f.managers.ResolveManager(lookupCtx, userID)
+	if err != nil {
+		f.logger.Printf("events feed resolve manager of %s: %v", userID, err)
+		return ""
+	}
+	return managerID
+}
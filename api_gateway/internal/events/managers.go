@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxCachedManagers - при большем числе записей устаревшие удаляются
+const maxCachedManagers = 10000
+
+type cachedManager struct {
+	managerID string
+	expiresAt time.Time
+}
+
+// managerCache кэширует ResolveManager на ttl, в том числе ответ "менеджер не назначен".
+// Ошибки не кэшируются.
+type managerCache struct {
+	resolver ManagerResolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedManager
+}
+
+func newManagerCache(resolver ManagerResolver, ttl time.Duration) *managerCache {
+	return &managerCache{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  make(map[string]cachedManager),
+	}
+}
+
+func (c *managerCache) ResolveManager(ctx context.Context, userID string) (string, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.managerID, nil
+	}
+
+	managerID, err := c.resolver.ResolveManager(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedManagers {
+		for id, cached := range c.entries {
+			if !now.Before(cached.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= maxCachedManagers {
+			clear(c.entries)
+		}
+	}
+	c.entries[userID] = cachedManager{managerID: managerID, expiresAt: now.Add(c.ttl)}
+	return managerID, nil
+}
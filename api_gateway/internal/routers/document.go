@@ -1,19 +1,28 @@
 package routers
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Oniqq60/task_system_control/api_gateway/internal/services"
 	"github.com/Oniqq60/task_system_control/api_gateway/internal/utils"
 	documentpb "github.com/Oniqq60/task_system_control/gen/proto/document"
 )
 
+// uploadChunkSize - размер одного сообщения при потоковой загрузке в document сервис
+const uploadChunkSize = 64 * 1024
+
 type DocumentRoutes struct {
 	service      *services.DocumentService
 	verifier     *utils.Verifier
@@ -32,8 +41,18 @@ func (r *DocumentRoutes) RegisterHandlers(_ context.Context, mux *http.ServeMux)
 	mux.HandleFunc("POST /document", r.handleAdd)
 	mux.HandleFunc("DELETE /document/{id}", r.handleDelete)
 	mux.HandleFunc("GET /document/{id}", r.handleGet)
-	mux.HandleFunc("GET /document/task/{taskId}", r.handleByTask)
+	// Список документов задачи не лежит под /document/{id}/..., иначе шаблоны пересекаются
+	mux.HandleFunc("GET /task/{taskId}/documents", r.handleByTask)
 	mux.HandleFunc("GET /document/owner", r.handleByOwner)
+	mux.HandleFunc("GET /document/search", r.handleSearch)
+	mux.HandleFunc("POST /document/upload-url", r.handleUploadURL)
+	mux.HandleFunc("POST /document/upload-complete", r.handleUploadComplete)
+	mux.HandleFunc("GET /document/{id}/download-url", r.handleDownloadURL)
+	mux.HandleFunc("GET /document/{id}/versions", r.handleListVersions)
+	mux.HandleFunc("POST /document/{id}/versions/{version}/restore", r.handleRestoreVersion)
+	mux.HandleFunc("POST /document/reconcile", r.handleReconcile)
+	mux.HandleFunc("GET /document/trash", r.handleListTrash)
+	mux.HandleFunc("POST /document/{id}/restore", r.handleRestoreDocument)
 }
 
 func (r *DocumentRoutes) handleAdd(w http.ResponseWriter, req *http.Request) {
@@ -43,108 +62,208 @@ func (r *DocumentRoutes) handleAdd(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Проверяем Content-Type
-	contentType := req.Header.Get("Content-Type")
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	// Сценарий 1: multipart/form-data — файл передаётся в document сервис потоком, без буферизации
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		r.handleAddMultipart(ctx, w, req, claims)
+		return
+	}
 
+	// Сценарий 2: application/json
 	var payload struct {
+		DocumentID  string   `json:"document_id"`
 		TaskID      string   `json:"task_id"`
 		Filename    string   `json:"filename"`
 		ContentType string   `json:"content_type"`
 		FileBase64  string   `json:"file_base64"`
 		Tags        []string `json:"tags"`
 	}
-	var fileContent []byte
+	if err := decodeJSON(req, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// Сценарий 1: multipart/form-data
-	if strings.HasPrefix(contentType, "multipart/form-data") {
-		if err := req.ParseMultipartForm(r.maxBodyBytes); err != nil {
-			writeError(w, http.StatusRequestEntityTooLarge, "file too large or invalid multipart")
-			return
-		}
+	fileContent, err := base64.StdEncoding.DecodeString(payload.FileBase64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file_base64 must be base64 encoded")
+		return
+	}
 
-		taskID := req.FormValue("task_id")
-		filename := req.FormValue("filename")
-		contentTypeValue := req.FormValue("content_type")
-		tags := req.FormValue("tags")
+	info := &documentpb.UploadDocumentInfo{
+		DocumentId:  payload.DocumentID,
+		TaskId:      payload.TaskID,
+		OwnerId:     claims.UserID,
+		Filename:    payload.Filename,
+		ContentType: payload.ContentType,
+		Tags:        payload.Tags,
+	}
+	if msg := validateUploadInfo(info); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if len(fileContent) == 0 {
+		writeError(w, http.StatusBadRequest, "file content is empty")
+		return
+	}
+	if len(fileContent) > int(r.maxBodyBytes) {
+		writeError(w, http.StatusRequestEntityTooLarge, "file too large")
+		return
+	}
 
-		file, _, err := req.FormFile("file")
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "missing file field")
-			return
-		}
-		defer file.Close()
+	resp, err := r.streamUpload(ctx, info, bytes.NewReader(fileContent))
+	if err != nil {
+		r.writeUploadError(w, err)
+		return
+	}
 
-		// Читаем содержимое
-		fileContent, err = io.ReadAll(io.LimitReader(file, r.maxBodyBytes))
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// handleAddMultipart читает части формы по порядку. Текстовые поля (document_id, task_id,
+// filename, content_type, tags) должны идти до поля file: его содержимое сразу уходит в gRPC поток.
+func (r *DocumentRoutes) handleAddMultipart(ctx context.Context, w http.ResponseWriter, req *http.Request, claims utils.Claims) {
+	reader, err := req.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid multipart body")
+		return
+	}
+
+	info := &documentpb.UploadDocumentInfo{OwnerId: claims.UserID}
+	for {
+		part, err := reader.NextPart()
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to read file")
+			if errors.Is(err, io.EOF) {
+				writeError(w, http.StatusBadRequest, "missing file field")
+				return
+			}
+			writeError(w, http.StatusBadRequest, "invalid multipart body")
 			return
 		}
 
-		// Заполняем payload
-		payload.TaskID = taskID
-		payload.Filename = filename
-		payload.ContentType = contentTypeValue
-		if tags != "" {
-			payload.Tags = strings.Split(tags, ",")
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, maxBodySize))
+			part.Close()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid multipart body")
+				return
+			}
+			switch part.FormName() {
+			case "document_id":
+				info.DocumentId = string(value)
+			case "task_id":
+				info.TaskId = string(value)
+			case "filename":
+				info.Filename = string(value)
+			case "content_type":
+				info.ContentType = string(value)
+			case "tags":
+				if len(value) > 0 {
+					info.Tags = strings.Split(string(value), ",")
+				}
+			}
+			continue
 		}
 
-	} else {
-		// Сценарий 2: application/json
-		if err := decodeJSON(req, &payload); err != nil {
-			writeError(w, http.StatusBadRequest, err.Error())
+		if info.Filename == "" {
+			info.Filename = part.FileName()
+		}
+		if msg := validateUploadInfo(info); msg != "" {
+			part.Close()
+			writeError(w, http.StatusBadRequest, msg)
 			return
 		}
 
-		data, err := base64.StdEncoding.DecodeString(payload.FileBase64)
+		resp, err := r.streamUpload(ctx, info, part)
+		part.Close()
 		if err != nil {
-			writeError(w, http.StatusBadRequest, "file_base64 must be base64 encoded")
+			r.writeUploadError(w, err)
 			return
 		}
-		fileContent = data
-	}
 
-	// Валидация
-	if payload.TaskID == "" {
-		writeError(w, http.StatusBadRequest, "task_id is required")
+		writeJSON(w, http.StatusCreated, resp)
 		return
 	}
-	if payload.Filename == "" {
-		writeError(w, http.StatusBadRequest, "filename is required")
-		return
+}
+
+var (
+	errUploadTooLarge = errors.New("file too large")
+	errUploadEmpty    = errors.New("file content is empty")
+)
+
+// streamUpload передаёт содержимое в UploadDocument кусками по uploadChunkSize
+func (r *DocumentRoutes) streamUpload(ctx context.Context, info *documentpb.UploadDocumentInfo, content io.Reader) (*documentpb.AddDocumentResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := r.service.UploadDocument(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if payload.ContentType == "" {
-		writeError(w, http.StatusBadRequest, "content_type is required")
-		return
+	if err := stream.Send(&documentpb.UploadDocumentRequest{
+		Payload: &documentpb.UploadDocumentRequest_Info{Info: info},
+	}); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
 	}
-	if len(fileContent) == 0 {
-		writeError(w, http.StatusBadRequest, "file content is empty")
-		return
+
+	var total int64
+	buf := make([]byte, uploadChunkSize)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			total += int64(n)
+			if r.maxBodyBytes > 0 && total > r.maxBodyBytes {
+				return nil, errUploadTooLarge
+			}
+			if err := stream.Send(&documentpb.UploadDocumentRequest{
+				Payload: &documentpb.UploadDocumentRequest_Chunk{Chunk: buf[:n]},
+			}); err != nil {
+				if errors.Is(err, io.EOF) {
+					// Сервер закрыл поток раньше времени — настоящая ошибка придёт в CloseAndRecv
+					break
+				}
+				return nil, err
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return nil, readErr
+		}
 	}
-	if len(fileContent) > int(r.maxBodyBytes) {
-		writeError(w, http.StatusRequestEntityTooLarge, "file too large")
-		return
+	if total == 0 {
+		return nil, errUploadEmpty
 	}
 
-	// Получаем токен из запроса для передачи в gRPC
-	token, _ := bearerToken(req)
-	ctx := context.WithValue(req.Context(), "jwt_token", token)
+	return stream.CloseAndRecv()
+}
 
-	// Вызов gRPC
-	resp, err := r.service.AddDocument(ctx, &documentpb.AddDocumentRequest{
-		TaskId:      payload.TaskID,
-		OwnerId:     claims.UserID,
-		FileContent: fileContent,
-		Filename:    payload.Filename,
-		ContentType: payload.ContentType,
-		Tags:        payload.Tags,
-	})
-	if err != nil {
+func (r *DocumentRoutes) writeUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUploadTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, errUploadEmpty):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
 		handleRPCError(w, err)
-		return
 	}
+}
 
-	writeJSON(w, http.StatusCreated, resp)
+func validateUploadInfo(info *documentpb.UploadDocumentInfo) string {
+	// Для новой версии задача берётся из существующего документа
+	if info.TaskId == "" && info.DocumentId == "" {
+		return "task_id or document_id is required"
+	}
+	if info.Filename == "" {
+		return "filename is required"
+	}
+	if info.ContentType == "" {
+		return "content_type is required"
+	}
+	return ""
 }
 
 func (r *DocumentRoutes) handleDelete(w http.ResponseWriter, req *http.Request) {
@@ -188,47 +307,99 @@ func (r *DocumentRoutes) handleGet(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	version, err := versionParam(req.URL.Query().Get("version"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Получаем токен из запроса для передачи в gRPC
 	token, _ := bearerToken(req)
-	ctx := context.WithValue(req.Context(), "jwt_token", token)
+	ctx, cancel := context.WithCancel(context.WithValue(req.Context(), "jwt_token", token))
+	defer cancel()
 
-	resp, err := r.service.GetDocument(ctx, &documentpb.GetDocumentRequest{
-		Id: docID,
+	stream, err := r.service.DownloadDocument(ctx, &documentpb.DownloadDocumentRequest{
+		Id:      docID,
+		Version: version,
 	})
 	if err != nil {
 		handleRPCError(w, err)
 		return
 	}
 
-	fileContent := resp.GetFileContent()
-	if r.maxBodyBytes > 0 && len(fileContent) > int(r.maxBodyBytes) {
-		writeError(w, http.StatusRequestEntityTooLarge, "document exceeds response limits")
+	// Первое сообщение потока — метаданные документа, ошибки доступа приходят здесь же
+	first, err := stream.Recv()
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+	info := first.GetInfo()
+	if info == nil {
+		writeError(w, http.StatusBadGateway, "unexpected document stream")
 		return
 	}
 
+	// Контрольная сумма содержимого служит ETag: одинаковое содержимое - одинаковый тег
+	if checksum := info.GetChecksum(); checksum != "" {
+		etag := `"` + checksum + `"`
+		w.Header().Set("ETag", etag)
+		if digest, err := hex.DecodeString(checksum); err == nil {
+			w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(digest))
+		}
+		if etagMatches(req.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
 	// Устанавливаем правильные HTTP заголовки для возврата файла
-	contentType := resp.GetContentType()
+	contentType := info.GetContentType()
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(fileContent)))
-	
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.GetSize()))
+
 	// Content-Disposition для скачивания файла с правильным именем
-	filename := resp.GetFilename()
+	filename := info.GetFilename()
 	if filename == "" {
 		filename = "document"
 	}
 	// Экранируем имя файла для безопасного использования в заголовке
 	escapedFilename := escapeFilenameForHeader(filename)
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, escapedFilename, escapeFilenameForHeaderUTF8(filename)))
-	
-	// Возвращаем бинарные данные напрямую
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write(fileContent); err != nil {
-		// Логируем ошибку, но ответ уже отправлен
+
+	// Пишем куски по мере получения, не собирая файл в памяти; большой файл
+	// отдаётся дольше WRITE_TIMEOUT сервера
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				// Заголовки уже отправлены, поэтому просто обрываем ответ
+				log.Printf("document %s stream error: %v", docID, err)
+			}
+			return
+		}
+		if _, err := w.Write(msg.GetChunk()); err != nil {
+			return
+		}
+	}
+}
+
+// etagMatches проверяет заголовок If-None-Match (список тегов или "*")
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *DocumentRoutes) handleByTask(w http.ResponseWriter, req *http.Request) {
@@ -280,6 +451,375 @@ func (r *DocumentRoutes) handleByOwner(w http.ResponseWriter, req *http.Request)
 	writeJSON(w, http.StatusOK, resp)
 }
 
+func (r *DocumentRoutes) handleSearch(w http.ResponseWriter, req *http.Request) {
+	if _, err := r.authorize(req); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := req.URL.Query()
+	searchReq := &documentpb.SearchDocumentsRequest{
+		Filename:    q.Get("filename"),
+		ContentType: q.Get("content_type"),
+		TaskId:      q.Get("task_id"),
+		OwnerId:     q.Get("owner_id"),
+		Content:     q.Get("content"),
+		SortBy:      q.Get("sort_by"),
+	}
+
+	// Теги принимаются и списком через запятую, и повторяющимся параметром
+	for _, value := range q["tags"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				searchReq.Tags = append(searchReq.Tags, tag)
+			}
+		}
+	}
+
+	switch q.Get("match") {
+	case "", "any":
+	case "all":
+		searchReq.MatchAllTags = true
+	default:
+		writeError(w, http.StatusBadRequest, "match must be any or all")
+		return
+	}
+
+	switch q.Get("order") {
+	case "":
+	case "asc":
+		searchReq.SortDesc = false
+	case "desc":
+		searchReq.SortDesc = true
+	default:
+		writeError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+	if searchReq.SortBy == "" && searchReq.Content == "" && q.Get("order") != "" {
+		// Без sort_by сервис сортирует по дате от новых к старым, явный order это переопределяет
+		searchReq.SortBy = "uploaded_at"
+	}
+
+	var err error
+	if searchReq.UploadedFrom, err = timeParam(q.Get("uploaded_from")); err != nil {
+		writeError(w, http.StatusBadRequest, "uploaded_from: "+err.Error())
+		return
+	}
+	if searchReq.UploadedTo, err = timeParam(q.Get("uploaded_to")); err != nil {
+		writeError(w, http.StatusBadRequest, "uploaded_to: "+err.Error())
+		return
+	}
+	if searchReq.Page, err = intParam(q.Get("page")); err != nil {
+		writeError(w, http.StatusBadRequest, "page: "+err.Error())
+		return
+	}
+	if searchReq.PageSize, err = intParam(q.Get("page_size")); err != nil {
+		writeError(w, http.StatusBadRequest, "page_size: "+err.Error())
+		return
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.SearchDocuments(ctx, searchReq)
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *DocumentRoutes) handleUploadURL(w http.ResponseWriter, req *http.Request) {
+	claims, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var payload struct {
+		TaskID      string `json:"task_id"`
+		Filename    string `json:"filename"`
+		ContentType string `json:"content_type"`
+	}
+	if err := decodeJSON(req, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.CreateUploadURL(ctx, &documentpb.CreateUploadURLRequest{
+		TaskId:      payload.TaskID,
+		OwnerId:     claims.UserID,
+		Filename:    payload.Filename,
+		ContentType: payload.ContentType,
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *DocumentRoutes) handleUploadComplete(w http.ResponseWriter, req *http.Request) {
+	claims, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var payload struct {
+		ObjectKey   string   `json:"object_key"`
+		DocumentID  string   `json:"document_id"`
+		TaskID      string   `json:"task_id"`
+		Filename    string   `json:"filename"`
+		ContentType string   `json:"content_type"`
+		Tags        []string `json:"tags"`
+	}
+	if err := decodeJSON(req, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if payload.ObjectKey == "" {
+		writeError(w, http.StatusBadRequest, "object_key is required")
+		return
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.CompleteUpload(ctx, &documentpb.CompleteUploadRequest{
+		ObjectKey:   payload.ObjectKey,
+		DocumentId:  payload.DocumentID,
+		TaskId:      payload.TaskID,
+		OwnerId:     claims.UserID,
+		Filename:    payload.Filename,
+		ContentType: payload.ContentType,
+		Tags:        payload.Tags,
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (r *DocumentRoutes) handleDownloadURL(w http.ResponseWriter, req *http.Request) {
+	if _, err := r.authorize(req); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	docID := req.PathValue("id")
+	if docID == "" {
+		writeError(w, http.StatusBadRequest, "document id is required")
+		return
+	}
+
+	version, err := versionParam(req.URL.Query().Get("version"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.CreateDownloadURL(ctx, &documentpb.CreateDownloadURLRequest{
+		Id:      docID,
+		Version: version,
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *DocumentRoutes) handleListVersions(w http.ResponseWriter, req *http.Request) {
+	if _, err := r.authorize(req); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	docID := req.PathValue("id")
+	if docID == "" {
+		writeError(w, http.StatusBadRequest, "document id is required")
+		return
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.ListVersions(ctx, &documentpb.ListVersionsRequest{
+		Id: docID,
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *DocumentRoutes) handleRestoreVersion(w http.ResponseWriter, req *http.Request) {
+	if _, err := r.authorize(req); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	docID := req.PathValue("id")
+	if docID == "" {
+		writeError(w, http.StatusBadRequest, "document id is required")
+		return
+	}
+	version, err := versionParam(req.PathValue("version"))
+	if err != nil || version == 0 {
+		writeError(w, http.StatusBadRequest, "version must be a positive integer")
+		return
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.RestoreVersion(ctx, &documentpb.RestoreVersionRequest{
+		Id:      docID,
+		Version: version,
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *DocumentRoutes) handleReconcile(w http.ResponseWriter, req *http.Request) {
+	if _, err := r.authorize(req); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	deleteOrphans := false
+	if value := req.URL.Query().Get("delete_orphans"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "delete_orphans must be true or false")
+			return
+		}
+		deleteOrphans = parsed
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.ReconcileStorage(ctx, &documentpb.ReconcileStorageRequest{
+		DeleteOrphans: deleteOrphans,
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *DocumentRoutes) handleListTrash(w http.ResponseWriter, req *http.Request) {
+	if _, err := r.authorize(req); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.ListTrash(ctx, &documentpb.ListTrashRequest{
+		OwnerId: req.URL.Query().Get("owner_id"),
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *DocumentRoutes) handleRestoreDocument(w http.ResponseWriter, req *http.Request) {
+	if _, err := r.authorize(req); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	docID := req.PathValue("id")
+	if docID == "" {
+		writeError(w, http.StatusBadRequest, "document id is required")
+		return
+	}
+
+	// Получаем токен из запроса для передачи в gRPC
+	token, _ := bearerToken(req)
+	ctx := context.WithValue(req.Context(), "jwt_token", token)
+
+	resp, err := r.service.RestoreDocument(ctx, &documentpb.RestoreDocumentRequest{Id: docID})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// timeParam принимает дату в формате RFC 3339 или YYYY-MM-DD и возвращает Unix timestamp
+func timeParam(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return 0, errors.New("expected RFC 3339 timestamp or YYYY-MM-DD date")
+	}
+	return t.Unix(), nil
+}
+
+func intParam(value string) (int32, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 0 {
+		return 0, errors.New("must be a non-negative integer")
+	}
+	return int32(n), nil
+}
+
+// versionParam разбирает номер версии; пустое значение означает текущую версию
+func versionParam(value string) (int32, error) {
+	if value == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || version < 0 {
+		return 0, errors.New("version must be a positive integer")
+	}
+	return int32(version), nil
+}
+
 func (r *DocumentRoutes) authorize(req *http.Request) (utils.Claims, error) {
 	token, err := bearerToken(req)
 	if err != nil {
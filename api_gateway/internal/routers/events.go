@@ -0,0 +1,103 @@
+package routers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/events"
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/utils"
+)
+
+const defaultEventsHeartbeat = 25 * time.Second
+
+// EventRoutes - поток событий задач и уведомлений (Server-Sent Events)
+type EventRoutes struct {
+	hub       *events.Hub
+	verifier  *utils.Verifier
+	heartbeat time.Duration
+}
+
+func NewEventRoutes(hub *events.Hub, verifier *utils.Verifier, heartbeat time.Duration) *EventRoutes {
+	if heartbeat <= 0 {
+		heartbeat = defaultEventsHeartbeat
+	}
+	return &EventRoutes{
+		hub:       hub,
+		verifier:  verifier,
+		heartbeat: heartbeat,
+	}
+}
+
+func (r *EventRoutes) RegisterHandlers(_ context.Context, mux *http.ServeMux) {
+	mux.HandleFunc("GET /events", r.handleStream)
+}
+
+func (r *EventRoutes) handleStream(w http.ResponseWriter, req *http.Request) {
+	token, err := bearerToken(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	claims, err := r.verifier.ParseToken(req.Context(), token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Поток живёт дольше WRITE_TIMEOUT сервера
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	sub := r.hub.Subscribe(claims.UserID, claims.Role)
+	defer r.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(r.heartbeat)
+	defer heartbeat.Stop()
+
+	// Поток закрывается, когда истекает токен: клиент переподключится с новым
+	var expired <-chan time.Time
+	if !claims.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(claims.ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-expired:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Клиент не успевал читать события и был отключён
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
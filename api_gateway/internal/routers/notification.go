@@ -0,0 +1,463 @@
+package routers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/services"
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/utils"
+	notificationpb "github.com/Oniqq60/task_system_control/gen/proto/notification"
+)
+
+// NotificationRoutes - ящик уведомлений текущего пользователя и webhook подписки (только admin)
+type NotificationRoutes struct {
+	service  *services.NotificationService
+	verifier *utils.Verifier
+}
+
+func NewNotificationRoutes(svc *services.NotificationService, verifier *utils.Verifier) *NotificationRoutes {
+	return &NotificationRoutes{
+		service:  svc,
+		verifier: verifier,
+	}
+}
+
+func (r *NotificationRoutes) RegisterHandlers(_ context.Context, mux *http.ServeMux) {
+	mux.HandleFunc("GET /notifications", r.handleList)
+	mux.HandleFunc("GET /notifications/unread-count", r.handleUnreadCount)
+	mux.HandleFunc("POST /notifications/read-all", r.handleMarkAllRead)
+	mux.HandleFunc("POST /notifications/{id}/read", r.handleMarkRead)
+	mux.HandleFunc("GET /notifications/preferences", r.handleGetPreferences)
+	mux.HandleFunc("PUT /notifications/preferences", r.handleUpdatePreferences)
+
+	mux.HandleFunc("POST /webhooks", r.handleCreateWebhook)
+	mux.HandleFunc("GET /webhooks", r.handleListWebhooks)
+	mux.HandleFunc("DELETE /webhooks/{id}", r.handleDeleteWebhook)
+	mux.HandleFunc("GET /webhooks/{id}/deliveries", r.handleListDeliveries)
+	mux.HandleFunc("POST /webhooks/{id}/deliveries/replay", r.handleReplayDeliveries)
+
+	mux.HandleFunc("GET /notification-rules", r.handleListRules)
+	mux.HandleFunc("POST /notification-rules", r.handleCreateRule)
+	mux.HandleFunc("PUT /notification-rules/{id}", r.handleUpdateRule)
+	mux.HandleFunc("DELETE /notification-rules/{id}", r.handleDeleteRule)
+}
+
+func (r *NotificationRoutes) handleList(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := req.URL.Query()
+	unreadOnly := false
+	if value := q.Get("unread_only"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "unread_only must be true or false")
+			return
+		}
+		unreadOnly = parsed
+	}
+	limit, err := intParam(q.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "limit "+err.Error())
+		return
+	}
+
+	resp, err := r.service.List(ctx, &notificationpb.ListRequest{
+		UnreadOnly: unreadOnly,
+		Limit:      limit,
+		BeforeId:   q.Get("before_id"),
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleUnreadCount(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	resp, err := r.service.UnreadCount(ctx, &notificationpb.UnreadCountRequest{})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleMarkRead(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	id := req.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "notification id is required")
+		return
+	}
+
+	resp, err := r.service.MarkRead(ctx, &notificationpb.MarkReadRequest{
+		Ids: []string{id},
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleMarkAllRead(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	resp, err := r.service.MarkAllRead(ctx, &notificationpb.MarkAllReadRequest{})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleCreateWebhook(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var payload struct {
+		URL         string   `json:"url"`
+		EventTypes  []string `json:"event_types"`
+		Secret      string   `json:"secret"`
+		Description string   `json:"description"`
+	}
+	if err := decodeJSON(req, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if payload.URL == "" {
+		writeError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	resp, err := r.service.CreateWebhook(ctx, &notificationpb.CreateWebhookRequest{
+		Url:         payload.URL,
+		EventTypes:  payload.EventTypes,
+		Secret:      payload.Secret,
+		Description: payload.Description,
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (r *NotificationRoutes) handleListWebhooks(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	resp, err := r.service.ListWebhooks(ctx, &notificationpb.ListWebhooksRequest{})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleDeleteWebhook(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	id := req.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "webhook id is required")
+		return
+	}
+
+	resp, err := r.service.DeleteWebhook(ctx, &notificationpb.DeleteWebhookRequest{Id: id})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleListDeliveries(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	id := req.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "webhook id is required")
+		return
+	}
+	q := req.URL.Query()
+	limit, err := intParam(q.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "limit "+err.Error())
+		return
+	}
+
+	resp, err := r.service.ListWebhookDeliveries(ctx, &notificationpb.ListWebhookDeliveriesRequest{
+		WebhookId: id,
+		Status:    q.Get("status"),
+		Limit:     limit,
+		BeforeId:  q.Get("before_id"),
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleReplayDeliveries(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	id := req.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "webhook id is required")
+		return
+	}
+	// Тело необязательно: без delivery_ids повторяются все неудавшиеся доставки
+	var payload struct {
+		DeliveryIDs []string `json:"delivery_ids"`
+	}
+	if err := decodeJSON(req, &payload); err != nil && err != errEmptyBody {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := r.service.ReplayWebhookDeliveries(ctx, &notificationpb.ReplayWebhookDeliveriesRequest{
+		WebhookId:   id,
+		DeliveryIds: payload.DeliveryIDs,
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleGetPreferences(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	resp, err := r.service.GetPreferences(ctx, &notificationpb.GetPreferencesRequest{})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleUpdatePreferences(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Настройки заменяются целиком
+	var payload struct {
+		NotificationTypes []string `json:"notification_types"`
+		Channels          []string `json:"channels"`
+		QuietHoursStart   string   `json:"quiet_hours_start"`
+		QuietHoursEnd     string   `json:"quiet_hours_end"`
+		Timezone          string   `json:"timezone"`
+		Digest            string   `json:"digest"`
+	}
+	if err := decodeJSON(req, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := r.service.UpdatePreferences(ctx, &notificationpb.UpdatePreferencesRequest{
+		Preferences: &notificationpb.Preferences{
+			NotificationTypes: payload.NotificationTypes,
+			Channels:          payload.Channels,
+			QuietHoursStart:   payload.QuietHoursStart,
+			QuietHoursEnd:     payload.QuietHoursEnd,
+			Timezone:          payload.Timezone,
+			Digest:            payload.Digest,
+		},
+	})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleListRules(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	resp, err := r.service.ListRules(ctx, &notificationpb.ListRulesRequest{})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleCreateRule(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	rule, err := decodeRule(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := r.service.CreateRule(ctx, &notificationpb.CreateRuleRequest{Rule: rule})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (r *NotificationRoutes) handleUpdateRule(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	id := req.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "rule id is required")
+		return
+	}
+	rule, err := decodeRule(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rule.Id = id
+
+	resp, err := r.service.UpdateRule(ctx, &notificationpb.UpdateRuleRequest{Rule: rule})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *NotificationRoutes) handleDeleteRule(w http.ResponseWriter, req *http.Request) {
+	ctx, err := r.authorize(req)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	id := req.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "rule id is required")
+		return
+	}
+
+	resp, err := r.service.DeleteRule(ctx, &notificationpb.DeleteRuleRequest{Id: id})
+	if err != nil {
+		handleRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// decodeRule разбирает правило из тела запроса; без enabled правило включено
+func decodeRule(req *http.Request) (*notificationpb.Rule, error) {
+	var payload struct {
+		Name             string `json:"name"`
+		EventType        string `json:"event_type"`
+		Status           string `json:"status"`
+		Recipient        string `json:"recipient"`
+		ManagerLevel     int32  `json:"manager_level"`
+		DelaySeconds     int64  `json:"delay_seconds"`
+		NotificationType string `json:"notification_type"`
+		Enabled          *bool  `json:"enabled"`
+	}
+	if err := decodeJSON(req, &payload); err != nil {
+		return nil, err
+	}
+	enabled := true
+	if payload.Enabled != nil {
+		enabled = *payload.Enabled
+	}
+	return &notificationpb.Rule{
+		Name:             payload.Name,
+		EventType:        payload.EventType,
+		Status:           payload.Status,
+		Recipient:        payload.Recipient,
+		ManagerLevel:     payload.ManagerLevel,
+		DelaySeconds:     payload.DelaySeconds,
+		NotificationType: payload.NotificationType,
+		Enabled:          enabled,
+	}, nil
+}
+
+// authorize проверяет токен и возвращает контекст с ним для передачи в gRPC.
+// Ящик выбирается notification сервисом по user_id из токена.
+func (r *NotificationRoutes) authorize(req *http.Request) (context.Context, error) {
+	token, err := bearerToken(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.verifier.ParseToken(req.Context(), token); err != nil {
+		return nil, err
+	}
+	return context.WithValue(req.Context(), "jwt_token", token), nil
+}
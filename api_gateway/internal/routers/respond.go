@@ -75,6 +75,8 @@ func mapRPCError(err error) (int, string) {
 		return http.StatusBadRequest, st.Message()
 	case codes.NotFound:
 		return http.StatusNotFound, st.Message()
+	case codes.AlreadyExists, codes.Aborted, codes.FailedPrecondition:
+		return http.StatusConflict, st.Message()
 	case codes.PermissionDenied, codes.Unauthenticated:
 		return http.StatusUnauthorized, st.Message()
 	case codes.ResourceExhausted:
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"time"
 
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/events"
 	"github.com/Oniqq60/task_system_control/api_gateway/internal/services"
 	"github.com/Oniqq60/task_system_control/api_gateway/internal/utils"
 )
@@ -13,6 +15,9 @@ type Dependencies struct {
 	Auth                 *services.AuthService
 	Task                 *services.TaskService
 	Document             *services.DocumentService
+	Notification         *services.NotificationService
+	Events               *events.Hub
+	EventsHeartbeat      time.Duration
 	JWTVerifier          *utils.Verifier
 	Middleware           []func(http.Handler) http.Handler
 	MaxDocumentBodyBytes int64
@@ -24,7 +29,7 @@ type Router struct {
 }
 
 func New(deps Dependencies) (*Router, error) {
-	if deps.Auth == nil || deps.Task == nil || deps.Document == nil {
+	if deps.Auth == nil || deps.Task == nil || deps.Document == nil || deps.Notification == nil || deps.Events == nil {
 		return nil, errors.New("all downstream services must be provided")
 	}
 	if deps.JWTVerifier == nil {
@@ -37,6 +42,8 @@ func New(deps Dependencies) (*Router, error) {
 	NewAuthRoutes(deps.Auth).RegisterHandlers(ctx, mux)
 	NewTaskRoutes(deps.Task, deps.JWTVerifier).RegisterHandlers(ctx, mux)
 	NewDocumentRoutes(deps.Document, deps.JWTVerifier, deps.MaxDocumentBodyBytes).RegisterHandlers(ctx, mux)
+	NewNotificationRoutes(deps.Notification, deps.JWTVerifier).RegisterHandlers(ctx, mux)
+	NewEventRoutes(deps.Events, deps.JWTVerifier, deps.EventsHeartbeat).RegisterHandlers(ctx, mux)
 
 	var handler http.Handler = mux
 	for i := len(deps.Middleware) - 1; i >= 0; i-- {
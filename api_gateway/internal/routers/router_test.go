@@ -0,0 +1,60 @@
+package routers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/events"
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/services"
+	"github.com/Oniqq60/task_system_control/api_gateway/internal/utils"
+)
+
+// newTestRouter собирает полный mux: пересекающиеся шаблоны маршрутов паникуют при регистрации
+func newTestRouter(t *testing.T) *Router {
+	t.Helper()
+	verifier, err := utils.NewVerifier("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := New(Dependencies{
+		Auth:         &services.AuthService{},
+		Task:         &services.TaskService{},
+		Document:     &services.DocumentService{},
+		Notification: &services.NotificationService{},
+		Events:       events.NewHub(1),
+		JWTVerifier:  verifier,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestRouterPatterns(t *testing.T) {
+	router := newTestRouter(t)
+	tests := []struct {
+		method  string
+		path    string
+		pattern string
+	}{
+		{http.MethodGet, "/document/abc", "GET /document/{id}"},
+		{http.MethodGet, "/document/owner", "GET /document/owner"},
+		{http.MethodGet, "/document/trash", "GET /document/trash"},
+		{http.MethodGet, "/document/task/download-url", "GET /document/{id}/download-url"},
+		{http.MethodGet, "/document/abc/download-url", "GET /document/{id}/download-url"},
+		{http.MethodGet, "/document/task/versions", "GET /document/{id}/versions"},
+		{http.MethodPost, "/document/abc/versions/2/restore", "POST /document/{id}/versions/{version}/restore"},
+		{http.MethodPost, "/document/abc/restore", "POST /document/{id}/restore"},
+		{http.MethodGet, "/task/abc/documents", "GET /task/{taskId}/documents"},
+		{http.MethodPatch, "/task/abc", "PATCH /task/{id}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			_, pattern := router.mux.Handler(httptest.NewRequest(tt.method, tt.path, nil))
+			if pattern != tt.pattern {
+				t.Fatalf("pattern = %q, want %q", pattern, tt.pattern)
+			}
+		})
+	}
+}
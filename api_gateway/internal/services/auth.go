@@ -69,3 +69,15 @@ func (s *AuthService) Login(ctx context.Context, req *authpb.LoginRequest) (*aut
 func (s *AuthService) ValidateToken(ctx context.Context, req *authpb.ValidateTokenRequest) (*authpb.ValidateTokenResponse, error) {
 	return s.client.ValidateToken(ctx, req)
 }
+
+// ResolveManager возвращает ID менеджера сотрудника или пустую строку, если менеджер не назначен
+func (s *AuthService) ResolveManager(ctx context.Context, userID string) (string, error) {
+	resp, err := s.client.GetManager(ctx, &authpb.GetManagerRequest{UserId: userID})
+	if err != nil {
+		return "", err
+	}
+	if !resp.GetFound() {
+		return "", nil
+	}
+	return resp.GetManagerId(), nil
+}
@@ -81,3 +81,58 @@ func (s *DocumentService) GetDocumentsByOwner(ctx context.Context, req *document
 	ctx = s.addAuthMetadata(ctx)
 	return s.client.GetDocumentsByOwner(ctx, req)
 }
+
+func (s *DocumentService) UploadDocument(ctx context.Context) (documentpb.DocumentService_UploadDocumentClient, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.UploadDocument(ctx)
+}
+
+func (s *DocumentService) DownloadDocument(ctx context.Context, req *documentpb.DownloadDocumentRequest) (documentpb.DocumentService_DownloadDocumentClient, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.DownloadDocument(ctx, req)
+}
+
+func (s *DocumentService) CreateUploadURL(ctx context.Context, req *documentpb.CreateUploadURLRequest) (*documentpb.CreateUploadURLResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.CreateUploadURL(ctx, req)
+}
+
+func (s *DocumentService) CompleteUpload(ctx context.Context, req *documentpb.CompleteUploadRequest) (*documentpb.AddDocumentResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.CompleteUpload(ctx, req)
+}
+
+func (s *DocumentService) CreateDownloadURL(ctx context.Context, req *documentpb.CreateDownloadURLRequest) (*documentpb.CreateDownloadURLResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.CreateDownloadURL(ctx, req)
+}
+
+func (s *DocumentService) ListVersions(ctx context.Context, req *documentpb.ListVersionsRequest) (*documentpb.ListVersionsResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.ListVersions(ctx, req)
+}
+
+func (s *DocumentService) RestoreVersion(ctx context.Context, req *documentpb.RestoreVersionRequest) (*documentpb.Document, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.RestoreVersion(ctx, req)
+}
+
+func (s *DocumentService) SearchDocuments(ctx context.Context, req *documentpb.SearchDocumentsRequest) (*documentpb.SearchDocumentsResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.SearchDocuments(ctx, req)
+}
+
+func (s *DocumentService) ReconcileStorage(ctx context.Context, req *documentpb.ReconcileStorageRequest) (*documentpb.ReconcileStorageResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.ReconcileStorage(ctx, req)
+}
+
+func (s *DocumentService) ListTrash(ctx context.Context, req *documentpb.ListTrashRequest) (*documentpb.ListTrashResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.ListTrash(ctx, req)
+}
+
+func (s *DocumentService) RestoreDocument(ctx context.Context, req *documentpb.RestoreDocumentRequest) (*documentpb.Document, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.RestoreDocument(ctx, req)
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	notificationpb "github.com/Oniqq60/task_system_control/gen/proto/notification"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+type NotificationService struct {
+	conn   *grpc.ClientConn
+	client notificationpb.NotificationServiceClient
+}
+
+func NewNotificationService(target string) (*NotificationService, error) {
+	if target == "" {
+		return nil, fmt.Errorf("notification grpc target is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, target,
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("dial notification service: %w", err)
+	}
+
+	return &NotificationService{
+		conn:   conn,
+		client: notificationpb.NewNotificationServiceClient(conn),
+	}, nil
+}
+
+func (s *NotificationService) Close() error {
+	if s == nil || s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// addAuthMetadata добавляет JWT токен в gRPC metadata: notification сервис
+// определяет владельца ящика по токену
+func (s *NotificationService) addAuthMetadata(ctx context.Context) context.Context {
+	if token, ok := ctx.Value("jwt_token").(string); ok && token != "" {
+		md := metadata.New(map[string]string{
+			"authorization": "Bearer " + token,
+		})
+		return metadata.NewOutgoingContext(ctx, md)
+	}
+	return ctx
+}
+
+func (s *NotificationService) List(ctx context.Context, req *notificationpb.ListRequest) (*notificationpb.ListResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.List(ctx, req)
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, req *notificationpb.MarkReadRequest) (*notificationpb.MarkReadResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.MarkRead(ctx, req)
+}
+
+func (s *NotificationService) MarkAllRead(ctx context.Context, req *notificationpb.MarkAllReadRequest) (*notificationpb.MarkReadResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.MarkAllRead(ctx, req)
+}
+
+func (s *NotificationService) UnreadCount(ctx context.Context, req *notificationpb.UnreadCountRequest) (*notificationpb.UnreadCountResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.UnreadCount(ctx, req)
+}
+
+func (s *NotificationService) CreateWebhook(ctx context.Context, req *notificationpb.CreateWebhookRequest) (*notificationpb.Webhook, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.CreateWebhook(ctx, req)
+}
+
+func (s *NotificationService) ListWebhooks(ctx context.Context, req *notificationpb.ListWebhooksRequest) (*notificationpb.ListWebhooksResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.ListWebhooks(ctx, req)
+}
+
+func (s *NotificationService) DeleteWebhook(ctx context.Context, req *notificationpb.DeleteWebhookRequest) (*notificationpb.DeleteWebhookResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.DeleteWebhook(ctx, req)
+}
+
+func (s *NotificationService) ListWebhookDeliveries(ctx context.Context, req *notificationpb.ListWebhookDeliveriesRequest) (*notificationpb.ListWebhookDeliveriesResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.ListWebhookDeliveries(ctx, req)
+}
+
+func (s *NotificationService) ReplayWebhookDeliveries(ctx context.Context, req *notificationpb.ReplayWebhookDeliveriesRequest) (*notificationpb.ReplayWebhookDeliveriesResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.ReplayWebhookDeliveries(ctx, req)
+}
+
+func (s *NotificationService) GetPreferences(ctx context.Context, req *notificationpb.GetPreferencesRequest) (*notificationpb.Preferences, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.GetPreferences(ctx, req)
+}
+
+func (s *NotificationService) UpdatePreferences(ctx context.Context, req *notificationpb.UpdatePreferencesRequest) (*notificationpb.Preferences, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.UpdatePreferences(ctx, req)
+}
+
+func (s *NotificationService) ListRules(ctx context.Context, req *notificationpb.ListRulesRequest) (*notificationpb.ListRulesResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.ListRules(ctx, req)
+}
+
+func (s *NotificationService) CreateRule(ctx context.Context, req *notificationpb.CreateRuleRequest) (*notificationpb.Rule, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.CreateRule(ctx, req)
+}
+
+func (s *NotificationService) UpdateRule(ctx context.Context, req *notificationpb.UpdateRuleRequest) (*notificationpb.Rule, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.UpdateRule(ctx, req)
+}
+
+func (s *NotificationService) DeleteRule(ctx context.Context, req *notificationpb.DeleteRuleRequest) (*notificationpb.DeleteRuleResponse, error) {
+	ctx = s.addAuthMetadata(ctx)
+	return s.client.DeleteRule(ctx, req)
+}
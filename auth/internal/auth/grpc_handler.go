@@ -193,3 +193,29 @@ func (h *GrpcHandler) GetManager(ctx context.Context, req *pb.GetManagerRequest)
 	}
 	return resp, nil
 }
+
+// GetUserContact возвращает email и имя пользователя для отправки уведомлений.
+func (h *GrpcHandler) GetUserContact(ctx context.Context, req *pb.GetUserContactRequest) (*pb.GetUserContactResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user_id")
+	}
+
+	user, err := h.service.Profile(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to fetch user")
+	}
+
+	return &pb.GetUserContactResponse{
+		UserId: user.ID.String(),
+		Email:  user.Email,
+		Name:   user.Name,
+	}, nil
+}
@@ -0,0 +1,112 @@
+// migrate-storage копирует объекты документов из одного хранилища в другое.
+// Запускается при остановленном document сервисе; настройки обоих хранилищ берутся
+// из тех же переменных окружения, что и у сервиса. Исходные объекты не удаляются.
+//
+//	go run ./cmd/migrate-storage -from minio -to filesystem
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	appcfg "github.com/Oniqq60/task_system_control/document/internal/cfg"
+	"github.com/Oniqq60/task_system_control/document/internal/document"
+)
+
+func main() {
+	from := flag.String("from", "minio", "source backend: minio or filesystem")
+	to := flag.String("to", "filesystem", "destination backend: minio or filesystem")
+	prefix := flag.String("prefix", "documents/", "copy only objects with this key prefix")
+	dryRun := flag.Bool("dry-run", false, "only report objects that would be copied")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatalf("source and destination backends are the same: %s", *from)
+	}
+
+	conf := appcfg.LoadConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	src, err := openStorage(conf, *from)
+	if err != nil {
+		log.Fatalf("failed to open source %s storage: %v", *from, err)
+	}
+	dst, err := openStorage(conf, *to)
+	if err != nil {
+		log.Fatalf("failed to open destination %s storage: %v", *to, err)
+	}
+
+	var scanned, copied, skipped, failed int64
+	err = src.ListObjects(ctx, *prefix, func(obj document.ObjectInfo) error {
+		scanned++
+		// Повторный запуск продолжает прерванную миграцию
+		size, err := dst.Stat(ctx, obj.Key)
+		if err == nil && size == obj.Size {
+			skipped++
+			return nil
+		}
+		if err != nil && !errors.Is(err, document.ErrObjectNotFound) {
+			return fmt.Errorf("stat %s: %w", obj.Key, err)
+		}
+		if *dryRun {
+			log.Printf("would copy %s (%d bytes)", obj.Key, obj.Size)
+			copied++
+			return nil
+		}
+		if err := copyObject(ctx, src, dst, obj.Key); err != nil {
+			failed++
+			log.Printf("copy %s: %v", obj.Key, err)
+			return nil
+		}
+		copied++
+		return nil
+	})
+	log.Printf("scanned %d objects: copied %d, already present %d, failed %d", scanned, copied, skipped, failed)
+	if err != nil {
+		log.Fatalf("migration stopped: %v", err)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func copyObject(ctx context.Context, src, dst document.ObjectStorage, objectKey string) error {
+	reader, size, err := src.Get(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	// Тип содержимого задаётся при выдаче ссылки на скачивание, поэтому здесь не переносится
+	return dst.Put(ctx, objectKey, "application/octet-stream", reader, size)
+}
+
+func openStorage(conf appcfg.Config, backend string) (document.ObjectStorage, error) {
+	switch backend {
+	case "minio":
+		return document.NewMinioStorage(
+			conf.MinioEndpoint,
+			conf.MinioAccessKey,
+			conf.MinioSecretKey,
+			conf.MinioUseSSL,
+			conf.MinioBucket,
+			conf.MinioPublicURL,
+		)
+	case "filesystem":
+		storage, _, err := document.NewFilesystemStorage(
+			conf.StorageFSRoot,
+			conf.StorageFSPublicURL,
+			[]byte(conf.StorageSigningKey),
+			conf.MaxFileSizeBytes,
+		)
+		return storage, err
+	default:
+		return nil, fmt.Errorf("unknown backend %q: expected minio or filesystem", backend)
+	}
+}
@@ -0,0 +1,57 @@
+// rotate-keys переоборачивает ключи данных документов активным мастер-ключом.
+// Содержимое в хранилище не перешифровывается. Старый мастер-ключ должен оставаться
+// в ENCRYPTION_KEYS / ENCRYPTION_KEY_FILE, пока команда не завершится успешно.
+//
+//	ENCRYPTION_ACTIVE_KEY=k2 go run ./cmd/rotate-keys
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	appcfg "github.com/Oniqq60/task_system_control/document/internal/cfg"
+	"github.com/Oniqq60/task_system_control/document/internal/document"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	conf := appcfg.LoadConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	keys, err := document.LoadMasterKeys(conf.EncryptionKeys, conf.EncryptionKeyFile, conf.EncryptionActiveKey)
+	if err != nil {
+		log.Fatalf("failed to load encryption keys: %v", err)
+	}
+	if keys == nil {
+		log.Fatal("no master keys configured: set ENCRYPTION_KEYS or ENCRYPTION_KEY_FILE")
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(conf.MongoURI))
+	if err != nil {
+		log.Fatalf("failed to connect to mongo: %v", err)
+	}
+	defer func() {
+		disconnectCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = client.Disconnect(disconnectCtx)
+	}()
+
+	database := client.Database(conf.MongoDatabase)
+	repo := document.NewRepository(
+		database.Collection(conf.MongoCollection),
+		database.Collection(conf.MongoVersionsCollection),
+		database.Collection(conf.MongoBlobsCollection),
+	)
+
+	updated, err := repo.RewrapEncryptionKeys(ctx, keys)
+	log.Printf("rewrapped %d data keys with master key %q", updated, keys.ActiveKeyID())
+	if err != nil {
+		log.Fatalf("rotation stopped: %v", err)
+	}
+}
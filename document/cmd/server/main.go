@@ -12,13 +12,16 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Oniqq60/task_system_control/document/internal/authclient"
 	appcfg "github.com/Oniqq60/task_system_control/document/internal/cfg"
 	"github.com/Oniqq60/task_system_control/document/internal/document"
+	"github.com/Oniqq60/task_system_control/document/internal/taskclient"
 	pb "github.com/Oniqq60/task_system_control/gen/proto/document"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
@@ -37,18 +40,29 @@ func main() {
 		}
 	}()
 
-	collection := mongoClient.Database(conf.MongoDatabase).Collection(conf.MongoCollection)
-	repo := document.NewRepository(collection)
-
-	storage, err := document.NewMinioStorage(
-		conf.MinioEndpoint,
-		conf.MinioAccessKey,
-		conf.MinioSecretKey,
-		conf.MinioUseSSL,
-		conf.MinioBucket,
+	database := mongoClient.Database(conf.MongoDatabase)
+	repo := document.NewRepository(
+		database.Collection(conf.MongoCollection),
+		database.Collection(conf.MongoVersionsCollection),
+		database.Collection(conf.MongoBlobsCollection),
 	)
+	indexCtx, cancelIndex := context.WithTimeout(ctx, 10*time.Second)
+	if err := repo.EnsureIndexes(indexCtx); err != nil {
+		logger.Fatalf("failed to create mongo indexes: %v", err)
+	}
+	cancelIndex()
+
+	keys, err := document.LoadMasterKeys(conf.EncryptionKeys, conf.EncryptionKeyFile, conf.EncryptionActiveKey)
 	if err != nil {
-		logger.Fatalf("failed to connect to MinIO: %v", err)
+		logger.Fatalf("failed to load encryption keys: %v", err)
+	}
+	if keys != nil {
+		logger.Printf("encryption at rest enabled, active master key %q", keys.ActiveKeyID())
+	}
+
+	storage, storageHandler, err := newStorage(conf)
+	if err != nil {
+		logger.Fatalf("failed to open %s storage: %v", conf.StorageBackend, err)
 	}
 
 	redisClient := redis.NewClient(&redis.Options{
@@ -57,11 +71,76 @@ func main() {
 	})
 	defer redisClient.Close()
 
-	service := document.NewService(repo, storage)
+	taskConn, err := grpc.DialContext(
+		ctx,
+		conf.TaskGRPCAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		logger.Fatalf("failed to connect to task gRPC: %v", err)
+	}
+	defer taskConn.Close()
+
+	authConn, err := grpc.DialContext(
+		ctx,
+		conf.AuthGRPCAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		logger.Fatalf("failed to connect to auth gRPC: %v", err)
+	}
+	defer authConn.Close()
+
+	reconciler := document.NewReconciler(repo, storage, conf.ReconcileGracePeriod)
+	if conf.ReconcileInterval > 0 {
+		go reconciler.Run(ctx, conf.ReconcileInterval, conf.ReconcileDeleteOrphans)
+	}
+
+	purgeWorker := document.NewPurgeWorker(repo, storage, conf.TrashRetention, conf.TrashPurgeInterval)
+	go purgeWorker.Run(ctx)
+
+	if conf.ScrubInterval > 0 {
+		scrubWorker := document.NewScrubWorker(repo, storage, keys, conf.ScrubReverifyAfter, conf.ScrubInterval)
+		go scrubWorker.Run(ctx)
+	}
+
+	validator, err := document.NewValidator(conf.AllowedExtensions, conf.AllowedMimeTypes)
+	if err != nil {
+		logger.Fatalf("invalid ALLOWED_EXTENSIONS: %v", err)
+	}
+
+	deps := document.Dependencies{
+		Repo:               repo,
+		Storage:            storage,
+		Validator:          validator,
+		PresignExpiry:      conf.PresignExpiry,
+		SpoolDir:           conf.UploadSpoolDir,
+		Keys:               keys,
+		Tasks:              taskclient.New(taskConn, conf.TaskRPCTimeout),
+		AttachableStatuses: conf.AttachableTaskStatuses,
+		Managers:           authclient.New(authConn, conf.TaskRPCTimeout),
+		TaskCacheTTL:       conf.TaskAccessCacheTTL,
+		Reconciler:         reconciler,
+	}
+	if len(conf.KafkaBrokers) > 0 {
+		events := document.NewKafkaPublisher(conf.KafkaBrokers, conf.KafkaDocumentTopic)
+		defer events.Close()
+		deps.Events = events
+	}
+	if scanner := newScanner(conf, logger); scanner != nil {
+		scanWorker := document.NewScanWorker(repo, storage, keys, scanner, conf.ScanRetryInterval)
+		go scanWorker.Run(ctx)
+		deps.Scans = scanWorker
+	}
+
+	service := document.NewService(deps)
 	authorizer := document.NewAuthorizer([]byte(conf.JWTSecret), redisClient)
 	grpcHandler := document.NewGrpcHandler(service, conf.MaxFileSizeBytes, authorizer)
 
 	httpMux := http.NewServeMux()
+	if storageHandler != nil {
+		httpMux.Handle(document.FilesystemURLPrefix, storageHandler)
+	}
 	httpServer := &http.Server{
 		Addr:    ":" + pickPort(conf.HTTPPort, "8082"),
 		Handler: applyHTTPMiddleware(httpMux),
@@ -127,3 +206,45 @@ func pickPort(value, fallback string) string {
 	}
 	return value
 }
+
+// newStorage открывает хранилище, выбранное STORAGE_BACKEND. Для файлового хранилища
+// возвращается и обработчик presigned ссылок.
+func newStorage(conf appcfg.Config) (document.ObjectStorage, http.Handler, error) {
+	switch conf.StorageBackend {
+	case "minio":
+		storage, err := document.NewMinioStorage(
+			conf.MinioEndpoint,
+			conf.MinioAccessKey,
+			conf.MinioSecretKey,
+			conf.MinioUseSSL,
+			conf.MinioBucket,
+			conf.MinioPublicURL,
+		)
+		return storage, nil, err
+	case "filesystem":
+		return document.NewFilesystemStorage(
+			conf.StorageFSRoot,
+			conf.StorageFSPublicURL,
+			[]byte(conf.StorageSigningKey),
+			conf.MaxFileSizeBytes,
+		)
+	default:
+		return nil, nil, fmt.Errorf("unknown STORAGE_BACKEND %q: expected minio or filesystem", conf.StorageBackend)
+	}
+}
+
+func newScanner(conf appcfg.Config, logger *log.Logger) document.Scanner {
+	switch conf.Scanner {
+	case "clamd":
+		logger.Printf("malware scanning via clamd at %s", conf.ClamdAddr)
+		return document.NewClamdScanner(conf.ClamdAddr, conf.ScanTimeout)
+	case "stub":
+		logger.Printf("malware scanning via stub scanner (EICAR only)")
+		return document.NewStubScanner()
+	case "none":
+		return nil
+	default:
+		logger.Fatalf("unknown SCANNER %q: expected none, stub or clamd", conf.Scanner)
+		return nil
+	}
+}
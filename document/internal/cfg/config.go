@@ -4,25 +4,59 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	HTTPPort         string
-	GRPCPort         string
-	MongoURI         string
-	MongoDatabase    string
-	MongoCollection  string
-	MinioEndpoint    string
-	MinioAccessKey   string
-	MinioSecretKey   string
-	MinioUseSSL      bool
-	MinioBucket      string
-	MaxFileSizeBytes int64
-	JWTSecret        string
-	RedisAddr        string
-	RedisPassword    string
+	HTTPPort                string
+	GRPCPort                string
+	MongoURI                string
+	MongoDatabase           string
+	MongoCollection         string
+	MongoVersionsCollection string
+	MongoBlobsCollection    string
+	MinioEndpoint           string
+	MinioAccessKey          string
+	MinioSecretKey          string
+	MinioUseSSL             bool
+	MinioBucket             string
+	MinioPublicURL          string
+	StorageBackend          string
+	StorageFSRoot           string
+	StorageFSPublicURL      string
+	StorageSigningKey       string
+	EncryptionKeys          string
+	EncryptionKeyFile       string
+	EncryptionActiveKey     string
+	PresignExpiry           time.Duration
+	MaxFileSizeBytes        int64
+	JWTSecret               string
+	RedisAddr               string
+	RedisPassword           string
+	AllowedExtensions       []string
+	AllowedMimeTypes        []string
+	Scanner                 string
+	ClamdAddr               string
+	ScanTimeout             time.Duration
+	ScanRetryInterval       time.Duration
+	TaskGRPCAddr            string
+	TaskRPCTimeout          time.Duration
+	AttachableTaskStatuses  []string
+	AuthGRPCAddr            string
+	TaskAccessCacheTTL      time.Duration
+	ReconcileInterval       time.Duration
+	ReconcileGracePeriod    time.Duration
+	ReconcileDeleteOrphans  bool
+	TrashRetention          time.Duration
+	TrashPurgeInterval      time.Duration
+	UploadSpoolDir          string
+	ScrubInterval           time.Duration
+	ScrubReverifyAfter      time.Duration
+	KafkaBrokers            []string
+	KafkaDocumentTopic      string
 }
 
 func LoadConfig() Config {
@@ -40,15 +74,58 @@ func LoadConfig() Config {
 		MinioAccessKey:  os.Getenv("MINIO_ACCESS_KEY"),
 		MinioSecretKey:  os.Getenv("MINIO_SECRET_KEY"),
 		MinioBucket:     os.Getenv("MINIO_BUCKET"),
+		MinioPublicURL:  os.Getenv("MINIO_PUBLIC_ENDPOINT"),
 		JWTSecret:       os.Getenv("JWT_SECRET"),
 		RedisAddr:       os.Getenv("REDIS_ADDR"),
 		RedisPassword:   os.Getenv("REDIS_PASSWORD"),
 	}
 
+	cfg.MongoVersionsCollection = os.Getenv("MONGODB_VERSIONS_COLLECTION")
+	if cfg.MongoVersionsCollection == "" {
+		cfg.MongoVersionsCollection = "document_versions"
+	}
+	cfg.MongoBlobsCollection = os.Getenv("MONGODB_BLOBS_COLLECTION")
+	if cfg.MongoBlobsCollection == "" {
+		cfg.MongoBlobsCollection = "document_blobs"
+	}
+	cfg.UploadSpoolDir = os.Getenv("UPLOAD_SPOOL_DIR")
+
+	// События документов; без KAFKA_BROKERS не публикуются
+	cfg.KafkaBrokers = splitList(os.Getenv("KAFKA_BROKERS"))
+	cfg.KafkaDocumentTopic = os.Getenv("KAFKA_DOCUMENT_TOPIC")
+	if cfg.KafkaDocumentTopic == "" {
+		cfg.KafkaDocumentTopic = "document-events"
+	}
+
 	if os.Getenv("MINIO_USE_SSL") == "true" || os.Getenv("MINIO_USE_SSL") == "1" {
 		cfg.MinioUseSSL = true
 	}
 
+	// minio или filesystem
+	cfg.StorageBackend = strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND")))
+	if cfg.StorageBackend == "" {
+		cfg.StorageBackend = "minio"
+	}
+	cfg.StorageFSRoot = os.Getenv("STORAGE_FS_ROOT")
+	if cfg.StorageFSRoot == "" {
+		cfg.StorageFSRoot = "./data/objects"
+	}
+	cfg.StorageFSPublicURL = os.Getenv("STORAGE_FS_PUBLIC_URL")
+	if cfg.StorageFSPublicURL == "" {
+		cfg.StorageFSPublicURL = "http://localhost:8082"
+	}
+	// Ключ подписи ссылок файлового хранилища; по умолчанию JWT_SECRET
+	cfg.StorageSigningKey = os.Getenv("STORAGE_SIGNING_KEY")
+	if cfg.StorageSigningKey == "" {
+		cfg.StorageSigningKey = cfg.JWTSecret
+	}
+
+	// Мастер-ключи в формате id:base64 через запятую и/или файл с ключом на строку.
+	// Без ключей новые файлы хранятся незашифрованными.
+	cfg.EncryptionKeys = os.Getenv("ENCRYPTION_KEYS")
+	cfg.EncryptionKeyFile = os.Getenv("ENCRYPTION_KEY_FILE")
+	cfg.EncryptionActiveKey = os.Getenv("ENCRYPTION_ACTIVE_KEY")
+
 	if maxStr := os.Getenv("MAX_FILE_SIZE"); maxStr != "" {
 		if v, err := strconv.ParseInt(maxStr, 10, 64); err == nil {
 			cfg.MaxFileSizeBytes = v
@@ -58,5 +135,82 @@ func LoadConfig() Config {
 		cfg.MaxFileSizeBytes = 10 * 1024 * 1024
 	}
 
+	cfg.PresignExpiry = 15 * time.Minute
+	if expiryStr := os.Getenv("PRESIGN_EXPIRY"); expiryStr != "" {
+		if v, err := time.ParseDuration(expiryStr); err == nil && v > 0 {
+			cfg.PresignExpiry = v
+		}
+	}
+
+	cfg.AllowedExtensions = splitList(os.Getenv("ALLOWED_EXTENSIONS"))
+	cfg.AllowedMimeTypes = splitList(os.Getenv("ALLOWED_MIME_TYPES"))
+
+	// none - проверка выключена, stub - только тестовая строка EICAR, clamd - ClamAV
+	cfg.Scanner = strings.ToLower(os.Getenv("SCANNER"))
+	if cfg.Scanner == "" {
+		cfg.Scanner = "none"
+	}
+	cfg.ClamdAddr = os.Getenv("CLAMD_ADDR")
+	if cfg.ClamdAddr == "" {
+		cfg.ClamdAddr = "localhost:3310"
+	}
+	cfg.ScanTimeout = durationEnv("SCAN_TIMEOUT", 2*time.Minute)
+	cfg.ScanRetryInterval = durationEnv("SCAN_RETRY_INTERVAL", time.Minute)
+
+	cfg.TaskGRPCAddr = os.Getenv("TASK_GRPC_ADDR")
+	if cfg.TaskGRPCAddr == "" {
+		cfg.TaskGRPCAddr = "localhost:9091"
+	}
+	cfg.TaskRPCTimeout = durationEnv("TASK_RPC_TIMEOUT", 5*time.Second)
+	cfg.AttachableTaskStatuses = splitList(os.Getenv("ATTACHABLE_TASK_STATUSES"))
+	cfg.TaskAccessCacheTTL = durationEnv("TASK_ACCESS_CACHE_TTL", time.Minute)
+
+	cfg.AuthGRPCAddr = os.Getenv("AUTH_GRPC_ADDR")
+	if cfg.AuthGRPCAddr == "" {
+		cfg.AuthGRPCAddr = "localhost:9090"
+	}
+
+	// 0 отключает сверку по расписанию, ручной запуск через RPC остаётся
+	cfg.ReconcileInterval = 24 * time.Hour
+	if value := os.Getenv("RECONCILE_INTERVAL"); value != "" {
+		if v, err := time.ParseDuration(value); err == nil && v >= 0 {
+			cfg.ReconcileInterval = v
+		}
+	}
+	cfg.ReconcileGracePeriod = durationEnv("RECONCILE_GRACE_PERIOD", 24*time.Hour)
+	if os.Getenv("RECONCILE_DELETE_ORPHANS") == "true" || os.Getenv("RECONCILE_DELETE_ORPHANS") == "1" {
+		cfg.ReconcileDeleteOrphans = true
+	}
+
+	cfg.TrashRetention = durationEnv("TRASH_RETENTION", 30*24*time.Hour)
+	cfg.TrashPurgeInterval = durationEnv("TRASH_PURGE_INTERVAL", time.Hour)
+
+	cfg.ScrubInterval = time.Hour
+	if value := os.Getenv("SCRUB_INTERVAL"); value != "" {
+		if v, err := time.ParseDuration(value); err == nil && v >= 0 {
+			cfg.ScrubInterval = v
+		}
+	}
+	cfg.ScrubReverifyAfter = durationEnv("SCRUB_REVERIFY_AFTER", 7*24*time.Hour)
+
 	return cfg
 }
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if v, err := time.ParseDuration(value); err == nil && v > 0 {
+			return v
+		}
+	}
+	return def
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,329 @@
+package document
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Blob - содержимое, хранящееся в MinIO под ключом своей SHA-256.
+// RefCount - число загрузок (версий документов), ссылающихся на содержимое;
+// восстановленные версии новых ссылок не добавляют.
+type Blob struct {
+	Checksum  string    `bson:"_id"`
+	ObjectKey string    `bson:"object_key"`
+	Size      int64     `bson:"size"`
+	RefCount  int64     `bson:"ref_count"`
+	Stored    bool      `bson:"stored"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	// DeletingAt задан, пока объект удаляется из хранилища
+	DeletingAt *time.Time `bson:"deleting_at,omitempty"`
+	// EncryptionKey задан, если объект зашифрован; копия хранится в документах и версиях
+	EncryptionKey *WrappedKey `bson:"encryption_key,omitempty"`
+}
+
+// ErrBlobBusy - содержимое с такой контрольной суммой сейчас удаляется
+var ErrBlobBusy = errors.New("stored content is being deleted, retry later")
+
+const (
+	blobAcquireAttempts = 5
+	// blobLockTimeout - блокировка удаления старше этого считается оставшейся после сбоя
+	blobLockTimeout = 10 * time.Minute
+)
+
+func (r *mongoRepository) AcquireBlob(ctx context.Context, checksum, objectKey string, size int64, key *WrappedKey) (Blob, error) {
+	now := time.Now()
+	onInsert := bson.M{
+		"object_key": objectKey,
+		"size":       size,
+		"stored":     false,
+		"created_at": now,
+	}
+	if key != nil {
+		onInsert["encryption_key"] = key
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var blob Blob
+	err := r.blobs.FindOneAndUpdate(ctx,
+		bson.M{"_id": checksum, "deleting_at": bson.M{"$exists": false}},
+		bson.M{
+			"$inc":         bson.M{"ref_count": 1},
+			"$set":         bson.M{"updated_at": now},
+			"$setOnInsert": onInsert,
+		},
+		opts,
+	).Decode(&blob)
+	// Запись есть, но не подошла под фильтр: содержимое удаляется
+	if mongo.IsDuplicateKeyError(err) {
+		return Blob{}, ErrBlobBusy
+	}
+	return blob, err
+}
+
+func (r *mongoRepository) FindBlob(ctx context.Context, checksum string) (Blob, error) {
+	var blob Blob
+	err := r.blobs.FindOne(ctx, bson.M{"_id": checksum}).Decode(&blob)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Blob{}, ErrNotFound
+	}
+	return blob, err
+}
+
+func (r *mongoRepository) SetBlobStored(ctx context.Context, checksum string, stored bool) error {
+	_, err := r.blobs.UpdateOne(ctx,
+		bson.M{"_id": checksum},
+		bson.M{"$set": bson.M{"stored": stored, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *mongoRepository) ReleaseBlob(ctx context.Context, checksum string) (int64, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var blob Blob
+	err := r.blobs.FindOneAndUpdate(ctx,
+		bson.M{"_id": checksum, "ref_count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"ref_count": -1}, "$set": bson.M{"updated_at": time.Now()}},
+		opts,
+	).Decode(&blob)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return blob.RefCount, nil
+}
+
+func (r *mongoRepository) LockUnusedBlob(ctx context.Context, checksum string) (bool, error) {
+	res, err := r.blobs.UpdateOne(ctx,
+		bson.M{"_id": checksum, "ref_count": bson.M{"$lte": 0}, "deleting_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deleting_at": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (r *mongoRepository) LockIdleBlob(ctx context.Context, checksum string, idleBefore time.Time) (bool, error) {
+	now := time.Now()
+	res, err := r.blobs.UpdateOne(ctx,
+		bson.M{"_id": checksum, "updated_at": bson.M{"$lt": idleBefore}, "deleting_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deleting_at": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	if res.ModifiedCount > 0 {
+		return true, nil
+	}
+
+	// Записи нет (например, после сбоя): создаём её сразу заблокированной,
+	// чтобы параллельная загрузка не записала объект, который сейчас будет удалён
+	_, err = r.blobs.InsertOne(ctx, Blob{
+		Checksum:   checksum,
+		ObjectKey:  BlobObjectKey(checksum),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		DeletingAt: &now,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *mongoRepository) DeleteBlob(ctx context.Context, checksum string) error {
+	_, err := r.blobs.DeleteOne(ctx, bson.M{"_id": checksum, "deleting_at": bson.M{"$exists": true}})
+	return err
+}
+
+func (r *mongoRepository) ClearStaleBlobLock(ctx context.Context, checksum string, before time.Time) error {
+	_, err := r.blobs.DeleteOne(ctx, bson.M{"_id": checksum, "deleting_at": bson.M{"$lt": before}})
+	return err
+}
+
+// blobStore ведёт подсчёт ссылок на содержимое и удаляет объект, когда уходит последняя ссылка
+type blobStore struct {
+	repo    Repository
+	storage ObjectStorage
+	keys    *Keyring
+}
+
+// acquire добавляет ссылку на содержимое, дожидаясь окончания его удаления, если оно идёт.
+// Новое содержимое при включённом шифровании получает свой ключ данных; уже хранящееся
+// остаётся в том виде, в каком было записано.
+func (b blobStore) acquire(ctx context.Context, checksum string, size int64) (Blob, error) {
+	key, err := b.keys.NewDataKey()
+	if err != nil {
+		return Blob{}, err
+	}
+	for attempt := 1; ; attempt++ {
+		blob, err := b.repo.AcquireBlob(ctx, checksum, BlobObjectKey(checksum), size, key)
+		if !errors.Is(err, ErrBlobBusy) || attempt == blobAcquireAttempts {
+			return blob, err
+		}
+		// Блокировка могла остаться после сбоя во время удаления
+		if err := b.repo.ClearStaleBlobLock(ctx, checksum, time.Now().Add(-blobLockTimeout)); err != nil {
+			return Blob{}, err
+		}
+		select {
+		case <-ctx.Done():
+			return Blob{}, ctx.Err()
+		case <-time.After(time.Duration(attempt) * 200 * time.Millisecond):
+		}
+	}
+}
+
+// release освобождает ссылку на объект. Объекты, загруженные до дедупликации,
+// принадлежат одному документу и удаляются, если на них больше нет записей.
+func (b blobStore) release(ctx context.Context, objectKey string) error {
+	checksum, ok := blobChecksum(objectKey)
+	if !ok {
+		referenced, err := b.repo.IsObjectReferenced(ctx, objectKey)
+		if err != nil || referenced {
+			return err
+		}
+		return deleteObject(ctx, b.storage, objectKey)
+	}
+
+	remaining, err := b.repo.ReleaseBlob(ctx, checksum)
+	if err != nil || remaining > 0 {
+		return err
+	}
+	locked, err := b.repo.LockUnusedBlob(ctx, checksum)
+	if err != nil || !locked {
+		return err
+	}
+	return b.deleteLocked(ctx, checksum, objectKey)
+}
+
+// heldObjects возвращает объекты, ссылки на которые держат удаляемые документ (docObject,
+// пустой - удаляется только версия) и версии. Ссылку на содержимое держит каждая загруженная
+// версия; восстановленные версии и документы без истории ссылаются на объекты,
+// загруженные до дедупликации.
+func heldObjects(docObject string, versions []Version) []string {
+	var objectKeys []string
+	legacy := map[string]bool{}
+	if _, ok := blobChecksum(docObject); !ok && docObject != "" {
+		legacy[docObject] = true
+	}
+	for _, v := range versions {
+		if _, ok := blobChecksum(v.MinioObject); !ok {
+			legacy[v.MinioObject] = true
+		} else if v.RestoredFrom == 0 {
+			objectKeys = append(objectKeys, v.MinioObject)
+		}
+	}
+	for objectKey := range legacy {
+		objectKeys = append(objectKeys, objectKey)
+	}
+	return objectKeys
+}
+
+// deleteLocked удаляет объект заблокированного содержимого. Запись удаляется в любом случае:
+// если объект удалить не удалось, его подберёт сверка хранилища.
+func (b blobStore) deleteLocked(ctx context.Context, checksum, objectKey string) error {
+	deleteErr := deleteObject(ctx, b.storage, objectKey)
+	if err := b.repo.DeleteBlob(ctx, checksum); err != nil {
+		return err
+	}
+	return deleteErr
+}
+
+// storeContent сохраняет поток во временный файл, считая SHA-256, и кладёт содержимое
+// в хранилище под ключом контрольной суммы. Уже хранящееся содержимое повторно не записывается.
+// Полученную ссылку освобождает releaseObjects.
+func (s *service) storeContent(ctx context.Context, contentType string, content io.Reader) (Blob, error) {
+	spool, err := os.CreateTemp(s.spoolDir, "upload-*")
+	if err != nil {
+		return Blob{}, err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hasher), content)
+	if err != nil {
+		return Blob{}, err
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+
+	blob, err := s.blobs.acquire(ctx, checksum, size)
+	if err != nil {
+		return Blob{}, err
+	}
+	if !blob.Stored {
+		if _, err = spool.Seek(0, io.SeekStart); err == nil {
+			err = putContent(ctx, s.storage, s.keys, blob, contentType, spool, size)
+		}
+		if err == nil {
+			err = s.repo.SetBlobStored(ctx, checksum, true)
+		}
+		if err != nil {
+			s.releaseObjects(blob.ObjectKey)
+			return Blob{}, err
+		}
+	}
+	return blob, nil
+}
+
+// adoptUpload переносит объект, загруженный по presigned URL, под ключ контрольной суммы.
+// Если такое содержимое уже хранится, копирование не выполняется. Временный объект удаляется.
+func (s *service) adoptUpload(ctx context.Context, uploadKey, checksum string, size int64) (Blob, error) {
+	blob, err := s.blobs.acquire(ctx, checksum, size)
+	if err != nil {
+		return Blob{}, err
+	}
+	if !blob.Stored {
+		err = s.copyUpload(ctx, uploadKey, blob, size)
+		if err == nil {
+			err = s.repo.SetBlobStored(ctx, checksum, true)
+		}
+		if err != nil {
+			s.releaseObjects(blob.ObjectKey)
+			return Blob{}, err
+		}
+	}
+	s.removeObjects(uploadKey)
+	return blob, nil
+}
+
+// copyUpload копирует объект загрузки внутри хранилища; зашифрованное содержимое
+// приходится перечитать через сервис
+func (s *service) copyUpload(ctx context.Context, uploadKey string, blob Blob, size int64) error {
+	if blob.EncryptionKey == nil {
+		return s.storage.Copy(ctx, uploadKey, blob.ObjectKey)
+	}
+	reader, _, err := s.storage.Get(ctx, uploadKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return putContent(ctx, s.storage, s.keys, blob, "", reader, size)
+}
+
+// releaseObjects в фоне освобождает ссылки на содержимое
+func (s *service) releaseObjects(objectKeys ...string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		for _, objectKey := range objectKeys {
+			if err := s.blobs.release(ctx, objectKey); err != nil {
+				log.Printf("release object %s: %v", objectKey, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,446 @@
+package document
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WrappedKey - ключ данных, зашифрованный мастер-ключом KeyID
+type WrappedKey struct {
+	KeyID string `bson:"key_id" json:"-"`
+	// Key - nonce и шифротекст ключа данных
+	Key []byte `bson:"key" json:"-"`
+}
+
+var (
+	ErrUnknownMasterKey = errors.New("encryption: unknown master key")
+	ErrDecrypt          = errors.New("encryption: content is corrupted or the key is wrong")
+	// ErrEncryptedContent - зашифрованное содержимое нельзя отдать по presigned ссылке
+	ErrEncryptedContent = errors.New("document is encrypted at rest: download it through the service")
+)
+
+const (
+	masterKeySize = 32
+	dataKeySize   = 32
+	// encryptChunkSize - размер открытого текста в одном блоке AES-GCM
+	encryptChunkSize = 64 * 1024
+	encryptMagic     = "TSE1"
+)
+
+// Keyring хранит мастер-ключи. Новые ключи данных оборачиваются активным ключом,
+// старые ключи нужны, чтобы развернуть ранее обёрнутые ключи до ротации.
+// nil Keyring означает, что шифрование выключено.
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+func NewKeyring(keys map[string][]byte, active string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	ring := &Keyring{keys: make(map[string]cipher.AEAD, len(keys)), active: active}
+	for id, key := range keys {
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("encryption: master key %q must be %d bytes", id, masterKeySize)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[id] = aead
+	}
+	if _, ok := ring.keys[active]; !ok {
+		return nil, fmt.Errorf("%w: active key %q", ErrUnknownMasterKey, active)
+	}
+	return ring, nil
+}
+
+// ParseMasterKeys разбирает список "id:base64,id:base64". Порядок сохраняется
+// в ids, чтобы активным по умолчанию можно было сделать последний ключ.
+func ParseMasterKeys(spec string) (keys map[string][]byte, ids []string, err error) {
+	keys = make(map[string][]byte)
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(item, ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, nil, fmt.Errorf("encryption: master key entry must be id:base64")
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, nil, fmt.Errorf("encryption: master key %q: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, nil, fmt.Errorf("encryption: duplicate master key %q", id)
+		}
+		keys[id] = key
+		ids = append(ids, id)
+	}
+	return keys, ids, nil
+}
+
+// LoadMasterKeys собирает мастер-ключи из строки и файла (по ключу на строку в формате id:base64)
+// и создаёт Keyring. Пустой active - последний указанный ключ.
+func LoadMasterKeys(spec, file, active string) (*Keyring, error) {
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		var lines []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		spec = strings.Join(append([]string{spec}, lines...), "\n")
+	}
+
+	keys, ids, err := ParseMasterKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	if active == "" && len(ids) > 0 {
+		active = ids[len(ids)-1]
+	}
+	return NewKeyring(keys, active)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ActiveKeyID возвращает id ключа, которым оборачиваются новые ключи данных
+func (k *Keyring) ActiveKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.active
+}
+
+// NewDataKey создаёт случайный ключ данных и оборачивает его активным мастер-ключом
+func (k *Keyring) NewDataKey() (*WrappedKey, error) {
+	if k == nil {
+		return nil, nil
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	return k.wrap(dataKey)
+}
+
+func (k *Keyring) wrap(dataKey []byte) (*WrappedKey, error) {
+	aead := k.keys[k.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// id ключа входит в AAD: обёрнутый ключ нельзя выдать за обёрнутый другим мастер-ключом
+	sealed := aead.Seal(nonce, nonce, dataKey, []byte(k.active))
+	return &WrappedKey{KeyID: k.active, Key: sealed}, nil
+}
+
+// Unwrap разворачивает ключ данных
+func (k *Keyring) Unwrap(wrapped WrappedKey) ([]byte, error) {
+	if k == nil {
+		return nil, fmt.Errorf("%w: %q (encryption is not configured)", ErrUnknownMasterKey, wrapped.KeyID)
+	}
+	aead, ok := k.keys[wrapped.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMasterKey, wrapped.KeyID)
+	}
+	if len(wrapped.Key) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, sealed := wrapped.Key[:aead.NonceSize()], wrapped.Key[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(wrapped.KeyID))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return dataKey, nil
+}
+
+// Rewrap переоборачивает ключ данных активным мастер-ключом; содержимое не перешифровывается.
+// false - ключ уже обёрнут активным ключом.
+func (k *Keyring) Rewrap(wrapped WrappedKey) (WrappedKey, bool, error) {
+	if wrapped.KeyID == k.active {
+		return wrapped, false, nil
+	}
+	dataKey, err := k.Unwrap(wrapped)
+	if err != nil {
+		return WrappedKey{}, false, err
+	}
+	rewrapped, err := k.wrap(dataKey)
+	if err != nil {
+		return WrappedKey{}, false, err
+	}
+	return *rewrapped, true, nil
+}
+
+// EncryptedSize возвращает размер зашифрованного объекта для открытого текста размера size
+func EncryptedSize(size int64) int64 {
+	chunks := size/encryptChunkSize + 1
+	return int64(len(encryptMagic)) + size + chunks*16
+}
+
+// PlaintextSize - обратная к EncryptedSize
+func PlaintextSize(size int64) int64 {
+	size -= int64(len(encryptMagic))
+	full := size / (encryptChunkSize + 16)
+	return size - (full+1)*16
+}
+
+// chunkNonce: номер блока и признак последнего блока, чтобы нельзя было
+// переставить или отрезать блоки. Ключ данных шифрует только одно содержимое,
+// поэтому детерминированный nonce безопасен.
+func chunkNonce(nonce []byte, index uint64, last bool) {
+	clear(nonce)
+	binary.BigEndian.PutUint64(nonce[:8], index)
+	if last {
+		nonce[11] = 1
+	}
+}
+
+// encryptingReader шифрует поток блоками: все блоки, кроме последнего, содержат
+// ровно encryptChunkSize байт открытого текста, последний - меньше (возможно, 0)
+type encryptingReader struct {
+	src   io.Reader
+	aead  cipher.AEAD
+	plain []byte
+	out   []byte
+	nonce []byte
+	index uint64
+	done  bool
+}
+
+func newEncryptingReader(src io.Reader, dataKey []byte) (io.Reader, error) {
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingReader{
+		src:   src,
+		aead:  aead,
+		plain: make([]byte, encryptChunkSize),
+		nonce: make([]byte, aead.NonceSize()),
+		out:   []byte(encryptMagic),
+	}, nil
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.src, r.plain)
+		last := false
+		switch {
+		case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+			last = true
+		case err != nil:
+			return 0, err
+		}
+		chunkNonce(r.nonce, r.index, last)
+		r.out = r.aead.Seal(r.out[:0], r.nonce, r.plain[:n], nil)
+		r.index++
+		r.done = last
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// decryptingReader расшифровывает поток encryptingReader и проверяет, что он не обрезан
+type decryptingReader struct {
+	src    io.ReadCloser
+	aead   cipher.AEAD
+	sealed []byte
+	out    []byte
+	nonce  []byte
+	index  uint64
+	header bool
+	done   bool
+}
+
+func newDecryptingReader(src io.ReadCloser, dataKey []byte) (io.ReadCloser, error) {
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		src:    src,
+		aead:   aead,
+		sealed: make([]byte, encryptChunkSize+aead.Overhead()),
+		nonce:  make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if !r.header {
+		magic := make([]byte, len(encryptMagic))
+		if _, err := io.ReadFull(r.src, magic); err != nil || string(magic) != encryptMagic {
+			return 0, ErrDecrypt
+		}
+		r.header = true
+	}
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.src, r.sealed)
+		last := false
+		switch {
+		case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+			last = true
+		case err != nil:
+			return 0, err
+		}
+		chunkNonce(r.nonce, r.index, last)
+		plain, err := r.aead.Open(r.sealed[:0], r.nonce, r.sealed[:n], nil)
+		if err != nil {
+			return 0, ErrDecrypt
+		}
+		r.out = plain
+		r.index++
+		r.done = last
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.src.Close()
+}
+
+// openObject открывает объект хранилища; если у содержимого есть ключ, поток расшифровывается.
+// Возвращаемый размер - размер открытого текста.
+func openObject(ctx context.Context, storage ObjectStorage, keys *Keyring, objectKey string, key *WrappedKey) (io.ReadCloser, int64, error) {
+	reader, size, err := storage.Get(ctx, objectKey)
+	if err != nil || key == nil {
+		return reader, size, err
+	}
+	dataKey, err := keys.Unwrap(*key)
+	if err != nil {
+		reader.Close()
+		return nil, 0, err
+	}
+	decrypted, err := newDecryptingReader(reader, dataKey)
+	if err != nil {
+		reader.Close()
+		return nil, 0, err
+	}
+	return decrypted, PlaintextSize(size), nil
+}
+
+// objectEncryptionKey возвращает ключ содержимого по ключу объекта. Шифруется только
+// содержимое, адресуемое по контрольной сумме; остальные объекты хранятся открыто.
+func objectEncryptionKey(ctx context.Context, repo Repository, objectKey string) (*WrappedKey, error) {
+	checksum, ok := blobChecksum(objectKey)
+	if !ok {
+		return nil, nil
+	}
+	blob, err := repo.FindBlob(ctx, checksum)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return blob.EncryptionKey, nil
+}
+
+// putContent записывает содержимое под ключом объекта, шифруя его ключом содержимого, если он есть
+func putContent(ctx context.Context, storage ObjectStorage, keys *Keyring, blob Blob, contentType string, reader io.Reader, size int64) error {
+	if blob.EncryptionKey == nil {
+		return storage.Put(ctx, blob.ObjectKey, contentType, reader, size)
+	}
+	dataKey, err := keys.Unwrap(*blob.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	encrypted, err := newEncryptingReader(reader, dataKey)
+	if err != nil {
+		return err
+	}
+	return storage.Put(ctx, blob.ObjectKey, "application/octet-stream", encrypted, EncryptedSize(size))
+}
+
+func (r *mongoRepository) RewrapEncryptionKeys(ctx context.Context, keys *Keyring) (int64, error) {
+	if keys == nil {
+		return 0, errors.New("encryption: no master keys configured")
+	}
+	filter := bson.M{
+		"encryption_key":        bson.M{"$exists": true},
+		"encryption_key.key_id": bson.M{"$ne": keys.ActiveKeyID()},
+	}
+	opts := options.Find().SetProjection(bson.M{"encryption_key": 1})
+
+	var updated int64
+	for _, collection := range []*mongo.Collection{r.blobs, r.collection, r.versions} {
+		cursor, err := collection.Find(ctx, filter, opts)
+		if err != nil {
+			return updated, err
+		}
+		for cursor.Next(ctx) {
+			var row struct {
+				ID            any        `bson:"_id"`
+				EncryptionKey WrappedKey `bson:"encryption_key"`
+			}
+			if err := cursor.Decode(&row); err != nil {
+				cursor.Close(ctx)
+				return updated, err
+			}
+			rewrapped, changed, err := keys.Rewrap(row.EncryptionKey)
+			if err != nil {
+				cursor.Close(ctx)
+				return updated, fmt.Errorf("%s %v: %w", collection.Name(), row.ID, err)
+			}
+			if !changed {
+				continue
+			}
+			// Условие на прежний ключ: запись могли обновить параллельно
+			res, err := collection.UpdateOne(ctx,
+				bson.M{"_id": row.ID, "encryption_key.key_id": row.EncryptionKey.KeyID},
+				bson.M{"$set": bson.M{"encryption_key": rewrapped}},
+			)
+			if err != nil {
+				cursor.Close(ctx)
+				return updated, err
+			}
+			updated += res.ModifiedCount
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,174 @@
+package document
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func testMasterKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, masterKeySize)
+}
+
+func testDataKey(t *testing.T) []byte {
+	t.Helper()
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatal(err)
+	}
+	return dataKey
+}
+
+func encrypt(t *testing.T, plain, dataKey []byte) []byte {
+	t.Helper()
+	reader, err := newEncryptingReader(bytes.NewReader(plain), dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Мелкие чтения проверяют выдачу блока по частям
+	sealed, err := io.ReadAll(iotest.HalfReader(reader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+func decrypt(sealed, dataKey []byte) ([]byte, error) {
+	reader, err := newDecryptingReader(io.NopCloser(iotest.HalfReader(bytes.NewReader(sealed))), dataKey)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	sizes := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"one byte", 1},
+		{"chunk minus one", encryptChunkSize - 1},
+		{"exactly one chunk", encryptChunkSize},
+		{"chunk plus one", encryptChunkSize + 1},
+		{"exactly two chunks", 2 * encryptChunkSize},
+		{"two chunks and a tail", 2*encryptChunkSize + 17},
+	}
+	dataKey := testDataKey(t)
+	for _, tt := range sizes {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := make([]byte, tt.size)
+			if _, err := rand.Read(plain); err != nil {
+				t.Fatal(err)
+			}
+			sealed := encrypt(t, plain, dataKey)
+			if got, want := int64(len(sealed)), EncryptedSize(int64(tt.size)); got != want {
+				t.Fatalf("encrypted size = %d, EncryptedSize = %d", got, want)
+			}
+			if got := PlaintextSize(int64(len(sealed))); got != int64(tt.size) {
+				t.Fatalf("PlaintextSize = %d, want %d", got, tt.size)
+			}
+			got, err := decrypt(sealed, dataKey)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatal("decrypted content differs from the original")
+			}
+		})
+	}
+}
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	dataKey := testDataKey(t)
+	plain := bytes.Repeat([]byte("0123456789abcdef"), (2*encryptChunkSize+10)/16)
+	sealed := encrypt(t, plain, dataKey)
+	header := len(encryptMagic)
+	chunk := encryptChunkSize + 16
+
+	tamper := func(fn func(b []byte) []byte) []byte {
+		return fn(append([]byte(nil), sealed...))
+	}
+	tests := []struct {
+		name   string
+		sealed []byte
+		key    []byte
+	}{
+		{"flipped bit in first chunk", tamper(func(b []byte) []byte { b[header+10] ^= 1; return b }), dataKey},
+		{"flipped bit in last chunk", tamper(func(b []byte) []byte { b[len(b)-1] ^= 1; return b }), dataKey},
+		{"last chunk cut off", sealed[:header+2*chunk], dataKey},
+		{"cut inside a chunk", sealed[:header+chunk+100], dataKey},
+		{"chunks swapped", tamper(func(b []byte) []byte {
+			first := append([]byte(nil), b[header:header+chunk]...)
+			copy(b[header:], b[header+chunk:header+2*chunk])
+			copy(b[header+chunk:], first)
+			return b
+		}), dataKey},
+		{"bad header", tamper(func(b []byte) []byte { b[0] = 'X'; return b }), dataKey},
+		{"wrong key", sealed, testDataKey(t)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decrypt(tt.sealed, tt.key); !errors.Is(err, ErrDecrypt) {
+				t.Fatalf("decrypt = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestKeyringRewrap(t *testing.T) {
+	oldRing, err := NewKeyring(map[string][]byte{"k1": testMasterKey(1)}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := oldRing.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey, err := oldRing.Unwrap(*wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := encrypt(t, []byte("encrypted before rotation"), dataKey)
+
+	rotated, err := NewKeyring(map[string][]byte{"k1": testMasterKey(1), "k2": testMasterKey(2)}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrapped, changed, err := rotated.Rewrap(*wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || rewrapped.KeyID != "k2" {
+		t.Fatalf("Rewrap = %q, changed %v; want k2, changed", rewrapped.KeyID, changed)
+	}
+	if _, changed, err := rotated.Rewrap(rewrapped); err != nil || changed {
+		t.Fatalf("second Rewrap changed = %v, err = %v; want unchanged", changed, err)
+	}
+
+	// После ротации старый мастер-ключ больше не нужен
+	newRing, err := NewKeyring(map[string][]byte{"k2": testMasterKey(2)}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := newRing.Unwrap(rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := decrypt(sealed, unwrapped); err != nil || string(plain) != "encrypted before rotation" {
+		t.Fatalf("decrypt with rewrapped key = %q, %v", plain, err)
+	}
+	if _, err := newRing.Unwrap(*wrapped); !errors.Is(err, ErrUnknownMasterKey) {
+		t.Fatalf("Unwrap of key wrapped by removed master key = %v, want ErrUnknownMasterKey", err)
+	}
+
+	// id мастер-ключа входит в AAD: ключ нельзя выдать за обёрнутый другим мастер-ключом
+	relabeled := WrappedKey{KeyID: "k1", Key: rewrapped.Key}
+	if _, err := rotated.Unwrap(relabeled); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Unwrap of relabeled key = %v, want ErrDecrypt", err)
+	}
+}
@@ -0,0 +1,104 @@
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Типы событий документов
+const (
+	EventDocumentCreated      = "document.created"
+	EventDocumentVersionAdded = "document.version_added"
+	EventDocumentDeleted      = "document.deleted"
+	EventDocumentRestored     = "document.restored"
+)
+
+const eventPublishTimeout = 10 * time.Second
+
+// DocumentEvent - событие документа для Kafka. Содержимое файла в событие не попадает.
+type DocumentEvent struct {
+	Type        string    `json:"type"`
+	DocumentID  string    `json:"documentId"`
+	TaskID      string    `json:"taskId"`
+	OwnerID     string    `json:"ownerId"`
+	ActorID     string    `json:"actorId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Version     int       `json:"version"`
+	Checksum    string    `json:"checksum,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// EventPublisher публикует события документов
+type EventPublisher interface {
+	PublishDocumentEvent(ctx context.Context, event DocumentEvent) error
+	Close() error
+}
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(brokers []string, topic string) EventPublisher {
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    topic,
+			Balancer: &kafka.Hash{},
+		},
+	}
+}
+
+// PublishDocumentEvent отправляет событие; ключ сообщения - ID документа,
+// поэтому события одного документа читаются по порядку
+func (p *kafkaPublisher) PublishDocumentEvent(ctx context.Context, event DocumentEvent) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.DocumentID),
+		Value: value,
+		Time:  event.Timestamp,
+	})
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+func newDocumentEvent(eventType string, doc Metadata, actorID string) DocumentEvent {
+	return DocumentEvent{
+		Type:        eventType,
+		DocumentID:  doc.ID.Hex(),
+		TaskID:      doc.TaskID,
+		OwnerID:     doc.OwnerID,
+		ActorID:     actorID,
+		Filename:    doc.Filename,
+		ContentType: doc.ContentType,
+		Size:        doc.Size,
+		Version:     doc.Version(),
+		Checksum:    doc.Checksum,
+		Timestamp:   time.Now(),
+	}
+}
+
+// publish отправляет событие в фоне: ошибка публикации не отменяет изменение документа
+func (s *service) publish(eventType string, doc Metadata, actorID string) {
+	if s.events == nil {
+		return
+	}
+	event := newDocumentEvent(eventType, doc, actorID)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+		defer cancel()
+		if err := s.events.PublishDocumentEvent(ctx, event); err != nil {
+			log.Printf("publish %s for document %s: %v", event.Type, event.DocumentID, err)
+		}
+	}()
+}
@@ -0,0 +1,206 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	textExtractionWorkers = 4
+	textExtractionTimeout = 2 * time.Minute
+	// maxExtractSourceBytes - файлы больше этого размера не разбираются целиком в памяти
+	maxExtractSourceBytes = 64 << 20
+)
+
+// maxIndexedTextBytes ограничивает объём текста, сохраняемого для полнотекстового поиска:
+// документ MongoDB не может быть больше 16 МБ
+const maxIndexedTextBytes = 1 << 20
+
+var errUnsupportedTextFormat = errors.New("text extraction not supported for this format")
+
+// textExtractors - форматы, из которых извлекается текст для поиска по содержимому
+var textExtractors = map[string]func(content []byte) (string, error){
+	".txt":  extractPlainText,
+	".pdf":  extractPDFText,
+	".docx": extractDOCXText,
+}
+
+// ExtractText извлекает текст из содержимого файла по его расширению
+func ExtractText(filename string, content []byte) (string, error) {
+	extract, ok := textExtractors[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return "", errUnsupportedTextFormat
+	}
+
+	text, err := extract(content)
+	if err != nil {
+		return "", err
+	}
+	return truncateText(normalizeText(strings.ToValidUTF8(text, " ")), maxIndexedTextBytes), nil
+}
+
+// SupportsTextExtraction сообщает, извлекается ли текст из файлов с таким именем
+func SupportsTextExtraction(filename string) bool {
+	_, ok := textExtractors[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+func extractPlainText(content []byte) (string, error) {
+	return string(content), nil
+}
+
+func extractPDFText(content []byte) (text string, err error) {
+	// Разбор повреждённых PDF в библиотеке может паниковать
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parse pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(plain, maxIndexedTextBytes))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// extractDOCXText читает word/document.xml: текст лежит в элементах w:t,
+// абзацы (w:p) и переносы (w:br, w:tab) превращаем в пробельные символы
+func extractDOCXText(content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+
+	var body *zip.File
+	for _, f := range archive.File {
+		if f.Name == "word/document.xml" {
+			body = f
+			break
+		}
+	}
+	if body == nil {
+		return "", errors.New("docx: word/document.xml not found")
+	}
+
+	rc, err := body.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var builder strings.Builder
+	decoder := xml.NewDecoder(io.LimitReader(rc, 8*maxIndexedTextBytes))
+	inText := false
+	for builder.Len() < maxIndexedTextBytes {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				builder.WriteByte('\t')
+			case "br":
+				builder.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				builder.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				builder.Write(t)
+			}
+		}
+	}
+	return builder.String(), nil
+}
+
+// normalizeText схлопывает пробельные символы: для поиска и фрагментов форматирование не нужно
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func truncateText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	// Не разрезаем UTF-8 символ посередине
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
+}
+
+// scheduleTextExtraction в фоне извлекает текст текущей версии документа для поиска
+// по содержимому. Для форматов без извлечения текст предыдущей версии стирается.
+func (s *service) scheduleTextExtraction(doc Metadata) {
+	go func() {
+		s.textSlots <- struct{}{}
+		defer func() { <-s.textSlots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), textExtractionTimeout)
+		defer cancel()
+
+		err := s.indexContentText(ctx, doc)
+		if err != nil && !errors.Is(err, ErrVersionConflict) && !errors.Is(err, ErrNotFound) {
+			log.Printf("document %s v%d: text extraction failed: %v", doc.ID.Hex(), doc.Version(), err)
+		}
+	}()
+}
+
+func (s *service) indexContentText(ctx context.Context, doc Metadata) error {
+	if !SupportsTextExtraction(doc.Filename) || doc.Size > maxExtractSourceBytes {
+		return s.repo.SetContentText(ctx, doc.ID, doc.Version(), "")
+	}
+
+	reader, _, err := openObject(ctx, s.storage, s.keys, doc.MinioObject, doc.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxExtractSourceBytes))
+	if err != nil {
+		return err
+	}
+
+	text, err := ExtractText(doc.Filename, content)
+	if err != nil {
+		// Повреждённый файл не должен оставлять в индексе текст прошлой версии
+		_ = s.repo.SetContentText(ctx, doc.ID, doc.Version(), "")
+		return err
+	}
+	return s.repo.SetContentText(ctx, doc.ID, doc.Version(), text)
+}
@@ -0,0 +1,360 @@
+package document
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilesystemURLPrefix - путь HTTP обработчика, раздающего presigned ссылки файлового хранилища
+const FilesystemURLPrefix = "/storage/objects/"
+
+const (
+	fsObjectsDir = "objects"
+	fsTempDir    = "tmp"
+	// fsStaleTemp - временные файлы старше этого остались от прерванных записей
+	fsStaleTemp = 24 * time.Hour
+)
+
+var errInvalidSignature = errors.New("invalid or expired signature")
+
+// fsStorage хранит объекты в локальном каталоге. Файл объекта лежит в каталоге
+// objects/<2 символа>/<2 символа>/ по SHA-256 ключа, имя файла - экранированный ключ.
+// Запись идёт во временный файл и переименовывается, поэтому читатели не видят частичных объектов.
+type fsStorage struct {
+	root      string
+	publicURL string
+	secret    []byte
+	maxSize   int64
+}
+
+// NewFilesystemStorage создаёт файловое хранилище в каталоге root. Presigned ссылки ведут
+// на publicURL + FilesystemURLPrefix и подписываются secret; их обслуживает возвращаемый
+// http.Handler. maxSize ограничивает размер загрузки по ссылке (0 - без ограничения).
+func NewFilesystemStorage(root, publicURL string, secret []byte, maxSize int64) (ObjectStorage, http.Handler, error) {
+	if len(secret) == 0 {
+		return nil, nil, errors.New("filesystem storage: signing secret is required")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dir := range []string{fsObjectsDir, fsTempDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o750); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s := &fsStorage{
+		root:      root,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		secret:    secret,
+		maxSize:   maxSize,
+	}
+	s.removeStaleTemp()
+	return s, http.HandlerFunc(s.serveHTTP), nil
+}
+
+// path возвращает путь файла объекта
+func (s *fsStorage) path(objectKey string) (string, error) {
+	if objectKey == "" || objectKey == "." || objectKey == ".." {
+		return "", ErrInvalidObjectKey
+	}
+	sum := sha256.Sum256([]byte(objectKey))
+	shard := hex.EncodeToString(sum[:2])
+	return filepath.Join(s.root, fsObjectsDir, shard[:2], shard[2:], url.PathEscape(objectKey)), nil
+}
+
+func (s *fsStorage) removeStaleTemp() {
+	entries, err := os.ReadDir(filepath.Join(s.root, fsTempDir))
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-fsStaleTemp)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(filepath.Join(s.root, fsTempDir, entry.Name()))
+		}
+	}
+}
+
+// write атомарно записывает объект: временный файл, fsync, rename.
+// size < 0 - размер заранее неизвестен.
+func (s *fsStorage) write(ctx context.Context, objectKey string, reader io.Reader, size int64) error {
+	target, err := s.path(objectKey)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.root, fsTempDir), "put-*")
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	written, err := io.Copy(tmp, ctxReader{ctx: ctx, reader: reader})
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("filesystem storage: wrote %d bytes, expected %d", written, size)
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// ctxReader прерывает долгую запись при отмене контекста
+type ctxReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+func (s *fsStorage) Put(ctx context.Context, objectKey string, contentType string, reader io.Reader, size int64) error {
+	return s.write(ctx, objectKey, reader, size)
+}
+
+func (s *fsStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	reader, size, err := s.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return s.write(ctx, dstKey, reader, size)
+}
+
+func (s *fsStorage) Delete(ctx context.Context, objectKey string) error {
+	path, err := s.path(objectKey)
+	if err != nil {
+		return err
+	}
+	// Как и в S3, удаление отсутствующего объекта не ошибка
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *fsStorage) Get(ctx context.Context, objectKey string) (io.ReadCloser, int64, error) {
+	path, err := s.path(objectKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+func (s *fsStorage) Stat(ctx context.Context, objectKey string) (int64, error) {
+	path, err := s.path(objectKey)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrObjectNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *fsStorage) PresignPut(ctx context.Context, objectKey string, expiry time.Duration) (string, error) {
+	return s.presign(http.MethodPut, objectKey, expiry, url.Values{})
+}
+
+func (s *fsStorage) PresignGet(ctx context.Context, objectKey string, filename string, contentType string, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("filename", filename)
+	if contentType != "" {
+		params.Set("content_type", contentType)
+	}
+	return s.presign(http.MethodGet, objectKey, expiry, params)
+}
+
+func (s *fsStorage) presign(method, objectKey string, expiry time.Duration, params url.Values) (string, error) {
+	if _, err := s.path(objectKey); err != nil {
+		return "", err
+	}
+	params.Set("expires", strconv.FormatInt(time.Now().Add(expiry).Unix(), 10))
+	params.Set("signature", s.sign(method, objectKey, params))
+	return s.publicURL + FilesystemURLPrefix + escapeObjectKey(objectKey) + "?" + params.Encode(), nil
+}
+
+// sign подписывает метод, ключ и все параметры ссылки, кроме самой подписи
+func (s *fsStorage) sign(method, objectKey string, params url.Values) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, objectKey,
+		params.Get("expires"), params.Get("filename"), params.Get("content_type"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *fsStorage) verify(method, objectKey string, params url.Values) error {
+	expires, err := strconv.ParseInt(params.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return errInvalidSignature
+	}
+	expected := s.sign(method, objectKey, params)
+	if !hmac.Equal([]byte(expected), []byte(params.Get("signature"))) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// escapeObjectKey экранирует сегменты ключа, сохраняя "/"
+func escapeObjectKey(objectKey string) string {
+	segments := strings.Split(objectKey, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// serveHTTP обслуживает presigned ссылки: GET отдаёт объект, PUT записывает его
+func (s *fsStorage) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	objectKey := strings.TrimPrefix(r.URL.Path, FilesystemURLPrefix)
+	params := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if err := s.verify(http.MethodGet, objectKey, params); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		s.serveObject(w, r, objectKey, params)
+	case http.MethodPut:
+		if err := s.verify(http.MethodPut, objectKey, params); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		body := io.Reader(r.Body)
+		if s.maxSize > 0 {
+			body = http.MaxBytesReader(w, r.Body, s.maxSize)
+		}
+		if err := s.write(r.Context(), objectKey, body, r.ContentLength); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "object too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "failed to store object", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *fsStorage) serveObject(w http.ResponseWriter, r *http.Request, objectKey string, params url.Values) {
+	path, err := s.path(objectKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to open object", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "failed to open object", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := params.Get("content_type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if filename := params.Get("filename"); filename != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, EscapeFilename(filename)))
+	}
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+func (s *fsStorage) ListObjects(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return filepath.WalkDir(filepath.Join(s.root, fsObjectsDir), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		objectKey, err := url.PathUnescape(entry.Name())
+		if err != nil || !strings.HasPrefix(objectKey, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// Объект удалили во время обхода
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: objectKey, Size: info.Size(), LastModified: info.ModTime()})
+	})
+}
+
+func (s *fsStorage) Bucket() string {
+	return "filesystem"
+}
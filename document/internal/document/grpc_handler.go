@@ -1,9 +1,12 @@
 package document
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
 	"strings"
+	"time"
 
 	pb "github.com/Oniqq60/task_system_control/gen/proto/document"
 	"github.com/golang-jwt/jwt/v5"
@@ -25,6 +28,9 @@ var (
 	tokenBlacklistPrefix = "auth:token:blacklist:"
 )
 
+// downloadChunkSize - размер одного сообщения при потоковой отдаче файла
+const downloadChunkSize = 64 * 1024
+
 type authClaims struct {
 	UserID string `json:"user_id"`
 	Role   string `json:"role"`
@@ -49,40 +55,68 @@ func (h *GrpcHandler) AddDocument(ctx context.Context, req *pb.AddDocumentReques
 		return nil, err
 	}
 
-	if requester.Role != RoleAdmin && requester.UserID != req.OwnerId {
-		return nil, status.Error(codes.PermissionDenied, "forbidden")
-	}
-
 	content := req.GetFileContent()
 	if len(content) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "empty file content")
 	}
 
 	input := AddDocumentInput{
+		DocumentID:  req.DocumentId,
 		TaskID:      req.TaskId,
 		OwnerID:     req.OwnerId,
 		Filename:    req.Filename,
 		ContentType: req.ContentType,
 		Tags:        req.Tags,
-		Content:     content,
+		Content:     bytes.NewReader(content),
+		Size:        int64(len(content)),
 		MaxSize:     h.maxSize,
 	}
 
-	metadata, _, err := h.service.AddDocument(ctx, input)
+	metadata, err := h.service.AddDocument(ctx, input, requester)
 	if err != nil {
 		return nil, handleServiceErr(err)
 	}
 
-	return &pb.AddDocumentResponse{
-		Id:          metadata.ID.Hex(),
-		Filename:    metadata.Filename,
-		ContentType: metadata.ContentType,
-		Size:        metadata.Size,
-		TaskId:      metadata.TaskID,
-		OwnerId:     metadata.OwnerID,
-		Tags:        metadata.Tags,
-		UploadedAt:  metadata.UploadedAt.Unix(),
-	}, nil
+	return mapAddResponse(metadata), nil
+}
+
+func (h *GrpcHandler) UploadDocument(stream pb.DocumentService_UploadDocumentServer) error {
+	ctx := stream.Context()
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return err
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.InvalidArgument, "empty upload stream")
+		}
+		return err
+	}
+	info := first.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, "first message must contain document info")
+	}
+
+	input := AddDocumentInput{
+		DocumentID:  info.DocumentId,
+		TaskID:      info.TaskId,
+		OwnerID:     info.OwnerId,
+		Filename:    info.Filename,
+		ContentType: info.ContentType,
+		Tags:        info.Tags,
+		Content:     &uploadStreamReader{stream: stream},
+		Size:        -1,
+		MaxSize:     h.maxSize,
+	}
+
+	metadata, err := h.service.AddDocument(ctx, input, requester)
+	if err != nil {
+		return handleServiceErr(err)
+	}
+
+	return stream.SendAndClose(mapAddResponse(metadata))
 }
 
 func (h *GrpcHandler) DeleteDocument(ctx context.Context, req *pb.DeleteDocumentRequest) (*pb.DeleteDocumentResponse, error) {
@@ -102,7 +136,7 @@ func (h *GrpcHandler) GetDocument(ctx context.Context, req *pb.GetDocumentReques
 		return nil, err
 	}
 
-	metadata, content, err := h.service.GetDocument(ctx, req.GetId(), requester)
+	metadata, content, err := h.service.GetDocument(ctx, req.GetId(), int(req.GetVersion()), requester)
 	if err != nil {
 		return nil, handleServiceErr(err)
 	}
@@ -117,9 +151,208 @@ func (h *GrpcHandler) GetDocument(ctx context.Context, req *pb.GetDocumentReques
 		OwnerId:     metadata.OwnerID,
 		Tags:        metadata.Tags,
 		UploadedAt:  metadata.UploadedAt.Unix(),
+		Version:     int32(metadata.Version()),
 	}, nil
 }
 
+func (h *GrpcHandler) DownloadDocument(req *pb.DownloadDocumentRequest, stream pb.DocumentService_DownloadDocumentServer) error {
+	ctx := stream.Context()
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return err
+	}
+
+	metadata, reader, err := h.service.OpenDocument(ctx, req.GetId(), int(req.GetVersion()), requester)
+	if err != nil {
+		return handleServiceErr(err)
+	}
+	defer reader.Close()
+
+	if err := stream.Send(&pb.DownloadDocumentResponse{
+		Payload: &pb.DownloadDocumentResponse_Info{Info: mapDoc(metadata)},
+	}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, readErr := reader.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.DownloadDocumentResponse{
+				Payload: &pb.DownloadDocumentResponse_Chunk{Chunk: buf[:n]},
+			}); err != nil {
+				return err
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				return nil
+			}
+			return handleServiceErr(readErr)
+		}
+	}
+}
+
+func (h *GrpcHandler) CreateUploadURL(ctx context.Context, req *pb.CreateUploadURLRequest) (*pb.CreateUploadURLResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	presigned, objectKey, err := h.service.CreateUploadURL(ctx, UploadURLInput{
+		TaskID:      req.GetTaskId(),
+		OwnerID:     req.GetOwnerId(),
+		Filename:    req.GetFilename(),
+		ContentType: req.GetContentType(),
+	}, requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	return &pb.CreateUploadURLResponse{
+		UploadUrl: presigned.URL,
+		ObjectKey: objectKey,
+		ExpiresAt: presigned.ExpiresAt.Unix(),
+	}, nil
+}
+
+func (h *GrpcHandler) CompleteUpload(ctx context.Context, req *pb.CompleteUploadRequest) (*pb.AddDocumentResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := h.service.CompleteUpload(ctx, CompleteUploadInput{
+		ObjectKey:   req.GetObjectKey(),
+		DocumentID:  req.GetDocumentId(),
+		TaskID:      req.GetTaskId(),
+		OwnerID:     req.GetOwnerId(),
+		Filename:    req.GetFilename(),
+		ContentType: req.GetContentType(),
+		Tags:        req.GetTags(),
+		MaxSize:     h.maxSize,
+	}, requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	return mapAddResponse(metadata), nil
+}
+
+func (h *GrpcHandler) CreateDownloadURL(ctx context.Context, req *pb.CreateDownloadURLRequest) (*pb.CreateDownloadURLResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	presigned, err := h.service.CreateDownloadURL(ctx, req.GetId(), int(req.GetVersion()), requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	return &pb.CreateDownloadURLResponse{
+		DownloadUrl: presigned.URL,
+		ExpiresAt:   presigned.ExpiresAt.Unix(),
+	}, nil
+}
+
+func (h *GrpcHandler) ListVersions(ctx context.Context, req *pb.ListVersionsRequest) (*pb.ListVersionsResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, versions, err := h.service.ListVersions(ctx, req.GetId(), requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	pbVersions := make([]*pb.DocumentVersion, 0, len(versions))
+	for _, v := range versions {
+		pbVersions = append(pbVersions, &pb.DocumentVersion{
+			Version:      int32(v.Version),
+			Filename:     v.Filename,
+			ContentType:  v.ContentType,
+			Size:         v.Size,
+			Checksum:     v.Checksum,
+			UploadedBy:   v.UploadedBy,
+			UploadedAt:   v.UploadedAt.Unix(),
+			RestoredFrom: int32(v.RestoredFrom),
+			ScanStatus:   string(scanStatus(v.ScanStatus)),
+			Corrupted:    v.Corrupted,
+		})
+	}
+
+	return &pb.ListVersionsResponse{
+		CurrentVersion: int32(doc.Version()),
+		Versions:       pbVersions,
+	}, nil
+}
+
+func (h *GrpcHandler) RestoreVersion(ctx context.Context, req *pb.RestoreVersionRequest) (*pb.Document, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := h.service.RestoreVersion(ctx, req.GetId(), int(req.GetVersion()), requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	return mapDoc(doc), nil
+}
+
+func (h *GrpcHandler) SearchDocuments(ctx context.Context, req *pb.SearchDocumentsRequest) (*pb.SearchDocumentsResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	input := SearchInput{
+		Tags:         req.GetTags(),
+		MatchAllTags: req.GetMatchAllTags(),
+		Filename:     req.GetFilename(),
+		ContentType:  req.GetContentType(),
+		TaskID:       req.GetTaskId(),
+		OwnerID:      req.GetOwnerId(),
+		Content:      req.GetContent(),
+		Page:         int(req.GetPage()),
+		PageSize:     int(req.GetPageSize()),
+		SortBy:       req.GetSortBy(),
+		SortDesc:     req.GetSortDesc(),
+	}
+	if req.GetUploadedFrom() > 0 {
+		input.UploadedFrom = time.Unix(req.GetUploadedFrom(), 0)
+	}
+	if req.GetUploadedTo() > 0 {
+		input.UploadedTo = time.Unix(req.GetUploadedTo(), 0)
+	}
+
+	result, err := h.service.SearchDocuments(ctx, input, requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	resp := &pb.SearchDocumentsResponse{
+		Documents: make([]*pb.Document, 0, len(result.Documents)),
+		Total:     result.Total,
+		Page:      int32(result.Page),
+		PageSize:  int32(result.PageSize),
+	}
+	for _, match := range result.Documents {
+		resp.Documents = append(resp.Documents, mapDoc(match.Metadata))
+		if input.Content != "" {
+			resp.Highlights = append(resp.Highlights, &pb.DocumentHighlight{
+				Id:      match.ID.Hex(),
+				Score:   match.Score,
+				Snippet: match.Snippet,
+			})
+		}
+	}
+	return resp, nil
+}
+
 func (h *GrpcHandler) GetDocumentsByTask(ctx context.Context, req *pb.GetDocumentsByTaskRequest) (*pb.GetDocumentsByTaskResponse, error) {
 	requester, err := h.authorize(ctx)
 	if err != nil {
@@ -212,25 +445,152 @@ func (h *GrpcHandler) authorize(ctx context.Context) (Requester, error) {
 	return h.auth.Authorize(ctx, token)
 }
 
+func mapDoc(m Metadata) *pb.Document {
+	doc := &pb.Document{
+		Id:          m.ID.Hex(),
+		Filename:    m.Filename,
+		ContentType: m.ContentType,
+		Size:        m.Size,
+		TaskId:      m.TaskID,
+		OwnerId:     m.OwnerID,
+		Tags:        m.Tags,
+		UploadedAt:  m.UploadedAt.Unix(),
+		Version:     int32(m.Version()),
+		ScanStatus:  string(scanStatus(m.ScanStatus)),
+		DeletedBy:   m.DeletedBy,
+		Checksum:    m.Checksum,
+		Corrupted:   m.Corrupted,
+	}
+	if m.DeletedAt != nil {
+		doc.DeletedAt = m.DeletedAt.Unix()
+	}
+	if m.LastVerifiedAt != nil {
+		doc.LastVerifiedAt = m.LastVerifiedAt.Unix()
+	}
+	return doc
+}
+
+// scanStatus показывает клиентам документы без проверки (загруженные до её появления) как clean
+func scanStatus(status ScanStatus) ScanStatus {
+	if status == "" {
+		return ScanClean
+	}
+	return status
+}
+
 func mapDocs(metas []Metadata) []*pb.Document {
 	pbDocs := make([]*pb.Document, 0, len(metas))
 	for _, m := range metas {
-		pbDocs = append(pbDocs, &pb.Document{
-			Id:          m.ID.Hex(),
-			Filename:    m.Filename,
-			ContentType: m.ContentType,
-			Size:        m.Size,
-			TaskId:      m.TaskID,
-			OwnerId:     m.OwnerID,
-			Tags:        m.Tags,
-			UploadedAt:  m.UploadedAt.Unix(),
-		})
+		pbDocs = append(pbDocs, mapDoc(m))
 	}
 	return pbDocs
 }
 
+func mapAddResponse(m Metadata) *pb.AddDocumentResponse {
+	return &pb.AddDocumentResponse{
+		Id:          m.ID.Hex(),
+		Filename:    m.Filename,
+		ContentType: m.ContentType,
+		Size:        m.Size,
+		TaskId:      m.TaskID,
+		OwnerId:     m.OwnerID,
+		Tags:        m.Tags,
+		UploadedAt:  m.UploadedAt.Unix(),
+		Version:     int32(m.Version()),
+	}
+}
+
+// uploadStreamReader превращает клиентский gRPC поток в io.Reader
+type uploadStreamReader struct {
+	stream pb.DocumentService_UploadDocumentServer
+	buf    []byte
+}
+
+func (r *uploadStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if msg.GetInfo() != nil {
+			return 0, status.Error(codes.InvalidArgument, "document info must be sent only once")
+		}
+		r.buf = msg.GetChunk()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (h *GrpcHandler) ReconcileStorage(ctx context.Context, req *pb.ReconcileStorageRequest) (*pb.ReconcileStorageResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := h.service.ReconcileStorage(ctx, req.GetDeleteOrphans(), requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	missing := make([]*pb.MissingObject, 0, len(report.MissingObjects))
+	for _, m := range report.MissingObjects {
+		missing = append(missing, &pb.MissingObject{
+			DocumentId: m.DocumentID,
+			Version:    int32(m.Version),
+			ObjectKey:  m.ObjectKey,
+		})
+	}
+
+	return &pb.ReconcileStorageResponse{
+		StartedAt:           report.StartedAt.Unix(),
+		FinishedAt:          report.FinishedAt.Unix(),
+		DeleteOrphans:       report.DeleteOrphans,
+		ObjectsScanned:      report.ObjectsScanned,
+		RecordsScanned:      report.RecordsScanned,
+		OrphanObjects:       report.OrphanObjects,
+		OrphanObjectsTotal:  report.OrphanObjectsTotal,
+		MissingObjects:      missing,
+		MissingObjectsTotal: report.MissingObjectsTotal,
+		DeletedObjects:      report.DeletedObjects,
+		DeletedRecords:      report.DeletedRecords,
+		Failed:              report.Failed,
+	}, nil
+}
+
+func (h *GrpcHandler) ListTrash(ctx context.Context, req *pb.ListTrashRequest) (*pb.ListTrashResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := h.service.ListTrash(ctx, req.GetOwnerId(), requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	return &pb.ListTrashResponse{Documents: mapDocs(docs)}, nil
+}
+
+func (h *GrpcHandler) RestoreDocument(ctx context.Context, req *pb.RestoreDocumentRequest) (*pb.Document, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := h.service.RestoreDocument(ctx, req.GetId(), requester)
+	if err != nil {
+		return nil, handleServiceErr(err)
+	}
+
+	return mapDoc(doc), nil
+}
+
 func handleServiceErr(err error) error {
-	if errors.Is(err, ErrForbidden) {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotTaskWorker) {
 		return status.Error(codes.PermissionDenied, err.Error())
 	}
 	if errors.Is(err, ErrFileTooLarge) {
@@ -251,8 +611,38 @@ func handleServiceErr(err error) error {
 	if errors.Is(err, ErrEmptyFilename) {
 		return status.Error(codes.InvalidArgument, err.Error())
 	}
+	if errors.Is(err, ErrInvalidFilename) || errors.Is(err, ErrPathTraversal) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, ErrInvalidFileType) || errors.Is(err, ErrContentMismatch) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
 	if errors.Is(err, ErrEmptyContent) {
 		return status.Error(codes.InvalidArgument, err.Error())
 	}
+	if errors.Is(err, ErrInvalidObjectKey) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, ErrInvalidVersion) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, ErrInvalidSearch) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrVersionNotFound) || errors.Is(err, ErrTaskNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, ErrUploadCompleted) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrReconcileRunning) {
+		return status.Error(codes.Aborted, err.Error())
+	}
+	if errors.Is(err, ErrScanPending) || errors.Is(err, ErrInfected) || errors.Is(err, ErrTaskNotAttachable) || errors.Is(err, ErrCorrupted) || errors.Is(err, ErrEncryptedContent) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrDecrypt) {
+		return status.Error(codes.DataLoss, err.Error())
+	}
 	return status.Error(codes.Internal, err.Error())
 }
@@ -0,0 +1,188 @@
+package document
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"time"
+)
+
+const (
+	defaultReverifyAfter = 7 * 24 * time.Hour
+	defaultScrubInterval = time.Hour
+	scrubBatchSize       = 100
+	scrubObjectTimeout   = 10 * time.Minute
+)
+
+var (
+	// ErrChecksumMismatch - содержимое объекта не совпало с сохранённой контрольной суммой
+	ErrChecksumMismatch = errors.New("document content does not match its checksum")
+	// ErrCorrupted - документ помечен повреждённым и не отдаётся
+	ErrCorrupted = errors.New("document content is corrupted")
+)
+
+// verifyingReader считает SHA-256 при чтении и вместо io.EOF возвращает
+// ErrChecksumMismatch, если содержимое не совпало с ожидаемым
+type verifyingReader struct {
+	io.ReadCloser
+	hasher     hash.Hash
+	expected   string
+	onMismatch func()
+}
+
+func newVerifyingReader(reader io.ReadCloser, expected string, onMismatch func()) *verifyingReader {
+	return &verifyingReader{ReadCloser: reader, hasher: sha256.New(), expected: expected, onMismatch: onMismatch}
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hasher.Write(p[:n])
+	if errors.Is(err, io.EOF) && hex.EncodeToString(r.hasher.Sum(nil)) != r.expected {
+		err = ErrChecksumMismatch
+	}
+	// Зашифрованное содержимое, не прошедшее проверку подлинности, тоже повреждено
+	if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrDecrypt) {
+		if r.onMismatch != nil {
+			r.onMismatch()
+			r.onMismatch = nil
+		}
+	}
+	return n, err
+}
+
+// openVerified открывает объект документа; если у документа есть контрольная сумма,
+// содержимое сверяется с ней по мере чтения, а при расхождении документ помечается повреждённым
+func (s *service) openVerified(ctx context.Context, doc Metadata) (io.ReadCloser, error) {
+	reader, _, err := openObject(ctx, s.storage, s.keys, doc.MinioObject, doc.EncryptionKey)
+	if err != nil || doc.Checksum == "" {
+		return reader, err
+	}
+	return newVerifyingReader(reader, doc.Checksum, func() {
+		log.Printf("document %s: checksum mismatch for object %s", doc.ID.Hex(), doc.MinioObject)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := markCorrupted(ctx, s.repo, doc.MinioObject); err != nil {
+				log.Printf("mark object %s corrupted: %v", doc.MinioObject, err)
+			}
+		}()
+	}), nil
+}
+
+// markCorrupted помечает записи объекта повреждёнными. Для общего содержимого
+// снимается признак stored, чтобы следующая загрузка того же файла записала объект заново.
+func markCorrupted(ctx context.Context, repo Repository, objectKey string) error {
+	if err := repo.SetIntegrity(ctx, objectKey, true, time.Now()); err != nil {
+		return err
+	}
+	if checksum, ok := blobChecksum(objectKey); ok {
+		return repo.SetBlobStored(ctx, checksum, false)
+	}
+	return nil
+}
+
+// checkIntegrity запрещает отдавать содержимое, не прошедшее сверку
+func checkIntegrity(doc Metadata) error {
+	if doc.Corrupted {
+		return ErrCorrupted
+	}
+	return nil
+}
+
+// ScrubWorker периодически перечитывает объекты хранилища и сверяет их с контрольными суммами.
+// Каждый объект проверяется не чаще раза в reverifyAfter.
+type ScrubWorker struct {
+	repo          Repository
+	storage       ObjectStorage
+	keys          *Keyring
+	reverifyAfter time.Duration
+	interval      time.Duration
+}
+
+func NewScrubWorker(repo Repository, storage ObjectStorage, keys *Keyring, reverifyAfter, interval time.Duration) *ScrubWorker {
+	if reverifyAfter <= 0 {
+		reverifyAfter = defaultReverifyAfter
+	}
+	if interval <= 0 {
+		interval = defaultScrubInterval
+	}
+	return &ScrubWorker{repo: repo, storage: storage, keys: keys, reverifyAfter: reverifyAfter, interval: interval}
+}
+
+func (w *ScrubWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.scrub(ctx)
+		}
+	}
+}
+
+func (w *ScrubWorker) scrub(ctx context.Context) {
+	before := time.Now().Add(-w.reverifyAfter)
+	var verified, corrupted int
+	defer func() {
+		if verified > 0 {
+			log.Printf("storage scrub: verified %d objects, %d corrupted", verified, corrupted)
+		}
+	}()
+
+	for ctx.Err() == nil {
+		objects, err := w.repo.FindUnverifiedObjects(ctx, before, scrubBatchSize)
+		if err != nil {
+			log.Printf("storage scrub: find objects: %v", err)
+			return
+		}
+		if len(objects) == 0 {
+			return
+		}
+		for _, obj := range objects {
+			ok, err := w.verify(ctx, obj)
+			if err != nil {
+				// Хранилище или база недоступны: продолжим в следующий запуск
+				log.Printf("storage scrub: %s: %v", obj.ObjectKey, err)
+				return
+			}
+			verified++
+			if !ok {
+				corrupted++
+				log.Printf("storage scrub: object %s is corrupted", obj.ObjectKey)
+			}
+		}
+	}
+}
+
+// verify перечитывает объект и записывает результат сверки. Отсутствующий объект
+// тоже считается повреждением; удалить такие записи может сверка хранилища.
+func (w *ScrubWorker) verify(ctx context.Context, obj StoredObject) (bool, error) {
+	readCtx, cancel := context.WithTimeout(ctx, scrubObjectTimeout)
+	defer cancel()
+
+	reader, _, err := openObject(readCtx, w.storage, w.keys, obj.ObjectKey, obj.EncryptionKey)
+	if errors.Is(err, ErrObjectNotFound) {
+		return false, markCorrupted(ctx, w.repo, obj.ObjectKey)
+	}
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, reader)
+	if err != nil && !errors.Is(err, ErrDecrypt) {
+		return false, err
+	}
+	if err != nil || hex.EncodeToString(hasher.Sum(nil)) != obj.Checksum {
+		return false, markCorrupted(ctx, w.repo, obj.ObjectKey)
+	}
+	return true, w.repo.SetIntegrity(ctx, obj.ObjectKey, false, time.Now())
+}
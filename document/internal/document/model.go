@@ -20,4 +20,86 @@ type Metadata struct {
 	MinioBucket  string             `bson:"minio_bucket" json:"minio_bucket"`
 	Checksum     string             `bson:"checksum,omitempty" json:"checksum,omitempty"`
 	LastModified time.Time          `bson:"last_modified" json:"last_modified"`
+	// CurrentVersion равен 0 у документов, загруженных до появления версий: считаем их версией 1
+	CurrentVersion int `bson:"current_version" json:"current_version"`
+	// ScanStatus относится к содержимому текущей версии
+	ScanStatus    ScanStatus `bson:"scan_status,omitempty" json:"scan_status,omitempty"`
+	ScanSignature string     `bson:"scan_signature,omitempty" json:"scan_signature,omitempty"`
+	// DeletedAt задан у документов в корзине; они не видны в обычных списках и поиске
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy string     `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+	// LastVerifiedAt - время последней сверки содержимого с Checksum; Corrupted - сверка не прошла
+	LastVerifiedAt *time.Time `bson:"last_verified_at,omitempty" json:"last_verified_at,omitempty"`
+	Corrupted      bool       `bson:"corrupted,omitempty" json:"corrupted,omitempty"`
+	// EncryptionKey - обёрнутый ключ данных содержимого текущей версии; nil - хранится открыто
+	EncryptionKey *WrappedKey `bson:"encryption_key,omitempty" json:"-"`
+}
+
+// Version - одна версия содержимого документа. История хранится в отдельной коллекции.
+type Version struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DocumentID   primitive.ObjectID `bson:"document_id" json:"document_id"`
+	Version      int                `bson:"version" json:"version"`
+	Filename     string             `bson:"filename" json:"filename"`
+	ContentType  string             `bson:"content_type" json:"content_type"`
+	Size         int64              `bson:"size" json:"size"`
+	MinioObject  string             `bson:"minio_object" json:"minio_object"`
+	MinioBucket  string             `bson:"minio_bucket" json:"minio_bucket"`
+	Checksum     string             `bson:"checksum,omitempty" json:"checksum,omitempty"`
+	UploadedBy   string             `bson:"uploaded_by" json:"uploaded_by"`
+	UploadedAt   time.Time          `bson:"uploaded_at" json:"uploaded_at"`
+	RestoredFrom int                `bson:"restored_from,omitempty" json:"restored_from,omitempty"`
+	// UploadObject - временный объект presigned загрузки, из которого создана версия
+	UploadObject   string      `bson:"upload_object,omitempty" json:"-"`
+	ScanStatus     ScanStatus  `bson:"scan_status,omitempty" json:"scan_status,omitempty"`
+	ScanSignature  string      `bson:"scan_signature,omitempty" json:"scan_signature,omitempty"`
+	LastVerifiedAt *time.Time  `bson:"last_verified_at,omitempty" json:"last_verified_at,omitempty"`
+	Corrupted      bool        `bson:"corrupted,omitempty" json:"corrupted,omitempty"`
+	EncryptionKey  *WrappedKey `bson:"encryption_key,omitempty" json:"-"`
+}
+
+// Version возвращает номер текущей версии с учётом документов без истории
+func (m Metadata) Version() int {
+	if m.CurrentVersion == 0 {
+		return 1
+	}
+	return m.CurrentVersion
+}
+
+// WithVersion возвращает метаданные документа с содержимым указанной версии
+func (m Metadata) WithVersion(v Version) Metadata {
+	m.Filename = v.Filename
+	m.ContentType = v.ContentType
+	m.Size = v.Size
+	m.MinioObject = v.MinioObject
+	m.MinioBucket = v.MinioBucket
+	m.Checksum = v.Checksum
+	m.CurrentVersion = v.Version
+	m.ScanStatus = v.ScanStatus
+	m.ScanSignature = v.ScanSignature
+	m.LastVerifiedAt = v.LastVerifiedAt
+	m.Corrupted = v.Corrupted
+	m.EncryptionKey = v.EncryptionKey
+	return m
+}
+
+// InitialVersion строит запись первой версии из метаданных документа
+func (m Metadata) InitialVersion() Version {
+	return Version{
+		DocumentID:     m.ID,
+		Version:        1,
+		Filename:       m.Filename,
+		ContentType:    m.ContentType,
+		Size:           m.Size,
+		MinioObject:    m.MinioObject,
+		MinioBucket:    m.MinioBucket,
+		Checksum:       m.Checksum,
+		UploadedBy:     m.OwnerID,
+		UploadedAt:     m.UploadedAt,
+		ScanStatus:     m.ScanStatus,
+		ScanSignature:  m.ScanSignature,
+		LastVerifiedAt: m.LastVerifiedAt,
+		Corrupted:      m.Corrupted,
+		EncryptionKey:  m.EncryptionKey,
+	}
 }
@@ -0,0 +1,325 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// defaultReconcileGrace должен быть больше срока жизни presigned URL: объект,
+	// загруженный по ссылке, появляется в хранилище раньше метаданных
+	defaultReconcileGrace = 24 * time.Hour
+	// maxReportedOrphans ограничивает число ключей в отчёте; счётчики считают все
+	maxReportedOrphans = 1000
+
+	objectDeleteAttempts = 3
+	objectDeleteTimeout  = 30 * time.Second
+)
+
+var ErrReconcileRunning = errors.New("storage reconciliation is already running")
+
+// MissingObject - запись документа или версии, объекта которой нет в хранилище
+type MissingObject struct {
+	DocumentID string
+	Version    int
+	ObjectKey  string
+}
+
+// ReconcileReport - результат сверки хранилища с метаданными
+type ReconcileReport struct {
+	StartedAt      time.Time
+	FinishedAt     time.Time
+	DeleteOrphans  bool
+	ObjectsScanned int64
+	RecordsScanned int64
+	// OrphanObjects - объекты без метаданных
+	OrphanObjects      []string
+	OrphanObjectsTotal int64
+	// MissingObjects - метаданные без объектов
+	MissingObjects      []MissingObject
+	MissingObjectsTotal int64
+	DeletedObjects      int64
+	DeletedRecords      int64
+	Failed              int64
+}
+
+// Reconciler сверяет объекты под documents/ с документами и версиями в MongoDB.
+// Объекты и записи моложе grace не трогаются: загрузка пишет объект раньше метаданных.
+type Reconciler struct {
+	repo    Repository
+	storage ObjectStorage
+	blobs   blobStore
+	grace   time.Duration
+	running sync.Mutex
+}
+
+func NewReconciler(repo Repository, storage ObjectStorage, grace time.Duration) *Reconciler {
+	if grace <= 0 {
+		grace = defaultReconcileGrace
+	}
+	return &Reconciler{
+		repo:    repo,
+		storage: storage,
+		blobs:   blobStore{repo: repo, storage: storage},
+		grace:   grace,
+	}
+}
+
+// Run запускает сверку по расписанию. deleteOrphans определяет, удаляются ли найденные сироты.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration, deleteOrphans bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := r.Reconcile(ctx, deleteOrphans)
+			if err != nil {
+				log.Printf("storage reconcile: %v", err)
+				continue
+			}
+			log.Printf("storage reconcile: %d objects, %d records, %d orphan objects, %d missing objects, deleted %d objects and %d records, %d failed",
+				report.ObjectsScanned, report.RecordsScanned, report.OrphanObjectsTotal, report.MissingObjectsTotal,
+				report.DeletedObjects, report.DeletedRecords, report.Failed)
+		}
+	}
+}
+
+// Reconcile выполняет одну сверку. Одновременно может идти только одна.
+func (r *Reconciler) Reconcile(ctx context.Context, deleteOrphans bool) (ReconcileReport, error) {
+	if !r.running.TryLock() {
+		return ReconcileReport{}, ErrReconcileRunning
+	}
+	defer r.running.Unlock()
+
+	report := ReconcileReport{StartedAt: time.Now(), DeleteOrphans: deleteOrphans}
+	cutoff := report.StartedAt.Add(-r.grace)
+
+	// Метаданные читаем первыми: объект, появившийся после этого, моложе grace
+	referenced := make(map[string]bool)
+	currentVersions := make(map[primitive.ObjectID]int)
+	var refs []ObjectReference
+	err := r.repo.ObjectReferences(ctx, func(ref ObjectReference) error {
+		report.RecordsScanned++
+		referenced[ref.ObjectKey] = true
+		if ref.Current {
+			currentVersions[ref.DocumentID] = ref.Version
+		}
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	existing := make(map[string]bool)
+	var orphans []string
+	err = r.storage.ListObjects(ctx, objectKeyPrefix, func(obj ObjectInfo) error {
+		report.ObjectsScanned++
+		existing[obj.Key] = true
+		if !referenced[obj.Key] && obj.LastModified.Before(cutoff) {
+			orphans = append(orphans, obj.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	for _, objectKey := range orphans {
+		report.OrphanObjectsTotal++
+		if len(report.OrphanObjects) < maxReportedOrphans {
+			report.OrphanObjects = append(report.OrphanObjects, objectKey)
+		}
+		if deleteOrphans {
+			r.deleteOrphanObject(ctx, objectKey, &report)
+		}
+	}
+
+	deletedDocs := make(map[primitive.ObjectID]bool)
+	markedMissing := make(map[string]bool)
+	// Сначала документы: удаление документа удаляет и его версии
+	for _, current := range []bool{true, false} {
+		for _, ref := range refs {
+			if ref.Current != current || existing[ref.ObjectKey] || !ref.UploadedAt.Before(cutoff) {
+				continue
+			}
+			if !current && deletedDocs[ref.DocumentID] {
+				continue
+			}
+			report.MissingObjectsTotal++
+			if len(report.MissingObjects) < maxReportedOrphans {
+				report.MissingObjects = append(report.MissingObjects, MissingObject{
+					DocumentID: ref.DocumentID.Hex(),
+					Version:    ref.Version,
+					ObjectKey:  ref.ObjectKey,
+				})
+			}
+			if !markedMissing[ref.ObjectKey] {
+				markedMissing[ref.ObjectKey] = true
+				r.markBlobMissing(ctx, ref.ObjectKey, &report)
+			}
+			if !deleteOrphans {
+				continue
+			}
+			// Запись текущей версии в истории удаляется только вместе с документом
+			if !current && currentVersions[ref.DocumentID] == ref.Version {
+				continue
+			}
+			if r.deleteMissingRecord(ctx, ref, &report) && current {
+				deletedDocs[ref.DocumentID] = true
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+func (r *Reconciler) deleteOrphanObject(ctx context.Context, objectKey string, report *ReconcileReport) {
+	// Объект мог быть привязан после листинга
+	referencedNow, err := r.repo.IsObjectReferenced(ctx, objectKey)
+	if err != nil {
+		report.Failed++
+		log.Printf("storage reconcile: check %s: %v", objectKey, err)
+		return
+	}
+	if referencedNow {
+		return
+	}
+
+	deleteFn := deleteObject
+	if checksum, ok := blobChecksum(objectKey); ok {
+		// Содержимое блокируется, чтобы параллельная загрузка не сослалась на удаляемый объект
+		locked, err := r.repo.LockIdleBlob(ctx, checksum, time.Now().Add(-r.grace))
+		if err != nil {
+			report.Failed++
+			log.Printf("storage reconcile: lock %s: %v", objectKey, err)
+			return
+		}
+		if !locked {
+			return
+		}
+		deleteFn = func(ctx context.Context, _ ObjectStorage, objectKey string) error {
+			return r.blobs.deleteLocked(ctx, checksum, objectKey)
+		}
+	}
+	if err := deleteFn(ctx, r.storage, objectKey); err != nil {
+		report.Failed++
+		log.Printf("storage reconcile: delete object %s: %v", objectKey, err)
+		return
+	}
+	report.DeletedObjects++
+}
+
+// markBlobMissing отмечает содержимое как отсутствующее, чтобы следующая загрузка
+// того же файла записала объект заново. Выполняется и без удаления записей.
+func (r *Reconciler) markBlobMissing(ctx context.Context, objectKey string, report *ReconcileReport) {
+	checksum, ok := blobChecksum(objectKey)
+	if !ok {
+		return
+	}
+	if err := r.repo.SetBlobStored(ctx, checksum, false); err != nil {
+		report.Failed++
+		log.Printf("storage reconcile: mark %s missing: %v", objectKey, err)
+	}
+}
+
+func (r *Reconciler) deleteMissingRecord(ctx context.Context, ref ObjectReference, report *ReconcileReport) bool {
+	// Перед удалением метаданных убеждаемся, что объекта действительно нет
+	if _, err := r.storage.Stat(ctx, ref.ObjectKey); !errors.Is(err, ErrObjectNotFound) {
+		if err != nil {
+			report.Failed++
+			log.Printf("storage reconcile: stat %s: %v", ref.ObjectKey, err)
+		}
+		return false
+	}
+
+	// Удалённые записи держали ссылки на содержимое: их нужно освободить, как при очистке корзины
+	var versions []Version
+	var err error
+	if ref.Current {
+		versions, err = r.repo.FindVersions(ctx, ref.DocumentID)
+		if err == nil {
+			err = r.repo.Delete(ctx, ref.DocumentID)
+		}
+	} else {
+		var v Version
+		v, err = r.repo.FindVersion(ctx, ref.DocumentID, ref.Version)
+		if err == nil {
+			versions = []Version{v}
+			err = r.repo.DeleteVersion(ctx, ref.DocumentID, ref.Version)
+		}
+	}
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrVersionNotFound) {
+		// Запись уже удалил кто-то другой вместе со ссылками
+		report.DeletedRecords++
+		return true
+	}
+	if err != nil {
+		report.Failed++
+		log.Printf("storage reconcile: delete document %s v%d: %v", ref.DocumentID.Hex(), ref.Version, err)
+		return false
+	}
+	report.DeletedRecords++
+
+	docObject := ""
+	if ref.Current {
+		docObject = ref.ObjectKey
+	}
+	for _, objectKey := range heldObjects(docObject, versions) {
+		if err := r.blobs.release(ctx, objectKey); err != nil {
+			report.Failed++
+			log.Printf("storage reconcile: release object %s: %v", objectKey, err)
+		}
+	}
+	return true
+}
+
+// deleteObject удаляет объект с несколькими попытками
+func deleteObject(ctx context.Context, storage ObjectStorage, objectKey string) error {
+	var err error
+	for attempt := 1; attempt <= objectDeleteAttempts; attempt++ {
+		deleteCtx, cancel := context.WithTimeout(ctx, objectDeleteTimeout)
+		err = storage.Delete(deleteCtx, objectKey)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt < objectDeleteAttempts {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+	}
+	return err
+}
+
+// removeObjects в фоне удаляет объекты, на которые больше нет ссылок.
+// Неудачные удаления логируются; оставшиеся объекты подберёт сверка хранилища.
+func (s *service) removeObjects(objectKeys ...string) {
+	go func() {
+		for _, objectKey := range objectKeys {
+			if err := deleteObject(context.Background(), s.storage, objectKey); err != nil {
+				log.Printf("delete object %s: %v", objectKey, err)
+			}
+		}
+	}()
+}
+
+// ReconcileStorage запускает сверку хранилища вручную; доступно только администратору
+func (s *service) ReconcileStorage(ctx context.Context, deleteOrphans bool, requester Requester) (ReconcileReport, error) {
+	if requester.Role != RoleAdmin {
+		return ReconcileReport{}, ErrForbidden
+	}
+	return s.reconciler.Reconcile(ctx, deleteOrphans)
+}
@@ -0,0 +1,196 @@
+package document
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testGrace - grace сверки в тестах: записи старше него уже сверяются
+const testGrace = time.Millisecond
+
+type reconcileFixture struct {
+	repo      *fakeRepository
+	storage   ObjectStorage
+	svc       Service
+	requester Requester
+	taskID    string
+}
+
+func newReconcileFixture(t *testing.T) *reconcileFixture {
+	t.Helper()
+	repo := newFakeRepository()
+	storage := newTestStorage(t)
+	ownerID := uuid.NewString()
+	return &reconcileFixture{
+		repo:    repo,
+		storage: storage,
+		svc: NewService(Dependencies{
+			Repo:       repo,
+			Storage:    storage,
+			SpoolDir:   t.TempDir(),
+			Reconciler: NewReconciler(repo, storage, testGrace),
+		}),
+		requester: Requester{UserID: ownerID, Role: RoleEmployee},
+		taskID:    uuid.NewString(),
+	}
+}
+
+// upload загружает текст новым документом или новой версией documentID
+func (f *reconcileFixture) upload(t *testing.T, documentID, content string) Metadata {
+	t.Helper()
+	doc, err := f.svc.AddDocument(context.Background(), AddDocumentInput{
+		DocumentID:  documentID,
+		TaskID:      f.taskID,
+		OwnerID:     f.requester.UserID,
+		Filename:    "notes.txt",
+		ContentType: "text/plain",
+		Content:     strings.NewReader(content),
+		Size:        int64(len(content)),
+		MaxSize:     1 << 20,
+	}, f.requester)
+	if err != nil {
+		t.Fatalf("AddDocument: %v", err)
+	}
+	return doc
+}
+
+func (f *reconcileFixture) reconcile(t *testing.T, deleteOrphans bool) ReconcileReport {
+	t.Helper()
+	time.Sleep(2 * testGrace)
+	report, err := f.svc.ReconcileStorage(context.Background(), deleteOrphans, Requester{Role: RoleAdmin})
+	if err != nil {
+		t.Fatalf("ReconcileStorage: %v", err)
+	}
+	if report.Failed != 0 {
+		t.Fatalf("reconcile failed %d times", report.Failed)
+	}
+	return report
+}
+
+func checksumOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (f *reconcileFixture) objectExists(t *testing.T, objectKey string) bool {
+	t.Helper()
+	_, err := f.storage.Stat(context.Background(), objectKey)
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestBlobStoreAcquireRelease(t *testing.T) {
+	ctx := context.Background()
+	f := newReconcileFixture(t)
+	blobs := blobStore{repo: f.repo, storage: f.storage}
+
+	first := f.upload(t, "", "shared content")
+	second := f.upload(t, "", "shared content")
+	if first.MinioObject != second.MinioObject {
+		t.Fatalf("same content stored under %q and %q", first.MinioObject, second.MinioObject)
+	}
+	checksum := checksumOf("shared content")
+	if blob, _ := f.repo.blob(checksum); blob.RefCount != 2 || !blob.Stored {
+		t.Fatalf("after two uploads blob = %+v, want 2 refs and stored", blob)
+	}
+
+	if err := blobs.release(ctx, first.MinioObject); err != nil {
+		t.Fatal(err)
+	}
+	if blob, _ := f.repo.blob(checksum); blob.RefCount != 1 {
+		t.Fatalf("after release ref count = %d, want 1", blob.RefCount)
+	}
+	if !f.objectExists(t, first.MinioObject) {
+		t.Fatal("object deleted while still referenced")
+	}
+
+	if err := blobs.release(ctx, second.MinioObject); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.repo.blob(checksum); ok {
+		t.Fatal("blob record kept after last release")
+	}
+	if f.objectExists(t, first.MinioObject) {
+		t.Fatal("object kept after last release")
+	}
+}
+
+func TestReconcileMarksMissingBlob(t *testing.T) {
+	ctx := context.Background()
+	f := newReconcileFixture(t)
+
+	doc := f.upload(t, "", "lost content")
+	if err := f.storage.Delete(ctx, doc.MinioObject); err != nil {
+		t.Fatal(err)
+	}
+
+	report := f.reconcile(t, false)
+	if report.MissingObjectsTotal == 0 {
+		t.Fatal("missing object not reported")
+	}
+	if report.DeletedRecords != 0 {
+		t.Fatalf("report-only reconcile deleted %d records", report.DeletedRecords)
+	}
+	if blob, _ := f.repo.blob(checksumOf("lost content")); blob.Stored {
+		t.Fatal("blob of missing object is still marked stored")
+	}
+
+	// Следующая загрузка того же содержимого должна записать объект заново
+	again := f.upload(t, "", "lost content")
+	if !f.objectExists(t, again.MinioObject) {
+		t.Fatal("upload of missing content skipped writing the object")
+	}
+	if _, content, err := f.svc.GetDocument(ctx, doc.ID.Hex(), 0, f.requester); err != nil || string(content) != "lost content" {
+		t.Fatalf("GetDocument = %q, %v", content, err)
+	}
+}
+
+func TestReconcileReleasesMissingRecords(t *testing.T) {
+	ctx := context.Background()
+	f := newReconcileFixture(t)
+
+	first := f.upload(t, "", "lost content")
+	second := f.upload(t, "", "lost content")
+	versioned := f.upload(t, "", "old version")
+	versioned = f.upload(t, versioned.ID.Hex(), "new version")
+	for _, objectKey := range []string{first.MinioObject, BlobObjectKey(checksumOf("old version"))} {
+		if err := f.storage.Delete(ctx, objectKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := f.reconcile(t, true)
+	// Два документа без содержимого и первая версия третьего
+	if report.DeletedRecords != 3 {
+		t.Fatalf("deleted %d records, want 3", report.DeletedRecords)
+	}
+	for _, id := range []string{first.ID.Hex(), second.ID.Hex()} {
+		if _, _, err := f.svc.GetDocument(ctx, id, 0, f.requester); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("document with missing object: GetDocument = %v, want ErrNotFound", err)
+		}
+	}
+	if _, err := f.repo.FindVersion(ctx, versioned.ID, 1); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("version with missing object: FindVersion = %v, want ErrVersionNotFound", err)
+	}
+	for _, content := range []string{"lost content", "old version"} {
+		if blob, ok := f.repo.blob(checksumOf(content)); ok {
+			t.Fatalf("blob %q kept with %d refs after its records were deleted", content, blob.RefCount)
+		}
+	}
+
+	if blob, _ := f.repo.blob(checksumOf("new version")); blob.RefCount != 1 || !blob.Stored {
+		t.Fatalf("blob of present version = %+v, want 1 ref and stored", blob)
+	}
+	if _, content, err := f.svc.GetDocument(ctx, versioned.ID.Hex(), 0, f.requester); err != nil || string(content) != "new version" {
+		t.Fatalf("GetDocument = %q, %v", content, err)
+	}
+}
@@ -3,35 +3,235 @@ package document
 import (
 	"context"
 	"errors"
+	"regexp"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var ErrNotFound = errors.New("document not found")
+var (
+	ErrNotFound        = errors.New("document not found")
+	ErrVersionNotFound = errors.New("document version not found")
+	ErrVersionConflict = errors.New("document version changed concurrently")
+)
 
 type Repository interface {
 	Insert(ctx context.Context, metadata Metadata) (primitive.ObjectID, error)
+	// Delete удаляет документ и его версии безвозвратно
 	Delete(ctx context.Context, id primitive.ObjectID) error
+	// FindByID не находит документы в корзине
 	FindByID(ctx context.Context, id primitive.ObjectID) (Metadata, error)
 	FindByTask(ctx context.Context, taskID string) ([]Metadata, error)
 	FindByOwner(ctx context.Context, ownerID string) ([]Metadata, error)
+	IsObjectReferenced(ctx context.Context, objectKey string) (bool, error)
+	// IsUploadCompleted сообщает, создана ли уже версия из объекта presigned загрузки
+	IsUploadCompleted(ctx context.Context, uploadKey string) (bool, error)
+	// Search возвращает страницу найденных документов и общее число совпадений
+	Search(ctx context.Context, filter SearchFilter) ([]SearchHit, int64, error)
+	// FindPendingScans возвращает ключи объектов, ожидающих антивирусной проверки
+	FindPendingScans(ctx context.Context, limit int) ([]string, error)
+	// SetScanStatus записывает результат проверки во все документы и версии с этим объектом
+	SetScanStatus(ctx context.Context, objectKey string, status ScanStatus, signature string) error
+	// FindUnverifiedObjects возвращает объекты с контрольной суммой, не сверявшиеся с verifiedBefore
+	FindUnverifiedObjects(ctx context.Context, verifiedBefore time.Time, limit int) ([]StoredObject, error)
+	// SetIntegrity записывает результат сверки во все документы и версии с этим объектом
+	SetIntegrity(ctx context.Context, objectKey string, corrupted bool, verifiedAt time.Time) error
+	// SetContentText сохраняет извлечённый текст, если документ всё ещё на версии version
+	SetContentText(ctx context.Context, id primitive.ObjectID, version int, text string) error
+
+	// InsertVersion возвращает ErrVersionConflict, если номер версии занят, и ErrUploadCompleted,
+	// если версия из того же объекта presigned загрузки уже есть
+	InsertVersion(ctx context.Context, version Version) error
+	FindVersions(ctx context.Context, documentID primitive.ObjectID) ([]Version, error)
+	FindVersion(ctx context.Context, documentID primitive.ObjectID, version int) (Version, error)
+	// SetCurrentVersion переключает документ на версию, если текущая версия всё ещё равна expected
+	SetCurrentVersion(ctx context.Context, documentID primitive.ObjectID, expected int, version Version) error
+	DeleteVersion(ctx context.Context, documentID primitive.ObjectID, version int) error
+
+	// ObjectReferences перебирает ссылки на объекты хранилища из документов и их версий
+	ObjectReferences(ctx context.Context, fn func(ObjectReference) error) error
+
+	// SoftDelete перемещает документ в корзину
+	SoftDelete(ctx context.Context, id primitive.ObjectID, deletedBy string, deletedAt time.Time) error
+	// Restore возвращает документ из корзины
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	FindDeletedByID(ctx context.Context, id primitive.ObjectID) (Metadata, error)
+	// FindTrash возвращает документы в корзине; пустой ownerID - всех пользователей
+	FindTrash(ctx context.Context, ownerID string) ([]Metadata, error)
+	// FindExpiredTrash возвращает документы, удалённые раньше before
+	FindExpiredTrash(ctx context.Context, before time.Time, limit int) ([]Metadata, error)
+	// DeleteTrashed безвозвратно удаляет документ, если он всё ещё в корзине и удалён раньше before
+	DeleteTrashed(ctx context.Context, id primitive.ObjectID, before time.Time) error
+
+	// AcquireBlob добавляет ссылку на содержимое, создавая запись при первой ссылке.
+	// Возвращает ErrBlobBusy, пока объект с таким содержимым удаляется.
+	AcquireBlob(ctx context.Context, checksum, objectKey string, size int64, key *WrappedKey) (Blob, error)
+	// RewrapEncryptionKeys переоборачивает ключи данных в содержимом, документах и версиях
+	// активным мастер-ключом и возвращает число обновлённых записей
+	RewrapEncryptionKeys(ctx context.Context, keys *Keyring) (int64, error)
+	// FindBlob возвращает запись о содержимом или ErrNotFound
+	FindBlob(ctx context.Context, checksum string) (Blob, error)
+	SetBlobStored(ctx context.Context, checksum string, stored bool) error
+	// ReleaseBlob убирает ссылку на содержимое и возвращает число оставшихся
+	ReleaseBlob(ctx context.Context, checksum string) (int64, error)
+	// LockUnusedBlob блокирует удаление содержимого без ссылок; false - блокировка не получена
+	LockUnusedBlob(ctx context.Context, checksum string) (bool, error)
+	// LockIdleBlob блокирует удаление содержимого, не менявшегося с idleBefore, независимо от счётчика
+	LockIdleBlob(ctx context.Context, checksum string, idleBefore time.Time) (bool, error)
+	// DeleteBlob удаляет заблокированную запись о содержимом
+	DeleteBlob(ctx context.Context, checksum string) error
+	// ClearStaleBlobLock удаляет запись, заблокированную раньше before и оставшуюся после сбоя
+	ClearStaleBlobLock(ctx context.Context, checksum string, before time.Time) error
+
+	EnsureIndexes(ctx context.Context) error
 }
 
+// notDeleted - условие, исключающее документы в корзине
+var notDeleted = bson.M{"$exists": false}
+
 type mongoRepository struct {
 	collection *mongo.Collection
+	versions   *mongo.Collection
+	blobs      *mongo.Collection
 }
 
-func NewRepository(collection *mongo.Collection) Repository {
+func NewRepository(collection, versions, blobs *mongo.Collection) Repository {
 	return &mongoRepository{
 		collection: collection,
+		versions:   versions,
+		blobs:      blobs,
+	}
+}
+
+// SearchFilter - условия поиска документов. Пустые поля в фильтре не участвуют.
+type SearchFilter struct {
+	Tags         []string
+	MatchAllTags bool
+	Filename     string
+	ContentType  string
+	UploadedFrom time.Time
+	UploadedTo   time.Time
+	TaskID       string
+	OwnerID      string
+	// Content - запрос полнотекстового поиска по извлечённому тексту
+	Content  string
+	SortBy   string
+	SortDesc bool
+	Skip     int64
+	Limit    int64
+}
+
+// SearchHit - найденный документ. Score и ContentText заполняются только при поиске по содержимому.
+type SearchHit struct {
+	Metadata    `bson:",inline"`
+	Score       float64 `bson:"score,omitempty"`
+	ContentText string  `bson:"content_text,omitempty"`
+}
+
+// uploadObjectIndex - уникальный индекс версий по объекту presigned загрузки
+const uploadObjectIndex = "upload_object_unique"
+
+// isIndexNotFound - удаляемого индекса или коллекции нет
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27)
+}
+
+// sortByRelevance - сортировка по textScore, доступна только вместе с Content
+const sortByRelevance = "relevance"
+
+// metadataProjection убирает из выборок извлечённый текст: он нужен только поиску
+var metadataProjection = bson.M{"content_text": 0}
+
+func (r *mongoRepository) EnsureIndexes(ctx context.Context) error {
+	// Прежний неуникальный индекс с теми же ключами не даст создать уникальный
+	if _, err := r.versions.Indexes().DropOne(ctx, "upload_object"); err != nil && !isIndexNotFound(err) {
+		return err
 	}
+	_, err := r.versions.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "document_id", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("document_version_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "minio_object", Value: 1}},
+			Options: options.Index().SetName("minio_object"),
+		},
+		{
+			Keys:    bson.D{{Key: "scan_status", Value: 1}},
+			Options: options.Index().SetName("scan_status").SetSparse(true),
+		},
+		{
+			// Один объект presigned загрузки становится только одной версией
+			Keys:    bson.D{{Key: "upload_object", Value: 1}},
+			Options: options.Index().SetName(uploadObjectIndex).SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "last_verified_at", Value: 1}},
+			Options: options.Index().SetName("last_verified_at"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "task_id", Value: 1}, {Key: "uploaded_at", Value: -1}},
+			Options: options.Index().SetName("task_uploaded_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "uploaded_at", Value: -1}},
+			Options: options.Index().SetName("owner_uploaded_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}, {Key: "uploaded_at", Value: -1}},
+			Options: options.Index().SetName("tags_uploaded_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "content_type", Value: 1}, {Key: "uploaded_at", Value: -1}},
+			Options: options.Index().SetName("content_type_uploaded_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "uploaded_at", Value: -1}},
+			Options: options.Index().SetName("uploaded_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "minio_object", Value: 1}},
+			Options: options.Index().SetName("minio_object"),
+		},
+		{
+			Keys:    bson.D{{Key: "scan_status", Value: 1}},
+			Options: options.Index().SetName("scan_status").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetName("deleted_at").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "last_verified_at", Value: 1}},
+			Options: options.Index().SetName("last_verified_at"),
+		},
+		{
+			// Тексты на русском и английском вперемешку, поэтому без стемминга и стоп-слов
+			Keys: bson.D{{Key: "content_text", Value: "text"}},
+			Options: options.Index().
+				SetName("content_text").
+				SetDefaultLanguage("none"),
+		},
+	})
+	return err
 }
 
 func (r *mongoRepository) findMany(ctx context.Context, filter bson.M) ([]Metadata, error) {
-	cursor, err := r.collection.Find(ctx, filter)
+	if _, ok := filter["deleted_at"]; !ok {
+		filter["deleted_at"] = notDeleted
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(metadataProjection))
 	if err != nil {
 		return nil, err
 	}
@@ -45,9 +245,10 @@ func (r *mongoRepository) findMany(ctx context.Context, filter bson.M) ([]Metada
 }
 
 func (r *mongoRepository) Insert(ctx context.Context, metadata Metadata) (primitive.ObjectID, error) {
-	now := time.Now()
-	metadata.UploadedAt = now
-	metadata.LastModified = now
+	if metadata.UploadedAt.IsZero() {
+		metadata.UploadedAt = time.Now()
+	}
+	metadata.LastModified = metadata.UploadedAt
 
 	res, err := r.collection.InsertOne(ctx, metadata)
 	if err != nil {
@@ -62,19 +263,29 @@ func (r *mongoRepository) Insert(ctx context.Context, metadata Metadata) (primit
 }
 
 func (r *mongoRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
-	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return r.deleteWithVersions(ctx, id, bson.M{"_id": id})
+}
+
+func (r *mongoRepository) DeleteTrashed(ctx context.Context, id primitive.ObjectID, before time.Time) error {
+	return r.deleteWithVersions(ctx, id, bson.M{"_id": id, "deleted_at": bson.M{"$lt": before}})
+}
+
+func (r *mongoRepository) deleteWithVersions(ctx context.Context, id primitive.ObjectID, filter bson.M) error {
+	res, err := r.collection.DeleteOne(ctx, filter)
 	if err != nil {
 		return err
 	}
 	if res.DeletedCount == 0 {
 		return ErrNotFound
 	}
-	return nil
+	_, err = r.versions.DeleteMany(ctx, bson.M{"document_id": id})
+	return err
 }
 
 func (r *mongoRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Metadata, error) {
 	var metadata Metadata
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&metadata)
+	filter := bson.M{"_id": id, "deleted_at": notDeleted}
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetProjection(metadataProjection)).Decode(&metadata)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return Metadata{}, ErrNotFound
@@ -84,6 +295,106 @@ func (r *mongoRepository) FindByID(ctx context.Context, id primitive.ObjectID) (
 	return metadata, nil
 }
 
+func (r *mongoRepository) IsObjectReferenced(ctx context.Context, objectKey string) (bool, error) {
+	filter := bson.M{"minio_object": objectKey}
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+	count, err = r.versions.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *mongoRepository) IsUploadCompleted(ctx context.Context, uploadKey string) (bool, error) {
+	count, err := r.versions.CountDocuments(ctx, bson.M{"upload_object": uploadKey}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *mongoRepository) InsertVersion(ctx context.Context, version Version) error {
+	if version.UploadedAt.IsZero() {
+		version.UploadedAt = time.Now()
+	}
+	_, err := r.versions.InsertOne(ctx, version)
+	if mongo.IsDuplicateKeyError(err) {
+		// Параллельный upload-complete того же объекта уже создал версию
+		if strings.Contains(err.Error(), uploadObjectIndex) {
+			return ErrUploadCompleted
+		}
+		return ErrVersionConflict
+	}
+	return err
+}
+
+func (r *mongoRepository) DeleteVersion(ctx context.Context, documentID primitive.ObjectID, version int) error {
+	res, err := r.versions.DeleteOne(ctx, bson.M{"document_id": documentID, "version": version})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrVersionNotFound
+	}
+	return nil
+}
+
+func (r *mongoRepository) FindVersions(ctx context.Context, documentID primitive.ObjectID) ([]Version, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}})
+	cursor, err := r.versions.Find(ctx, bson.M{"document_id": documentID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []Version
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *mongoRepository) FindVersion(ctx context.Context, documentID primitive.ObjectID, version int) (Version, error) {
+	var result Version
+	err := r.versions.FindOne(ctx, bson.M{"document_id": documentID, "version": version}).Decode(&result)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return Version{}, ErrVersionNotFound
+		}
+		return Version{}, err
+	}
+	return result, nil
+}
+
+func (r *mongoRepository) SetCurrentVersion(ctx context.Context, documentID primitive.ObjectID, expected int, version Version) error {
+	filter := bson.M{"_id": documentID, "current_version": expected}
+	if expected <= 1 {
+		// У документов без истории поле current_version может быть 0
+		filter["current_version"] = bson.M{"$in": bson.A{0, 1}}
+	}
+	update := bson.M{"$set": bson.M{
+		"filename":         version.Filename,
+		"content_type":     version.ContentType,
+		"size":             version.Size,
+		"minio_object":     version.MinioObject,
+		"minio_bucket":     version.MinioBucket,
+		"checksum":         version.Checksum,
+		"scan_status":      version.ScanStatus,
+		"scan_signature":   version.ScanSignature,
+		"last_verified_at": version.LastVerifiedAt,
+		"corrupted":        version.Corrupted,
+		"encryption_key":   version.EncryptionKey,
+		"current_version":  version.Version,
+		"last_modified":    time.Now(),
+	}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
 func (r *mongoRepository) FindByTask(ctx context.Context, taskID string) ([]Metadata, error) {
 	return r.findMany(ctx, bson.M{"task_id": taskID})
 }
@@ -91,3 +402,322 @@ func (r *mongoRepository) FindByTask(ctx context.Context, taskID string) ([]Meta
 func (r *mongoRepository) FindByOwner(ctx context.Context, ownerID string) ([]Metadata, error) {
 	return r.findMany(ctx, bson.M{"owner_id": ownerID})
 }
+
+func (r *mongoRepository) Search(ctx context.Context, f SearchFilter) ([]SearchHit, int64, error) {
+	filter := bson.M{"deleted_at": notDeleted}
+	if f.Content != "" {
+		filter["$text"] = bson.M{"$search": f.Content}
+	}
+	if len(f.Tags) > 0 {
+		if f.MatchAllTags {
+			filter["tags"] = bson.M{"$all": f.Tags}
+		} else {
+			filter["tags"] = bson.M{"$in": f.Tags}
+		}
+	}
+	if f.Filename != "" {
+		filter["filename"] = primitive.Regex{Pattern: regexp.QuoteMeta(f.Filename), Options: "i"}
+	}
+	if f.ContentType != "" {
+		filter["content_type"] = f.ContentType
+	}
+	if f.TaskID != "" {
+		filter["task_id"] = f.TaskID
+	}
+	if f.OwnerID != "" {
+		filter["owner_id"] = f.OwnerID
+	}
+	if !f.UploadedFrom.IsZero() || !f.UploadedTo.IsZero() {
+		uploadedAt := bson.M{}
+		if !f.UploadedFrom.IsZero() {
+			uploadedAt["$gte"] = f.UploadedFrom
+		}
+		if !f.UploadedTo.IsZero() {
+			uploadedAt["$lt"] = f.UploadedTo
+		}
+		filter["uploaded_at"] = uploadedAt
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	order := 1
+	if f.SortDesc {
+		order = -1
+	}
+	// _id добавлен для стабильного порядка между страницами при одинаковых значениях
+	sort := bson.D{{Key: f.SortBy, Value: order}, {Key: "_id", Value: order}}
+	if f.SortBy == sortByRelevance {
+		sort = bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}}
+	}
+
+	opts := options.Find().
+		SetSort(sort).
+		SetSkip(f.Skip).
+		SetLimit(f.Limit).
+		SetProjection(metadataProjection)
+	if f.Content != "" {
+		// Текст нужен сервису для фрагментов с подсветкой
+		opts.SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}})
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []SearchHit
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+func (r *mongoRepository) SetContentText(ctx context.Context, id primitive.ObjectID, version int, text string) error {
+	filter := bson.M{"_id": id, "current_version": version}
+	if version <= 1 {
+		filter["current_version"] = bson.M{"$in": bson.A{0, 1}}
+	}
+	update := bson.M{"$set": bson.M{"content_text": text}}
+	if text == "" {
+		update = bson.M{"$unset": bson.M{"content_text": ""}}
+	}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *mongoRepository) FindPendingScans(ctx context.Context, limit int) ([]string, error) {
+	filter := bson.M{"scan_status": ScanPending}
+	opts := options.Find().SetProjection(bson.M{"minio_object": 1}).SetLimit(int64(limit))
+
+	seen := make(map[string]bool)
+	var objectKeys []string
+	// Версии тоже проверяем: старая версия могла остаться pending после новой загрузки
+	for _, collection := range []*mongo.Collection{r.collection, r.versions} {
+		cursor, err := collection.Find(ctx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		var rows []struct {
+			MinioObject string `bson:"minio_object"`
+		}
+		err = cursor.All(ctx, &rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if !seen[row.MinioObject] {
+				seen[row.MinioObject] = true
+				objectKeys = append(objectKeys, row.MinioObject)
+			}
+		}
+	}
+	return objectKeys, nil
+}
+
+func (r *mongoRepository) SetScanStatus(ctx context.Context, objectKey string, status ScanStatus, signature string) error {
+	filter := bson.M{"minio_object": objectKey}
+	update := bson.M{"$set": bson.M{"scan_status": status, "scan_signature": signature}}
+
+	if _, err := r.versions.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// StoredObject - объект хранилища и ожидаемая контрольная сумма его содержимого
+type StoredObject struct {
+	ObjectKey     string      `bson:"minio_object"`
+	Checksum      string      `bson:"checksum"`
+	EncryptionKey *WrappedKey `bson:"encryption_key,omitempty"`
+}
+
+func (r *mongoRepository) FindUnverifiedObjects(ctx context.Context, verifiedBefore time.Time, limit int) ([]StoredObject, error) {
+	// $not отбирает и записи без поля, и записи с null
+	filter := bson.M{
+		"checksum":         bson.M{"$gt": ""},
+		"last_verified_at": bson.M{"$not": bson.M{"$gte": verifiedBefore}},
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"minio_object": 1, "checksum": 1, "encryption_key": 1}).
+		SetSort(bson.D{{Key: "last_verified_at", Value: 1}}).
+		SetLimit(int64(limit))
+
+	seen := make(map[string]bool)
+	var objects []StoredObject
+	for _, collection := range []*mongo.Collection{r.collection, r.versions} {
+		cursor, err := collection.Find(ctx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		var rows []StoredObject
+		if err := cursor.All(ctx, &rows); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if !seen[row.ObjectKey] {
+				seen[row.ObjectKey] = true
+				objects = append(objects, row)
+			}
+		}
+	}
+	return objects, nil
+}
+
+func (r *mongoRepository) SetIntegrity(ctx context.Context, objectKey string, corrupted bool, verifiedAt time.Time) error {
+	filter := bson.M{"minio_object": objectKey}
+	update := bson.M{"$set": bson.M{"corrupted": corrupted, "last_verified_at": verifiedAt}}
+
+	if _, err := r.versions.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// ObjectReference - ссылка документа или версии на объект в хранилище
+type ObjectReference struct {
+	DocumentID primitive.ObjectID
+	Version    int
+	ObjectKey  string
+	UploadedAt time.Time
+	// Current - ссылка из самого документа (его текущая версия), а не из истории
+	Current bool
+}
+
+func (r *mongoRepository) ObjectReferences(ctx context.Context, fn func(ObjectReference) error) error {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"minio_object": 1, "uploaded_at": 1, "current_version": 1,
+	}))
+	if err != nil {
+		return err
+	}
+	for cursor.Next(ctx) {
+		var doc Metadata
+		if err := cursor.Decode(&doc); err != nil {
+			cursor.Close(ctx)
+			return err
+		}
+		ref := ObjectReference{
+			DocumentID: doc.ID,
+			Version:    doc.Version(),
+			ObjectKey:  doc.MinioObject,
+			UploadedAt: doc.UploadedAt,
+			Current:    true,
+		}
+		if err := fn(ref); err != nil {
+			cursor.Close(ctx)
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		cursor.Close(ctx)
+		return err
+	}
+	cursor.Close(ctx)
+
+	cursor, err = r.versions.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"document_id": 1, "version": 1, "minio_object": 1, "uploaded_at": 1,
+	}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var v Version
+		if err := cursor.Decode(&v); err != nil {
+			return err
+		}
+		ref := ObjectReference{
+			DocumentID: v.DocumentID,
+			Version:    v.Version,
+			ObjectKey:  v.MinioObject,
+			UploadedAt: v.UploadedAt,
+		}
+		if err := fn(ref); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *mongoRepository) SoftDelete(ctx context.Context, id primitive.ObjectID, deletedBy string, deletedAt time.Time) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "deleted_at": notDeleted},
+		bson.M{"$set": bson.M{"deleted_at": deletedAt, "deleted_by": deletedBy}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "deleted_at": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRepository) FindDeletedByID(ctx context.Context, id primitive.ObjectID) (Metadata, error) {
+	var metadata Metadata
+	filter := bson.M{"_id": id, "deleted_at": bson.M{"$exists": true}}
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetProjection(metadataProjection)).Decode(&metadata)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return Metadata{}, ErrNotFound
+		}
+		return Metadata{}, err
+	}
+	return metadata, nil
+}
+
+func (r *mongoRepository) FindTrash(ctx context.Context, ownerID string) ([]Metadata, error) {
+	filter := bson.M{"deleted_at": bson.M{"$exists": true}}
+	if ownerID != "" {
+		filter["owner_id"] = ownerID
+	}
+	return r.findMany(ctx, filter)
+}
+
+func (r *mongoRepository) FindExpiredTrash(ctx context.Context, before time.Time, limit int) ([]Metadata, error) {
+	opts := options.Find().
+		SetProjection(metadataProjection).
+		SetSort(bson.D{{Key: "deleted_at", Value: 1}}).
+		SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"deleted_at": bson.M{"$lt": before}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []Metadata
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
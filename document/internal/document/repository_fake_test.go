@@ -0,0 +1,299 @@
+package document
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeRepository хранит документы, версии и содержимое в памяти. Методы, которые
+// тестам не нужны, не реализованы: вызов паникует на встроенном nil Repository.
+type fakeRepository struct {
+	Repository
+
+	mu       sync.Mutex
+	docs     map[primitive.ObjectID]Metadata
+	versions map[primitive.ObjectID]map[int]Version
+	blobs    map[string]Blob
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		docs:     make(map[primitive.ObjectID]Metadata),
+		versions: make(map[primitive.ObjectID]map[int]Version),
+		blobs:    make(map[string]Blob),
+	}
+}
+
+func (r *fakeRepository) Insert(_ context.Context, metadata Metadata) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	metadata.ID = primitive.NewObjectID()
+	r.docs[metadata.ID] = metadata
+	return metadata.ID, nil
+}
+
+func (r *fakeRepository) Delete(_ context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.docs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.docs, id)
+	delete(r.versions, id)
+	return nil
+}
+
+func (r *fakeRepository) FindByID(_ context.Context, id primitive.ObjectID) (Metadata, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc, ok := r.docs[id]
+	if !ok || doc.DeletedAt != nil {
+		return Metadata{}, ErrNotFound
+	}
+	return doc, nil
+}
+
+func (r *fakeRepository) IsObjectReferenced(_ context.Context, objectKey string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, doc := range r.docs {
+		if doc.MinioObject == objectKey {
+			return true, nil
+		}
+		for _, v := range r.versions[id] {
+			if v.MinioObject == objectKey {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRepository) FindPendingScans(_ context.Context, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var objectKeys []string
+	for _, doc := range r.docs {
+		if doc.ScanStatus == ScanPending && len(objectKeys) < limit {
+			objectKeys = append(objectKeys, doc.MinioObject)
+		}
+	}
+	return objectKeys, nil
+}
+
+func (r *fakeRepository) SetScanStatus(_ context.Context, objectKey string, status ScanStatus, signature string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, doc := range r.docs {
+		if doc.MinioObject == objectKey {
+			doc.ScanStatus, doc.ScanSignature = status, signature
+			r.docs[id] = doc
+		}
+		for n, v := range r.versions[id] {
+			if v.MinioObject == objectKey {
+				v.ScanStatus, v.ScanSignature = status, signature
+				r.versions[id][n] = v
+			}
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) SetIntegrity(_ context.Context, objectKey string, corrupted bool, verifiedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, doc := range r.docs {
+		if doc.MinioObject == objectKey {
+			doc.Corrupted, doc.LastVerifiedAt = corrupted, &verifiedAt
+			r.docs[id] = doc
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) SetContentText(context.Context, primitive.ObjectID, int, string) error {
+	return nil
+}
+
+func (r *fakeRepository) InsertVersion(_ context.Context, version Version) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.versions[version.DocumentID] == nil {
+		r.versions[version.DocumentID] = make(map[int]Version)
+	}
+	if _, ok := r.versions[version.DocumentID][version.Version]; ok {
+		return ErrVersionConflict
+	}
+	for _, versions := range r.versions {
+		for _, v := range versions {
+			if version.UploadObject != "" && v.UploadObject == version.UploadObject {
+				return ErrUploadCompleted
+			}
+		}
+	}
+	r.versions[version.DocumentID][version.Version] = version
+	return nil
+}
+
+func (r *fakeRepository) FindVersions(_ context.Context, documentID primitive.ObjectID) ([]Version, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var versions []Version
+	for _, v := range r.versions[documentID] {
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func (r *fakeRepository) FindVersion(_ context.Context, documentID primitive.ObjectID, version int) (Version, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.versions[documentID][version]
+	if !ok {
+		return Version{}, ErrVersionNotFound
+	}
+	return v, nil
+}
+
+func (r *fakeRepository) SetCurrentVersion(_ context.Context, documentID primitive.ObjectID, expected int, version Version) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc, ok := r.docs[documentID]
+	if !ok || doc.Version() != expected {
+		return ErrVersionConflict
+	}
+	r.docs[documentID] = doc.WithVersion(version)
+	return nil
+}
+
+func (r *fakeRepository) DeleteVersion(_ context.Context, documentID primitive.ObjectID, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.versions[documentID][version]; !ok {
+		return ErrVersionNotFound
+	}
+	delete(r.versions[documentID], version)
+	return nil
+}
+
+func (r *fakeRepository) ObjectReferences(_ context.Context, fn func(ObjectReference) error) error {
+	r.mu.Lock()
+	var refs []ObjectReference
+	for id, doc := range r.docs {
+		refs = append(refs, ObjectReference{DocumentID: id, Version: doc.Version(), ObjectKey: doc.MinioObject, UploadedAt: doc.UploadedAt, Current: true})
+		for _, v := range r.versions[id] {
+			refs = append(refs, ObjectReference{DocumentID: id, Version: v.Version, ObjectKey: v.MinioObject, UploadedAt: v.UploadedAt})
+		}
+	}
+	r.mu.Unlock()
+	for _, ref := range refs {
+		if err := fn(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) AcquireBlob(_ context.Context, checksum, objectKey string, size int64, key *WrappedKey) (Blob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	blob, ok := r.blobs[checksum]
+	if ok && blob.DeletingAt != nil {
+		return Blob{}, ErrBlobBusy
+	}
+	if !ok {
+		blob = Blob{Checksum: checksum, ObjectKey: objectKey, Size: size, CreatedAt: time.Now(), EncryptionKey: key}
+	}
+	blob.RefCount++
+	blob.UpdatedAt = time.Now()
+	r.blobs[checksum] = blob
+	return blob, nil
+}
+
+func (r *fakeRepository) FindBlob(_ context.Context, checksum string) (Blob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	blob, ok := r.blobs[checksum]
+	if !ok {
+		return Blob{}, ErrNotFound
+	}
+	return blob, nil
+}
+
+func (r *fakeRepository) SetBlobStored(_ context.Context, checksum string, stored bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if blob, ok := r.blobs[checksum]; ok {
+		blob.Stored = stored
+		r.blobs[checksum] = blob
+	}
+	return nil
+}
+
+func (r *fakeRepository) ReleaseBlob(_ context.Context, checksum string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	blob, ok := r.blobs[checksum]
+	if !ok || blob.RefCount <= 0 {
+		return 0, nil
+	}
+	blob.RefCount--
+	r.blobs[checksum] = blob
+	return blob.RefCount, nil
+}
+
+func (r *fakeRepository) LockUnusedBlob(_ context.Context, checksum string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	blob, ok := r.blobs[checksum]
+	if !ok || blob.RefCount > 0 || blob.DeletingAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	blob.DeletingAt = &now
+	r.blobs[checksum] = blob
+	return true, nil
+}
+
+func (r *fakeRepository) LockIdleBlob(_ context.Context, checksum string, idleBefore time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	blob, ok := r.blobs[checksum]
+	if !ok {
+		blob = Blob{Checksum: checksum, ObjectKey: BlobObjectKey(checksum), CreatedAt: now, UpdatedAt: now}
+	} else if blob.DeletingAt != nil || !blob.UpdatedAt.Before(idleBefore) {
+		return false, nil
+	}
+	blob.DeletingAt = &now
+	r.blobs[checksum] = blob
+	return true, nil
+}
+
+func (r *fakeRepository) DeleteBlob(_ context.Context, checksum string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if blob, ok := r.blobs[checksum]; ok && blob.DeletingAt != nil {
+		delete(r.blobs, checksum)
+	}
+	return nil
+}
+
+func (r *fakeRepository) ClearStaleBlobLock(_ context.Context, checksum string, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if blob, ok := r.blobs[checksum]; ok && blob.DeletingAt != nil && blob.DeletingAt.Before(before) {
+		delete(r.blobs, checksum)
+	}
+	return nil
+}
+
+func (r *fakeRepository) blob(checksum string) (Blob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	blob, ok := r.blobs[checksum]
+	return blob, ok
+}
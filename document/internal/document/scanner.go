@@ -0,0 +1,289 @@
+package document
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// ScanStatus - состояние антивирусной проверки содержимого.
+// Пустое значение у документов, загруженных до появления проверки, считается clean.
+type ScanStatus string
+
+const (
+	ScanPending  ScanStatus = "pending"
+	ScanClean    ScanStatus = "clean"
+	ScanInfected ScanStatus = "infected"
+)
+
+// IsClean сообщает, можно ли отдавать содержимое пользователю
+func (s ScanStatus) IsClean() bool {
+	return s == "" || s == ScanClean
+}
+
+type ScanResult struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner проверяет содержимое файла на вредоносное ПО
+type Scanner interface {
+	Scan(ctx context.Context, content io.Reader) (ScanResult, error)
+}
+
+// ScanQueue принимает объекты на фоновую проверку
+type ScanQueue interface {
+	Enqueue(objectKey string)
+}
+
+var (
+	ErrScanPending = errors.New("document is being scanned")
+	ErrInfected    = errors.New("document is quarantined: malware detected")
+)
+
+// checkScanStatus запрещает отдавать непроверенное или заражённое содержимое
+func checkScanStatus(status ScanStatus) error {
+	switch {
+	case status.IsClean():
+		return nil
+	case status == ScanInfected:
+		return ErrInfected
+	default:
+		return ErrScanPending
+	}
+}
+
+// clamdChunkSize - размер куска в протоколе INSTREAM
+const clamdChunkSize = 64 * 1024
+
+type clamdScanner struct {
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamdScanner создаёт клиент clamd (протокол INSTREAM поверх TCP)
+func NewClamdScanner(addr string, timeout time.Duration) Scanner {
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	return &clamdScanner{addr: addr, timeout: timeout}
+}
+
+func (c *clamdScanner) Scan(ctx context.Context, content io.Reader) (ScanResult, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return ScanResult{}, err
+	}
+
+	if err := c.stream(conn, content); err != nil {
+		// clamd закрывает соединение при превышении StreamMaxLength, причина будет в ответе
+		if reply, replyErr := readClamdReply(conn); replyErr == nil && reply != "" {
+			return parseClamdReply(reply)
+		}
+		return ScanResult{}, fmt.Errorf("send to clamd: %w", err)
+	}
+
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("read clamd reply: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+func (c *clamdScanner) stream(conn net.Conn, content io.Reader) error {
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	var size [4]byte
+	buf := make([]byte, clamdChunkSize)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := conn.Write(size[:]); err != nil {
+				return err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return readErr
+		}
+	}
+
+	// Кусок нулевой длины завершает поток
+	binary.BigEndian.PutUint32(size[:], 0)
+	_, err := conn.Write(size[:])
+	return err
+}
+
+func readClamdReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// parseClamdReply разбирает ответ вида "stream: OK", "stream: <сигнатура> FOUND" или "... ERROR"
+func parseClamdReply(reply string) (ScanResult, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return ScanResult{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// eicarSignature - стандартная тестовая строка антивирусов
+var eicarSignature = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+type stubScanner struct{}
+
+// NewStubScanner создаёт сканер для локального запуска и тестов: заражённым
+// считается только файл с тестовой строкой EICAR, остальные - чистыми
+func NewStubScanner() Scanner {
+	return stubScanner{}
+}
+
+func (stubScanner) Scan(_ context.Context, content io.Reader) (ScanResult, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	if bytes.Contains(data, eicarSignature) {
+		return ScanResult{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return ScanResult{}, nil
+}
+
+// ScanWorker последовательно проверяет загруженные объекты. Новые загрузки приходят
+// через Enqueue, а по таймеру подбираются объекты, оставшиеся в pending после
+// ошибок сканера или перезапуска сервиса.
+type ScanWorker struct {
+	repo     Repository
+	storage  ObjectStorage
+	keys     *Keyring
+	scanner  Scanner
+	interval time.Duration
+	queue    chan string
+}
+
+const (
+	scanQueueSize    = 256
+	scanBatchSize    = 50
+	scanTimeout      = 5 * time.Minute
+	defaultScanRetry = time.Minute
+)
+
+func NewScanWorker(repo Repository, storage ObjectStorage, keys *Keyring, scanner Scanner, interval time.Duration) *ScanWorker {
+	if interval <= 0 {
+		interval = defaultScanRetry
+	}
+	return &ScanWorker{
+		repo:     repo,
+		storage:  storage,
+		keys:     keys,
+		scanner:  scanner,
+		interval: interval,
+		queue:    make(chan string, scanQueueSize),
+	}
+}
+
+// Enqueue не блокирует загрузку: при переполненной очереди объект
+// будет проверен при следующем проходе по pending
+func (w *ScanWorker) Enqueue(objectKey string) {
+	select {
+	case w.queue <- objectKey:
+	default:
+	}
+}
+
+func (w *ScanWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.scanPending(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case objectKey := <-w.queue:
+			w.scanObject(ctx, objectKey)
+		case <-ticker.C:
+			w.scanPending(ctx)
+		}
+	}
+}
+
+func (w *ScanWorker) scanPending(ctx context.Context) {
+	objectKeys, err := w.repo.FindPendingScans(ctx, scanBatchSize)
+	if err != nil {
+		log.Printf("scan worker: find pending: %v", err)
+		return
+	}
+	for _, objectKey := range objectKeys {
+		if ctx.Err() != nil {
+			return
+		}
+		w.scanObject(ctx, objectKey)
+	}
+}
+
+func (w *ScanWorker) scanObject(ctx context.Context, objectKey string) {
+	scanCtx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+
+	key, err := objectEncryptionKey(scanCtx, w.repo, objectKey)
+	if err != nil {
+		log.Printf("scan worker: find key for %s: %v", objectKey, err)
+		return
+	}
+	reader, _, err := openObject(scanCtx, w.storage, w.keys, objectKey, key)
+	if err != nil {
+		log.Printf("scan worker: open %s: %v", objectKey, err)
+		return
+	}
+	result, err := w.scanner.Scan(scanCtx, reader)
+	reader.Close()
+	if err != nil {
+		// Остаётся pending и будет проверен повторно
+		log.Printf("scan worker: scan %s: %v", objectKey, err)
+		return
+	}
+
+	status := ScanClean
+	if result.Infected {
+		status = ScanInfected
+		log.Printf("scan worker: %s quarantined: %s", objectKey, result.Signature)
+	}
+	if err := w.repo.SetScanStatus(scanCtx, objectKey, status, result.Signature); err != nil {
+		log.Printf("scan worker: save status for %s: %v", objectKey, err)
+	}
+}
@@ -0,0 +1,104 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestStorage(t *testing.T) ObjectStorage {
+	t.Helper()
+	storage, _, err := NewFilesystemStorage(t.TempDir(), "http://localhost", []byte("test-secret"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func TestStubScannerUpload(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantStatus ScanStatus
+		wantErr    error
+	}{
+		{"clean file", "quarterly report\n", ScanClean, nil},
+		{"eicar file", string(eicarSignature) + "\n", ScanInfected, ErrInfected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newFakeRepository()
+			storage := newTestStorage(t)
+			worker := NewScanWorker(repo, storage, nil, NewStubScanner(), time.Hour)
+			svc := NewService(Dependencies{Repo: repo, Storage: storage, Scans: worker, SpoolDir: t.TempDir()})
+
+			ownerID := uuid.NewString()
+			requester := Requester{UserID: ownerID, Role: RoleEmployee}
+			doc, err := svc.AddDocument(ctx, AddDocumentInput{
+				TaskID:      uuid.NewString(),
+				OwnerID:     ownerID,
+				Filename:    "report.txt",
+				ContentType: "text/plain",
+				Content:     strings.NewReader(tt.content),
+				Size:        int64(len(tt.content)),
+				MaxSize:     1 << 20,
+			}, requester)
+			if err != nil {
+				t.Fatalf("AddDocument: %v", err)
+			}
+			if doc.ScanStatus != ScanPending {
+				t.Fatalf("scan status after upload = %q, want %q", doc.ScanStatus, ScanPending)
+			}
+			if _, _, err := svc.GetDocument(ctx, doc.ID.Hex(), 0, requester); !errors.Is(err, ErrScanPending) {
+				t.Fatalf("GetDocument before scan = %v, want ErrScanPending", err)
+			}
+
+			select {
+			case objectKey := <-worker.queue:
+				if objectKey != doc.MinioObject {
+					t.Fatalf("enqueued %q, want %q", objectKey, doc.MinioObject)
+				}
+				worker.scanObject(ctx, objectKey)
+			default:
+				t.Fatal("upload was not enqueued for scanning")
+			}
+
+			scanned, err := repo.FindByID(ctx, doc.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scanned.ScanStatus != tt.wantStatus {
+				t.Fatalf("scan status = %q, want %q", scanned.ScanStatus, tt.wantStatus)
+			}
+			version, err := repo.FindVersion(ctx, doc.ID, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version.ScanStatus != tt.wantStatus {
+				t.Fatalf("version scan status = %q, want %q", version.ScanStatus, tt.wantStatus)
+			}
+
+			_, reader, err := svc.OpenDocument(ctx, doc.ID.Hex(), 0, requester)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OpenDocument = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer reader.Close()
+			content, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.content {
+				t.Fatalf("content = %q, want %q", content, tt.content)
+			}
+		})
+	}
+}
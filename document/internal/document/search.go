@@ -0,0 +1,247 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+	// snippetRadius - сколько символов текста показывать по обе стороны от первого совпадения
+	snippetRadius = 80
+)
+
+// searchSortFields - поля, по которым разрешена сортировка (для каждого есть подходящий индекс или он дешёв)
+var searchSortFields = map[string]bool{
+	"uploaded_at": true,
+	"filename":    true,
+	"size":        true,
+}
+
+var ErrInvalidSearch = errors.New("invalid search parameters")
+
+type SearchInput struct {
+	Tags         []string
+	MatchAllTags bool
+	Filename     string
+	ContentType  string
+	UploadedFrom time.Time
+	UploadedTo   time.Time
+	TaskID       string
+	OwnerID      string
+	Content      string
+	Page         int
+	PageSize     int
+	SortBy       string
+	SortDesc     bool
+}
+
+// SearchMatch - найденный документ. Score и Snippet заполняются только при поиске по содержимому.
+type SearchMatch struct {
+	Metadata
+	Score   float64
+	Snippet string
+}
+
+type SearchResult struct {
+	Documents []SearchMatch
+	Total     int64
+	Page      int
+	PageSize  int
+}
+
+func (s *service) SearchDocuments(ctx context.Context, input SearchInput, requester Requester) (SearchResult, error) {
+	if input.TaskID != "" {
+		if _, err := uuid.Parse(input.TaskID); err != nil {
+			return SearchResult{}, ErrInvalidTaskID
+		}
+	}
+	if input.OwnerID != "" {
+		if _, err := uuid.Parse(input.OwnerID); err != nil {
+			return SearchResult{}, ErrInvalidOwnerID
+		}
+	}
+
+	// Сотрудник ищет только среди своих документов, как и в GetDocumentsByOwner.
+	// Поиск по содержимому тем самым не раскрывает текст документов, недоступных через GetDocument.
+	if requester.Role != RoleAdmin {
+		if input.OwnerID != "" && input.OwnerID != requester.UserID {
+			return SearchResult{}, ErrForbidden
+		}
+		input.OwnerID = requester.UserID
+	}
+
+	if !input.UploadedFrom.IsZero() && !input.UploadedTo.IsZero() && !input.UploadedFrom.Before(input.UploadedTo) {
+		return SearchResult{}, ErrInvalidSearch
+	}
+
+	content := strings.TrimSpace(input.Content)
+	sortBy, sortDesc := input.SortBy, input.SortDesc
+	switch {
+	case sortBy == "" && content != "":
+		sortBy = sortByRelevance
+	case sortBy == "":
+		sortBy, sortDesc = "uploaded_at", true
+	case sortBy == sortByRelevance && content == "":
+		return SearchResult{}, ErrInvalidSearch
+	case sortBy != sortByRelevance && !searchSortFields[sortBy]:
+		return SearchResult{}, ErrInvalidSearch
+	}
+
+	page := input.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := input.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultSearchPageSize
+	}
+	if pageSize > maxSearchPageSize {
+		pageSize = maxSearchPageSize
+	}
+
+	tags := make([]string, 0, len(input.Tags))
+	for _, tag := range input.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	hits, total, err := s.repo.Search(ctx, SearchFilter{
+		Tags:         tags,
+		MatchAllTags: input.MatchAllTags,
+		Filename:     strings.TrimSpace(input.Filename),
+		ContentType:  input.ContentType,
+		UploadedFrom: input.UploadedFrom,
+		UploadedTo:   input.UploadedTo,
+		TaskID:       input.TaskID,
+		OwnerID:      input.OwnerID,
+		Content:      content,
+		SortBy:       sortBy,
+		SortDesc:     sortDesc,
+		Skip:         int64(page-1) * int64(pageSize),
+		Limit:        int64(pageSize),
+	})
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	matches := make([]SearchMatch, 0, len(hits))
+	for _, hit := range hits {
+		match := SearchMatch{Metadata: hit.Metadata, Score: hit.Score}
+		if content != "" {
+			match.Snippet = buildSnippet(hit.ContentText, content)
+		}
+		matches = append(matches, match)
+	}
+
+	return SearchResult{
+		Documents: matches,
+		Total:     total,
+		Page:      page,
+		PageSize:  pageSize,
+	}, nil
+}
+
+// buildSnippet вырезает из текста фрагмент вокруг первого совпадения с запросом
+// и оборачивает найденные слова в <mark>. Остальной текст экранируется для HTML.
+func buildSnippet(text, query string) string {
+	if text == "" {
+		return ""
+	}
+
+	terms := snippetTerms(query)
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	first := -1
+	for _, term := range terms {
+		if idx := indexRunes(lower, term); idx >= 0 && (first < 0 || idx < first) {
+			first = idx
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	from := max(first-snippetRadius, 0)
+	to := min(first+snippetRadius, len(runes))
+
+	var builder strings.Builder
+	if from > 0 {
+		builder.WriteString("…")
+	}
+	for i := from; i < to; {
+		matched := 0
+		for _, term := range terms {
+			if len(term) > matched && hasRunePrefix(lower[i:], term) {
+				matched = len(term)
+			}
+		}
+		if matched > 0 {
+			builder.WriteString("<mark>")
+			builder.WriteString(html.EscapeString(string(runes[i : i+matched])))
+			builder.WriteString("</mark>")
+			i += matched
+			continue
+		}
+		builder.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if to < len(runes) {
+		builder.WriteString("…")
+	}
+	return builder.String()
+}
+
+// snippetTerms разбирает запрос $text на слова для подсветки: кавычки фраз
+// отбрасываются, исключённые через "-" слова не подсвечиваются
+func snippetTerms(query string) [][]rune {
+	var terms [][]rune
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		word = strings.Trim(word, `"`)
+		if word == "" {
+			continue
+		}
+		term := []rune(word)
+		for i, r := range term {
+			term[i] = unicode.ToLower(r)
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+func indexRunes(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if hasRunePrefix(s[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	if len(prefix) == 0 || len(s) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
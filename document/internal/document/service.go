@@ -1,9 +1,14 @@
 package document
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,23 +23,62 @@ const (
 )
 
 type Service interface {
-	AddDocument(ctx context.Context, input AddDocumentInput) (Metadata, []byte, error)
+	AddDocument(ctx context.Context, input AddDocumentInput, requester Requester) (Metadata, error)
 	DeleteDocument(ctx context.Context, id string, requester Requester) error
-	GetDocument(ctx context.Context, id string, requester Requester) (Metadata, []byte, error)
+	// version = 0 означает текущую версию
+	GetDocument(ctx context.Context, id string, version int, requester Requester) (Metadata, []byte, error)
+	OpenDocument(ctx context.Context, id string, version int, requester Requester) (Metadata, io.ReadCloser, error)
 	GetDocumentsByTask(ctx context.Context, taskID string, requester Requester) ([]Metadata, error)
 	GetDocumentsByOwner(ctx context.Context, ownerID string, requester Requester) ([]Metadata, error)
+	CreateUploadURL(ctx context.Context, input UploadURLInput, requester Requester) (PresignedURL, string, error)
+	CompleteUpload(ctx context.Context, input CompleteUploadInput, requester Requester) (Metadata, error)
+	CreateDownloadURL(ctx context.Context, id string, version int, requester Requester) (PresignedURL, error)
+	ListVersions(ctx context.Context, id string, requester Requester) (Metadata, []Version, error)
+	RestoreVersion(ctx context.Context, id string, version int, requester Requester) (Metadata, error)
+	SearchDocuments(ctx context.Context, input SearchInput, requester Requester) (SearchResult, error)
+	ReconcileStorage(ctx context.Context, deleteOrphans bool, requester Requester) (ReconcileReport, error)
+	// ownerID пустой - корзина requester (для admin - всех пользователей)
+	ListTrash(ctx context.Context, ownerID string, requester Requester) ([]Metadata, error)
+	RestoreDocument(ctx context.Context, id string, requester Requester) (Metadata, error)
 }
 
 type AddDocumentInput struct {
+	// DocumentID задан, если загружается новая версия существующего документа
+	DocumentID  string
 	TaskID      string
 	OwnerID     string
 	Filename    string
 	ContentType string
 	Tags        []string
-	Content     []byte
+	Content     io.Reader
+	Size        int64 // -1, если размер заранее неизвестен (потоковая загрузка)
 	MaxSize     int64
 }
 
+type UploadURLInput struct {
+	TaskID      string
+	OwnerID     string
+	Filename    string
+	ContentType string
+}
+
+type CompleteUploadInput struct {
+	ObjectKey   string
+	DocumentID  string
+	TaskID      string
+	OwnerID     string
+	Filename    string
+	ContentType string
+	Tags        []string
+	MaxSize     int64
+}
+
+// PresignedURL - короткоживущая ссылка на объект в MinIO
+type PresignedURL struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
 type Requester struct {
 	UserID string
 	Role   Role
@@ -49,77 +93,176 @@ var (
 	ErrInvalidOwnerID     = errors.New("invalid owner id")
 	ErrEmptyFilename      = errors.New("filename required")
 	ErrEmptyContent       = errors.New("file content required")
+	ErrInvalidObjectKey   = errors.New("invalid object key")
+	ErrUploadCompleted    = errors.New("upload already completed")
+	ErrInvalidVersion     = errors.New("invalid document version")
 )
 
+// Dependencies - зависимости и настройки сервиса документов
+type Dependencies struct {
+	Repo    Repository
+	Storage ObjectStorage
+	// Validator проверяет загружаемые файлы; нулевое значение - списки по умолчанию
+	Validator     Validator
+	PresignExpiry time.Duration
+	// Scans - очередь антивирусной проверки; nil отключает проверку
+	Scans ScanQueue
+	// Tasks - сервис задач; nil отключает проверку задачи при загрузке
+	Tasks TaskLookup
+	// AttachableStatuses - статусы задач, к которым можно прикладывать документы;
+	// пустой список - только COMPLETED
+	AttachableStatuses []string
+	// Managers определяет руководителя исполнителя задачи; nil - руководитель доступа не получает
+	Managers ManagerResolver
+	// Keys - мастер-ключи шифрования содержимого; nil - новые файлы хранятся открыто
+	Keys *Keyring
+	// SpoolDir - каталог временных файлов загрузки; пустая строка - системный
+	SpoolDir string
+	// TaskCacheTTL - время жизни кеша участников задач; 0 - одна минута
+	TaskCacheTTL time.Duration
+	// Reconciler сверяет хранилище с метаданными; nil - сверка с настройками по умолчанию
+	Reconciler *Reconciler
+	// Events публикует события документов; nil - события не отправляются
+	Events EventPublisher
+}
+
 type service struct {
-	repo    Repository
-	storage ObjectStorage
+	repo          Repository
+	storage       ObjectStorage
+	validator     Validator
+	presignExpiry time.Duration
+	scans         ScanQueue
+	tasks         TaskLookup
+	managers      ManagerResolver
+	taskCache     *ttlCache[TaskInfo]
+	managerCache  *ttlCache[string]
+	reconciler    *Reconciler
+	blobs         blobStore
+	keys          *Keyring
+	spoolDir      string
+	events        EventPublisher
+	// attachableStatuses - статусы задач, допускающие вложения
+	attachableStatuses map[string]bool
+	// textSlots ограничивает число одновременных фоновых извлечений текста
+	textSlots chan struct{}
 }
 
 var errMaxSizeNotSpecified = errors.New("max file size not specified")
 
-func NewService(repo Repository, storage ObjectStorage) Service {
+// uploadTimeout ограничивает время записи в хранилище. Потоковая загрузка
+// читает данные клиента прямо во время PutObject, поэтому запас больше, чем для чтения.
+const uploadTimeout = 5 * time.Minute
+
+func NewService(deps Dependencies) Service {
+	presignExpiry := deps.PresignExpiry
+	if presignExpiry <= 0 {
+		presignExpiry = 15 * time.Minute
+	}
+	validator := deps.Validator
+	if validator.extensions == nil {
+		validator = defaultValidator
+	}
+	statuses := deps.AttachableStatuses
+	if len(statuses) == 0 {
+		statuses = defaultAttachableStatuses
+	}
+	taskCacheTTL := deps.TaskCacheTTL
+	if taskCacheTTL <= 0 {
+		taskCacheTTL = time.Minute
+	}
+	reconciler := deps.Reconciler
+	if reconciler == nil {
+		reconciler = NewReconciler(deps.Repo, deps.Storage, 0)
+	}
+	attachable := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		attachable[strings.ToUpper(status)] = true
+	}
 	return &service{
-		repo:    repo,
-		storage: storage,
+		repo:               deps.Repo,
+		storage:            deps.Storage,
+		validator:          validator,
+		presignExpiry:      presignExpiry,
+		scans:              deps.Scans,
+		tasks:              deps.Tasks,
+		managers:           deps.Managers,
+		taskCache:          newTTLCache[TaskInfo](taskCacheTTL),
+		managerCache:       newTTLCache[string](taskCacheTTL),
+		reconciler:         reconciler,
+		blobs:              blobStore{repo: deps.Repo, storage: deps.Storage, keys: deps.Keys},
+		keys:               deps.Keys,
+		spoolDir:           deps.SpoolDir,
+		events:             deps.Events,
+		attachableStatuses: attachable,
+		textSlots:          make(chan struct{}, textExtractionWorkers),
 	}
 }
 
-func (s *service) AddDocument(ctx context.Context, input AddDocumentInput) (Metadata, []byte, error) {
+func (s *service) AddDocument(ctx context.Context, input AddDocumentInput, requester Requester) (Metadata, error) {
 	if input.MaxSize <= 0 {
-		return Metadata{}, nil, errMaxSizeNotSpecified
+		return Metadata{}, errMaxSizeNotSpecified
 	}
-	if input.Filename == "" {
-		return Metadata{}, nil, ErrEmptyFilename
+	filename, err := s.checkFile(input.Filename, input.ContentType)
+	if err != nil {
+		return Metadata{}, err
 	}
-	if input.ContentType == "" {
-		return Metadata{}, nil, ErrInvalidContentType
+	if input.Content == nil || input.Size == 0 {
+		return Metadata{}, ErrEmptyContent
 	}
-	if len(input.Content) == 0 {
-		return Metadata{}, nil, ErrEmptyContent
+	if input.Size > input.MaxSize {
+		return Metadata{}, ErrFileTooLarge
 	}
-	if int64(len(input.Content)) > input.MaxSize {
-		return Metadata{}, nil, ErrFileTooLarge
+	target, err := s.uploadTarget(ctx, input.DocumentID, input.TaskID, input.OwnerID, requester)
+	if err != nil {
+		return Metadata{}, err
 	}
-	if _, err := uuid.Parse(input.TaskID); err != nil {
-		return Metadata{}, nil, ErrInvalidTaskID
+
+	// Тип определяется по первым байтам до записи в хранилище
+	buffered := bufio.NewReader(input.Content)
+	head, err := buffered.Peek(sniffLen)
+	if len(head) == 0 {
+		if err == nil || errors.Is(err, io.EOF) {
+			return Metadata{}, ErrEmptyContent
+		}
+		return Metadata{}, err
 	}
-	if _, err := uuid.Parse(input.OwnerID); err != nil {
-		return Metadata{}, nil, ErrInvalidOwnerID
+	if err := s.validator.CheckContent(filename, head); err != nil {
+		return Metadata{}, err
 	}
+	content := &maxSizeReader{reader: buffered, remaining: input.MaxSize}
 
-	saveCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	saveCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
 	defer cancel()
-	objectKey, checksum, size, err := s.storage.Save(saveCtx, input.Filename, input.ContentType, input.Content)
+	blob, err := s.storeContent(saveCtx, input.ContentType, content)
 	if err != nil {
-		return Metadata{}, nil, err
-	}
-
-	metadata := Metadata{
-		TaskID:      input.TaskID,
-		OwnerID:     input.OwnerID,
-		Filename:    input.Filename,
-		ContentType: input.ContentType,
-		Size:        size,
-		Tags:        input.Tags,
-		MinioObject: objectKey,
-		MinioBucket: s.storage.Bucket(),
-		Checksum:    checksum,
+		if content.exceeded {
+			return Metadata{}, ErrFileTooLarge
+		}
+		return Metadata{}, err
 	}
 
-	insertCtx, cancelInsert := context.WithTimeout(ctx, 10*time.Second)
-	defer cancelInsert()
-
-	insertID, err := s.repo.Insert(insertCtx, metadata)
+	metadata, err := s.attachObject(ctx, target, Metadata{
+		TaskID:        input.TaskID,
+		OwnerID:       input.OwnerID,
+		Filename:      filename,
+		ContentType:   input.ContentType,
+		Size:          blob.Size,
+		Tags:          input.Tags,
+		MinioObject:   blob.ObjectKey,
+		MinioBucket:   s.storage.Bucket(),
+		Checksum:      blob.Checksum,
+		EncryptionKey: blob.EncryptionKey,
+	}, "")
 	if err != nil {
-		_ = s.storage.Delete(context.Background(), objectKey)
-		return Metadata{}, nil, err
+		s.releaseObjects(blob.ObjectKey)
+		return Metadata{}, err
 	}
-	metadata.ID = insertID
 
-	return metadata, input.Content, nil
+	return metadata, nil
 }
 
+// DeleteDocument перемещает документ в корзину. Содержимое удаляет PurgeWorker
+// по истечении срока хранения.
 func (s *service) DeleteDocument(ctx context.Context, id string, requester Requester) error {
 	objectID, err := parseObjectID(id)
 	if err != nil {
@@ -135,57 +278,54 @@ func (s *service) DeleteDocument(ctx context.Context, id string, requester Reque
 		return ErrForbidden
 	}
 
-	if err := s.repo.Delete(ctx, objectID); err != nil {
+	if err := s.repo.SoftDelete(ctx, objectID, requester.UserID, time.Now()); err != nil {
 		return err
 	}
-
-	go func(objectKey string) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		_ = s.storage.Delete(ctx, objectKey)
-	}(doc.MinioObject)
-
+	s.publish(EventDocumentDeleted, doc, requester.UserID)
 	return nil
 }
 
-func (s *service) GetDocument(ctx context.Context, id string, requester Requester) (Metadata, []byte, error) {
-	objectID, err := parseObjectID(id)
-	if err != nil {
-		return Metadata{}, nil, ErrInvalidDocumentID
-	}
+func (s *service) GetDocument(ctx context.Context, id string, version int, requester Requester) (Metadata, []byte, error) {
+	readCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-	doc, err := s.repo.FindByID(ctx, objectID)
+	doc, reader, err := s.OpenDocument(readCtx, id, version, requester)
 	if err != nil {
 		return Metadata{}, nil, err
 	}
+	defer reader.Close()
 
-	if !requester.CanAccessDocument(doc) {
-		return Metadata{}, nil, ErrForbidden
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return Metadata{}, nil, err
 	}
 
-	readCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+	return doc, content, nil
+}
 
-	reader, _, err := s.storage.Get(readCtx, doc.MinioObject)
+// OpenDocument проверяет права и открывает содержимое документа для потокового чтения.
+// Reader живёт, пока жив ctx; закрыть его обязан вызывающий. Если содержимое не совпало
+// с контрольной суммой, в конце потока вместо io.EOF возвращается ErrChecksumMismatch.
+func (s *service) OpenDocument(ctx context.Context, id string, version int, requester Requester) (Metadata, io.ReadCloser, error) {
+	doc, err := s.findVersion(ctx, id, version, requester)
 	if err != nil {
 		return Metadata{}, nil, err
 	}
-	defer reader.Close()
 
-	content, err := io.ReadAll(reader)
+	reader, err := s.openVerified(ctx, doc)
 	if err != nil {
 		return Metadata{}, nil, err
 	}
 
-	return doc, content, nil
+	return doc, reader, nil
 }
 
 func (s *service) GetDocumentsByTask(ctx context.Context, taskID string, requester Requester) ([]Metadata, error) {
 	if _, err := uuid.Parse(taskID); err != nil {
 		return nil, ErrInvalidTaskID
 	}
-	if requester.Role != RoleAdmin {
-		return nil, ErrForbidden
+	if err := s.authorizeTaskAccess(ctx, taskID, requester); err != nil {
+		return nil, err
 	}
 	return s.repo.FindByTask(ctx, taskID)
 }
@@ -200,6 +340,187 @@ func (s *service) GetDocumentsByOwner(ctx context.Context, ownerID string, reque
 	return s.repo.FindByOwner(ctx, ownerID)
 }
 
+func (s *service) CreateUploadURL(ctx context.Context, input UploadURLInput, requester Requester) (PresignedURL, string, error) {
+	filename, err := s.checkFile(input.Filename, input.ContentType)
+	if err != nil {
+		return PresignedURL{}, "", err
+	}
+	if _, err := uuid.Parse(input.TaskID); err != nil {
+		return PresignedURL{}, "", ErrInvalidTaskID
+	}
+	if _, err := uuid.Parse(input.OwnerID); err != nil {
+		return PresignedURL{}, "", ErrInvalidOwnerID
+	}
+	if requester.Role != RoleAdmin && requester.UserID != input.OwnerID {
+		return PresignedURL{}, "", ErrForbidden
+	}
+	// Проверяем задачу до загрузки, чтобы клиент не передавал файл впустую;
+	// CompleteUpload проверит её ещё раз
+	if err := s.checkTask(ctx, input.TaskID, input.OwnerID, requester); err != nil {
+		return PresignedURL{}, "", err
+	}
+
+	objectKey := NewObjectKey(filename)
+	expiresAt := time.Now().Add(s.presignExpiry)
+	uploadURL, err := s.storage.PresignPut(ctx, objectKey, s.presignExpiry)
+	if err != nil {
+		return PresignedURL{}, "", err
+	}
+
+	return PresignedURL{URL: uploadURL, ExpiresAt: expiresAt}, objectKey, nil
+}
+
+func (s *service) CompleteUpload(ctx context.Context, input CompleteUploadInput, requester Requester) (Metadata, error) {
+	if input.MaxSize <= 0 {
+		return Metadata{}, errMaxSizeNotSpecified
+	}
+	if !isUploadObjectKey(input.ObjectKey) {
+		return Metadata{}, ErrInvalidObjectKey
+	}
+	filename, err := s.checkFile(input.Filename, input.ContentType)
+	if err != nil {
+		return Metadata{}, err
+	}
+	target, err := s.uploadTarget(ctx, input.DocumentID, input.TaskID, input.OwnerID, requester)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	// Ключ объекта случайный и известен только тому, кто запросил URL,
+	// но один объект всё равно нельзя привязать к двум документам или версиям.
+	// До дедупликации объект загрузки сам становился объектом документа.
+	// Проверка лишь отсекает повтор заранее: параллельный вызов остановит уникальный
+	// индекс версий по upload_object, и InsertVersion вернёт ErrUploadCompleted.
+	referenced, err := s.repo.IsObjectReferenced(ctx, input.ObjectKey)
+	if err != nil {
+		return Metadata{}, err
+	}
+	completed, err := s.repo.IsUploadCompleted(ctx, input.ObjectKey)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if referenced || completed {
+		return Metadata{}, ErrUploadCompleted
+	}
+
+	size, err := s.storage.Stat(ctx, input.ObjectKey)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if size == 0 {
+		s.removeObjects(input.ObjectKey)
+		return Metadata{}, ErrEmptyContent
+	}
+	if size > input.MaxSize {
+		s.removeObjects(input.ObjectKey)
+		return Metadata{}, ErrFileTooLarge
+	}
+
+	checksum, head, err := s.inspectObject(ctx, input.ObjectKey)
+	if err != nil {
+		return Metadata{}, err
+	}
+	// Клиент загружал в MinIO напрямую, поэтому содержимое проверяем только сейчас
+	if err := s.validator.CheckContent(filename, head); err != nil {
+		s.removeObjects(input.ObjectKey)
+		return Metadata{}, err
+	}
+
+	blob, err := s.adoptUpload(ctx, input.ObjectKey, checksum, size)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	metadata, err := s.attachObject(ctx, target, Metadata{
+		TaskID:        input.TaskID,
+		OwnerID:       input.OwnerID,
+		Filename:      filename,
+		ContentType:   input.ContentType,
+		Size:          size,
+		Tags:          input.Tags,
+		MinioObject:   blob.ObjectKey,
+		MinioBucket:   s.storage.Bucket(),
+		Checksum:      checksum,
+		EncryptionKey: blob.EncryptionKey,
+	}, input.ObjectKey)
+	if err != nil {
+		s.releaseObjects(blob.ObjectKey)
+		return Metadata{}, err
+	}
+	return metadata, nil
+}
+
+func (s *service) CreateDownloadURL(ctx context.Context, id string, version int, requester Requester) (PresignedURL, error) {
+	doc, err := s.findVersion(ctx, id, version, requester)
+	if err != nil {
+		return PresignedURL{}, err
+	}
+
+	if doc.EncryptionKey != nil {
+		return PresignedURL{}, ErrEncryptedContent
+	}
+
+	expiresAt := time.Now().Add(s.presignExpiry)
+	downloadURL, err := s.storage.PresignGet(ctx, doc.MinioObject, doc.Filename, doc.ContentType, s.presignExpiry)
+	if err != nil {
+		return PresignedURL{}, err
+	}
+
+	return PresignedURL{URL: downloadURL, ExpiresAt: expiresAt}, nil
+}
+
+// checkFile проверяет имя и заявленный тип загружаемого файла и возвращает очищенное имя
+func (s *service) checkFile(filename, contentType string) (string, error) {
+	if filename == "" {
+		return "", ErrEmptyFilename
+	}
+	if contentType == "" {
+		return "", ErrInvalidContentType
+	}
+	if err := s.validator.Validate(filename, contentType); err != nil {
+		return "", err
+	}
+	return SanitizeFilename(filename), nil
+}
+
+// inspectObject читает объект из хранилища, считает его SHA-256 и запоминает первые байты для проверки типа
+func (s *service) inspectObject(ctx context.Context, objectKey string) (string, []byte, error) {
+	readCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	defer cancel()
+
+	reader, _, err := s.storage.Get(readCtx, objectKey)
+	if err != nil {
+		return "", nil, err
+	}
+	defer reader.Close()
+
+	buffered := bufio.NewReader(reader)
+	head, err := buffered.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", nil, err
+	}
+	head = append([]byte(nil), head...)
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, buffered); err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), head, nil
+}
+
+// isUploadObjectKey проверяет, что ключ выдан CreateUploadURL: documents/<uuid>[.ext]
+func isUploadObjectKey(objectKey string) bool {
+	if !strings.HasPrefix(objectKey, objectKeyPrefix) {
+		return false
+	}
+	name := strings.TrimPrefix(objectKey, objectKeyPrefix)
+	if strings.Contains(name, "/") {
+		return false
+	}
+	_, err := uuid.Parse(strings.TrimSuffix(name, path.Ext(name)))
+	return err == nil
+}
+
 func parseObjectID(id string) (primitive.ObjectID, error) {
 	return primitive.ObjectIDFromHex(id)
 }
@@ -217,3 +538,20 @@ func (r Requester) CanAccessDocument(doc Metadata) bool {
 	}
 	return r.UserID == doc.OwnerID
 }
+
+// maxSizeReader возвращает ошибку, как только прочитано больше remaining байт
+type maxSizeReader struct {
+	reader    io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		r.exceeded = true
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
@@ -1,12 +1,11 @@
 package document
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
@@ -18,22 +17,64 @@ import (
 
 type minioStorage struct {
 	client     *minio.Client
+	presigner  *minio.Client
 	bucketName string
 }
 
-func hashSHA256(data []byte) string {
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:])
-}
+// objectKeyPrefix - префикс, под которым хранятся все документы в бакете
+const objectKeyPrefix = "documents/"
+
+var ErrObjectNotFound = errors.New("object not found")
 
 type ObjectStorage interface {
-	Save(ctx context.Context, filename string, contentType string, data []byte) (objectKey string, checksum string, size int64, err error)
+	// Put записывает объект под заданным ключом, перезаписывая существующий
+	Put(ctx context.Context, objectKey string, contentType string, reader io.Reader, size int64) error
+	// Copy копирует объект внутри бакета
+	Copy(ctx context.Context, srcKey, dstKey string) error
 	Delete(ctx context.Context, objectKey string) error
 	Get(ctx context.Context, objectKey string) (io.ReadCloser, int64, error)
+	// Stat возвращает размер объекта или ErrObjectNotFound
+	Stat(ctx context.Context, objectKey string) (int64, error)
+	PresignPut(ctx context.Context, objectKey string, expiry time.Duration) (string, error)
+	// PresignGet задаёт имя и тип файла в ответе: одно содержимое может принадлежать разным документам
+	PresignGet(ctx context.Context, objectKey string, filename string, contentType string, expiry time.Duration) (string, error)
+	// ListObjects перебирает все объекты с ключами, начинающимися с prefix
+	ListObjects(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
 	Bucket() string
 }
 
-func NewMinioStorage(endpoint, accessKey, secretKey string, useSSL bool, bucket string) (ObjectStorage, error) {
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// blobKeyPrefix - префикс объектов, адресуемых по SHA-256 содержимого
+const blobKeyPrefix = objectKeyPrefix + "sha256/"
+
+// BlobObjectKey возвращает ключ объекта с содержимым, имеющим такую контрольную сумму
+func BlobObjectKey(checksum string) string {
+	return blobKeyPrefix + checksum
+}
+
+// blobChecksum извлекает контрольную сумму из ключа; ok == false для объектов,
+// загруженных до дедупликации, и для временных объектов presigned загрузки
+func blobChecksum(objectKey string) (string, bool) {
+	if !strings.HasPrefix(objectKey, blobKeyPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(objectKey, blobKeyPrefix), true
+}
+
+// NewObjectKey генерирует новый уникальный ключ объекта с расширением исходного файла
+func NewObjectKey(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return fmt.Sprintf("%s%s%s", objectKeyPrefix, uuid.New().String(), ext)
+}
+
+// NewMinioStorage подключается к MinIO. publicEndpoint (опционально) задаёт адрес,
+// который попадёт в presigned URL, если клиенты ходят в MinIO не по внутреннему адресу.
+func NewMinioStorage(endpoint, accessKey, secretKey string, useSSL bool, bucket, publicEndpoint string) (ObjectStorage, error) {
 	client, err := minio.New(endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
 		Secure: useSSL,
@@ -42,6 +83,19 @@ func NewMinioStorage(endpoint, accessKey, secretKey string, useSSL bool, bucket
 		return nil, err
 	}
 
+	presigner := client
+	if publicEndpoint != "" {
+		// Регион задаём явно: подпись считается локально, без запроса к публичному адресу
+		presigner, err = minio.New(publicEndpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+			Secure: useSSL,
+			Region: "us-east-1",
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -57,28 +111,24 @@ func NewMinioStorage(endpoint, accessKey, secretKey string, useSSL bool, bucket
 
 	return &minioStorage{
 		client:     client,
+		presigner:  presigner,
 		bucketName: bucket,
 	}, nil
 }
 
-func (s *minioStorage) Save(ctx context.Context, filename string, contentType string, data []byte) (string, string, int64, error) {
-	objectID := uuid.New().String()
-	ext := strings.ToLower(filepath.Ext(filename))
-	objectKey := fmt.Sprintf("documents/%s%s", objectID, ext)
-	reader := bytes.NewReader(data)
-	size := int64(len(data))
-
-	checksum := hashSHA256(data)
-
+func (s *minioStorage) Put(ctx context.Context, objectKey string, contentType string, reader io.Reader, size int64) error {
 	_, err := s.client.PutObject(ctx, s.bucketName, objectKey, reader, size, minio.PutObjectOptions{
-		ContentType:  contentType,
-		UserMetadata: map[string]string{"filename": filename},
+		ContentType: contentType,
 	})
-	if err != nil {
-		return "", "", 0, err
-	}
+	return err
+}
 
-	return objectKey, checksum, size, nil
+func (s *minioStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucketName, Object: dstKey},
+		minio.CopySrcOptions{Bucket: s.bucketName, Object: srcKey},
+	)
+	return err
 }
 
 func (s *minioStorage) Delete(ctx context.Context, objectKey string) error {
@@ -93,11 +143,62 @@ func (s *minioStorage) Get(ctx context.Context, objectKey string) (io.ReadCloser
 	info, err := obj.Stat()
 	if err != nil {
 		_ = obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, 0, ErrObjectNotFound
+		}
 		return nil, 0, err
 	}
 	return obj, info.Size, nil
 }
 
+func (s *minioStorage) Stat(ctx context.Context, objectKey string) (int64, error) {
+	info, err := s.client.StatObject(ctx, s.bucketName, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return 0, ErrObjectNotFound
+		}
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+func (s *minioStorage) PresignPut(ctx context.Context, objectKey string, expiry time.Duration) (string, error) {
+	u, err := s.presigner.PresignedPutObject(ctx, s.bucketName, objectKey, expiry)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *minioStorage) PresignGet(ctx context.Context, objectKey string, filename string, contentType string, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("response-content-disposition", fmt.Sprintf(`attachment; filename="%s"`, EscapeFilename(filename)))
+	if contentType != "" {
+		params.Set("response-content-type", contentType)
+	}
+	u, err := s.presigner.PresignedGetObject(ctx, s.bucketName, objectKey, expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *minioStorage) ListObjects(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	// Отмена контекста останавливает листинг, если fn вернула ошибку
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for obj := range s.client.ListObjects(listCtx, s.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if err := fn(ObjectInfo{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *minioStorage) Bucket() string {
 	return s.bucketName
 }
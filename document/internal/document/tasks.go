@@ -0,0 +1,184 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TaskInfo - сведения о задаче из сервиса задач, нужные для привязки документов
+type TaskInfo struct {
+	ID        string
+	WorkerID  string
+	CreatedBy string
+	Status    string
+}
+
+// TaskLookup получает задачу из сервиса задач.
+// Если задачи нет, возвращает ErrTaskNotFound.
+type TaskLookup interface {
+	GetTask(ctx context.Context, taskID string) (TaskInfo, error)
+}
+
+var (
+	ErrTaskNotFound      = errors.New("task not found")
+	ErrTaskNotAttachable = errors.New("task status does not allow attaching documents")
+	ErrNotTaskWorker     = errors.New("document owner is not the task worker")
+)
+
+// defaultAttachableStatuses - документы прикладываются к выполненным задачам
+var defaultAttachableStatuses = []string{"COMPLETED"}
+
+// checkTask проверяет, что задача существует, владелец документа - её исполнитель
+// (администратор может прикладывать документы за других) и статус задачи допускает вложения
+func (s *service) checkTask(ctx context.Context, taskID, ownerID string, requester Requester) error {
+	if s.tasks == nil {
+		return nil
+	}
+
+	task, err := s.tasks.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if requester.Role != RoleAdmin && task.WorkerID != ownerID {
+		return ErrNotTaskWorker
+	}
+	if !s.attachableStatuses[task.Status] {
+		return ErrTaskNotAttachable
+	}
+	return nil
+}
+
+// ManagerResolver возвращает руководителя сотрудника или пустую строку, если он не назначен
+type ManagerResolver interface {
+	ResolveManager(ctx context.Context, userID string) (string, error)
+}
+
+// TaskAccess - участники задачи, которым доступны её документы
+type TaskAccess struct {
+	WorkerID  string
+	CreatedBy string
+	ManagerID string
+}
+
+// CanAccessTask разрешает читать документы задачи исполнителю, её автору
+// и руководителю исполнителя
+func (r Requester) CanAccessTask(access TaskAccess) bool {
+	if r.Role == RoleAdmin {
+		return true
+	}
+	if r.UserID == "" {
+		return false
+	}
+	return r.UserID == access.WorkerID || r.UserID == access.CreatedBy || r.UserID == access.ManagerID
+}
+
+// authorizeTaskAccess проверяет, что requester участвует в задаче. Задача и руководитель
+// исполнителя кешируются, поэтому переназначение вступает в силу не позже чем через TTL кеша.
+func (s *service) authorizeTaskAccess(ctx context.Context, taskID string, requester Requester) error {
+	if requester.Role == RoleAdmin {
+		return nil
+	}
+	if s.tasks == nil {
+		return ErrForbidden
+	}
+
+	task, err := s.cachedTask(ctx, taskID)
+	if errors.Is(err, ErrTaskNotFound) || errors.Is(err, ErrInvalidTaskID) {
+		return ErrForbidden
+	}
+	if err != nil {
+		return err
+	}
+
+	access := TaskAccess{WorkerID: task.WorkerID, CreatedBy: task.CreatedBy}
+	// Руководителя запрашиваем, только если исполнитель и автор не подошли
+	if !requester.CanAccessTask(access) && s.managers != nil && task.WorkerID != "" {
+		access.ManagerID, err = s.cachedManager(ctx, task.WorkerID)
+		if err != nil {
+			return err
+		}
+	}
+	if !requester.CanAccessTask(access) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *service) cachedTask(ctx context.Context, taskID string) (TaskInfo, error) {
+	if task, ok := s.taskCache.get(taskID); ok {
+		return task, nil
+	}
+	task, err := s.tasks.GetTask(ctx, taskID)
+	if err != nil {
+		return TaskInfo{}, err
+	}
+	s.taskCache.set(taskID, task)
+	return task, nil
+}
+
+func (s *service) cachedManager(ctx context.Context, userID string) (string, error) {
+	if managerID, ok := s.managerCache.get(userID); ok {
+		return managerID, nil
+	}
+	managerID, err := s.managers.ResolveManager(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	s.managerCache.set(userID, managerID)
+	return managerID, nil
+}
+
+// maxCacheEntries ограничивает размер кеша участников задач
+const maxCacheEntries = 10000
+
+// ttlCache - потокобезопасный кеш с временем жизни записей
+type ttlCache[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, entries: make(map[string]ttlEntry[V])}
+}
+
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache[V]) set(key string, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxCacheEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			c.entries = make(map[string]ttlEntry[V])
+		}
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
@@ -0,0 +1,133 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour
+	defaultPurgeInterval  = time.Hour
+	purgeBatchSize        = 100
+)
+
+func (s *service) ListTrash(ctx context.Context, ownerID string, requester Requester) ([]Metadata, error) {
+	if ownerID != "" {
+		if _, err := uuid.Parse(ownerID); err != nil {
+			return nil, ErrInvalidOwnerID
+		}
+	}
+	if requester.Role != RoleAdmin {
+		if ownerID != "" && ownerID != requester.UserID {
+			return nil, ErrForbidden
+		}
+		ownerID = requester.UserID
+	}
+	return s.repo.FindTrash(ctx, ownerID)
+}
+
+func (s *service) RestoreDocument(ctx context.Context, id string, requester Requester) (Metadata, error) {
+	objectID, err := parseObjectID(id)
+	if err != nil {
+		return Metadata{}, ErrInvalidDocumentID
+	}
+
+	doc, err := s.repo.FindDeletedByID(ctx, objectID)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if !requester.CanManageDocument(doc) {
+		return Metadata{}, ErrForbidden
+	}
+
+	if err := s.repo.Restore(ctx, objectID); err != nil {
+		return Metadata{}, err
+	}
+	doc.DeletedAt = nil
+	doc.DeletedBy = ""
+	s.publish(EventDocumentRestored, doc, requester.UserID)
+	return doc, nil
+}
+
+// PurgeWorker безвозвратно удаляет документы, пролежавшие в корзине дольше retention,
+// вместе с версиями и объектами в хранилище
+type PurgeWorker struct {
+	repo      Repository
+	blobs     blobStore
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurgeWorker(repo Repository, storage ObjectStorage, retention, interval time.Duration) *PurgeWorker {
+	if retention <= 0 {
+		retention = defaultTrashRetention
+	}
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+	return &PurgeWorker{
+		repo:      repo,
+		blobs:     blobStore{repo: repo, storage: storage},
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+func (w *PurgeWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.purge(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.purge(ctx)
+		}
+	}
+}
+
+func (w *PurgeWorker) purge(ctx context.Context) {
+	before := time.Now().Add(-w.retention)
+	for ctx.Err() == nil {
+		docs, err := w.repo.FindExpiredTrash(ctx, before, purgeBatchSize)
+		if err != nil {
+			log.Printf("trash purge: find expired: %v", err)
+			return
+		}
+		for _, doc := range docs {
+			w.purgeDocument(ctx, doc, before)
+		}
+		if len(docs) < purgeBatchSize {
+			return
+		}
+	}
+}
+
+func (w *PurgeWorker) purgeDocument(ctx context.Context, doc Metadata, before time.Time) {
+	versions, err := w.repo.FindVersions(ctx, doc.ID)
+	if err != nil {
+		log.Printf("trash purge: document %s: %v", doc.ID.Hex(), err)
+		return
+	}
+
+	// Документ могли восстановить после выборки: тогда DeleteTrashed ничего не удалит
+	if err := w.repo.DeleteTrashed(ctx, doc.ID, before); err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Printf("trash purge: document %s: %v", doc.ID.Hex(), err)
+		}
+		return
+	}
+
+	for _, objectKey := range heldObjects(doc.MinioObject, versions) {
+		// Не удалённые объекты подберёт сверка хранилища
+		if err := w.blobs.release(ctx, objectKey); err != nil {
+			log.Printf("trash purge: release object %s: %v", objectKey, err)
+		}
+	}
+}
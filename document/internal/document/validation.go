@@ -1,13 +1,17 @@
 package document
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"mime"
 	"path/filepath"
 	"strings"
+	"unicode/utf16"
 	"unicode/utf8"
 )
 
+// allowedExtensions и allowedMimeTypes - списки по умолчанию, если в конфиге не заданы свои
 var allowedExtensions = map[string]bool{
 	".pdf":  true,
 	".doc":  true,
@@ -31,14 +35,226 @@ var allowedMimeTypes = map[string]bool{
 	"image/jpeg": true,
 }
 
+// sniffLen - сколько первых байт содержимого нужно для определения типа
+const sniffLen = 512
+
+// Сигнатуры содержимого, определяемые по первым байтам
+const (
+	kindPDF  = "pdf"
+	kindOLE  = "ole" // контейнер старого Office: doc, xls
+	kindZIP  = "zip" // контейнер OOXML: docx, xlsx
+	kindPNG  = "png"
+	kindJPEG = "jpeg"
+	kindText = "text"
+)
+
+// fileType описывает известный формат: допустимые заявленные MIME типы и сигнатуру содержимого
+type fileType struct {
+	mimeTypes []string
+	kind      string
+}
+
+var knownFileTypes = map[string]fileType{
+	".pdf":  {mimeTypes: []string{"application/pdf"}, kind: kindPDF},
+	".doc":  {mimeTypes: []string{"application/msword"}, kind: kindOLE},
+	".docx": {mimeTypes: []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"}, kind: kindZIP},
+	".txt":  {mimeTypes: []string{"text/plain"}, kind: kindText},
+	".xls":  {mimeTypes: []string{"application/vnd.ms-excel"}, kind: kindOLE},
+	".xlsx": {mimeTypes: []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}, kind: kindZIP},
+	".png":  {mimeTypes: []string{"image/png"}, kind: kindPNG},
+	".jpg":  {mimeTypes: []string{"image/jpeg"}, kind: kindJPEG},
+	".jpeg": {mimeTypes: []string{"image/jpeg"}, kind: kindJPEG},
+}
+
+var signatures = []struct {
+	prefix []byte
+	kind   string
+}{
+	{[]byte("%PDF-"), kindPDF},
+	{[]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, kindOLE},
+	{[]byte("PK\x03\x04"), kindZIP},
+	{[]byte("\x89PNG\r\n\x1a\n"), kindPNG},
+	{[]byte{0xFF, 0xD8, 0xFF}, kindJPEG},
+}
+
 var (
 	ErrInvalidFilename = errors.New("invalid filename")
 	ErrInvalidFileType = errors.New("file type not allowed")
 	ErrPathTraversal   = errors.New("path traversal detected")
+	ErrContentMismatch = errors.New("file content does not match its type")
 )
 
-// ValidateFilename проверяет имя файла на безопасность
+// Validator проверяет имя, заявленный тип и содержимое загружаемого файла
+type Validator struct {
+	extensions map[string]bool
+	mimeTypes  map[string]bool
+}
+
+// NewValidator создаёт валидатор с разрешёнными расширениями и MIME типами.
+// Пустой список заменяется списком по умолчанию. Расширение без сигнатуры
+// в knownFileTypes не разрешается: содержимое такого файла проверить нечем.
+func NewValidator(extensions, mimeTypes []string) (Validator, error) {
+	v := Validator{extensions: allowedExtensions, mimeTypes: allowedMimeTypes}
+	if len(extensions) > 0 {
+		v.extensions = make(map[string]bool, len(extensions))
+		for _, ext := range extensions {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if ext != "" && !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			if _, ok := knownFileTypes[ext]; !ok {
+				return Validator{}, fmt.Errorf("%w: no content check for extension %q", ErrInvalidFileType, ext)
+			}
+			v.extensions[ext] = true
+		}
+	}
+	if len(mimeTypes) > 0 {
+		v.mimeTypes = make(map[string]bool, len(mimeTypes))
+		for _, mimeType := range mimeTypes {
+			v.mimeTypes[strings.ToLower(strings.TrimSpace(mimeType))] = true
+		}
+	}
+	return v, nil
+}
+
+// Validate проверяет имя файла и заявленный тип, включая их соответствие друг другу
+func (v Validator) Validate(filename, contentType string) error {
+	if err := v.ValidateFilename(filename); err != nil {
+		return err
+	}
+	if err := v.ValidateContentType(contentType); err != nil {
+		return err
+	}
+
+	known, ok := knownFileTypes[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return ErrInvalidFileType
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, allowed := range known.mimeTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return nil
+		}
+	}
+	return ErrContentMismatch
+}
+
+// CheckContent сверяет первые байты содержимого с форматом, ожидаемым по расширению
+func (v Validator) CheckContent(filename string, head []byte) error {
+	known, ok := knownFileTypes[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return ErrInvalidFileType
+	}
+	if sniffKind(head) != known.kind {
+		return ErrContentMismatch
+	}
+	return nil
+}
+
+// BOM текстовых кодировок
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// sniffKind определяет формат по сигнатуре, остальное проверяет как текст (см. isText)
+func sniffKind(head []byte) string {
+	for _, sig := range signatures {
+		if bytes.HasPrefix(head, sig.prefix) {
+			return sig.kind
+		}
+	}
+	if isText(head, len(head) >= sniffLen) {
+		return kindText
+	}
+	return ""
+}
+
+// isText проверяет, что head - текст без управляющих символов: UTF-8 (с BOM или без),
+// UTF-16 с BOM или однобайтовая кодировка (Windows-1251 и подобные). truncated - head
+// обрезан по sniffLen, и последний символ мог обрезаться посередине.
+func isText(head []byte, truncated bool) bool {
+	switch {
+	case bytes.HasPrefix(head, bomUTF16LE), bytes.HasPrefix(head, bomUTF16BE):
+		return isUTF16Text(head, truncated)
+	case bytes.HasPrefix(head, bomUTF8):
+		head = head[len(bomUTF8):]
+	}
+
+	if truncated {
+		for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	if !utf8.Valid(head) {
+		// Не UTF-8: однобайтовая кодировка. 0x98 не занят в Windows-1251.
+		for _, b := range head {
+			if b == 0x98 || !isTextByte(b) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, b := range head {
+		if !isTextByte(b) {
+			return false
+		}
+	}
+	return true
+}
+
+func isUTF16Text(head []byte, truncated bool) bool {
+	bigEndian := bytes.HasPrefix(head, bomUTF16BE)
+	head = head[2:]
+	if len(head)%2 != 0 {
+		if !truncated {
+			return false
+		}
+		head = head[:len(head)-1]
+	}
+	units := make([]uint16, 0, len(head)/2)
+	for i := 0; i < len(head); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(head[i])<<8|uint16(head[i+1]))
+		} else {
+			units = append(units, uint16(head[i+1])<<8|uint16(head[i]))
+		}
+	}
+	// Суррогатная пара могла обрезаться посередине
+	if truncated && len(units) > 0 && utf16.IsSurrogate(rune(units[len(units)-1])) {
+		units = units[:len(units)-1]
+	}
+	for _, r := range utf16.Decode(units) {
+		if r == utf8.RuneError || (r < 0x80 && !isTextByte(byte(r))) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTextByte - печатный символ или пробельный; остальные управляющие символы бывают только в бинарных файлах
+func isTextByte(b byte) bool {
+	if b == 0x7F {
+		return false
+	}
+	return b >= 0x20 || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+// ValidateFilename проверяет имя файла по списку расширений по умолчанию
 func ValidateFilename(filename string) error {
+	return defaultValidator.ValidateFilename(filename)
+}
+
+// ValidateContentType проверяет MIME тип файла по списку по умолчанию
+func ValidateContentType(contentType string) error {
+	return defaultValidator.ValidateContentType(contentType)
+}
+
+var defaultValidator, _ = NewValidator(nil, nil)
+
+// ValidateFilename проверяет имя файла на безопасность
+func (v Validator) ValidateFilename(filename string) error {
 	if filename == "" {
 		return ErrInvalidFilename
 	}
@@ -59,7 +275,7 @@ func ValidateFilename(filename string) error {
 	if ext == "" {
 		return ErrInvalidFilename
 	}
-	if !allowedExtensions[ext] {
+	if !v.extensions[ext] {
 		return ErrInvalidFileType
 	}
 
@@ -72,7 +288,7 @@ func ValidateFilename(filename string) error {
 }
 
 // ValidateContentType проверяет MIME тип файла
-func ValidateContentType(contentType string) error {
+func (v Validator) ValidateContentType(contentType string) error {
 	if contentType == "" {
 		return ErrInvalidContentType
 	}
@@ -82,8 +298,8 @@ func ValidateContentType(contentType string) error {
 		return ErrInvalidContentType
 	}
 
-	if !allowedMimeTypes[mediaType] {
-		return ErrInvalidContentType
+	if !v.mimeTypes[strings.ToLower(mediaType)] {
+		return ErrInvalidFileType
 	}
 
 	return nil
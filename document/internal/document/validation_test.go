@@ -0,0 +1,129 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"unicode/utf16"
+)
+
+func utf16Bytes(text string, bigEndian bool) []byte {
+	var out []byte
+	if bigEndian {
+		out = append(out, bomUTF16BE...)
+	} else {
+		out = append(out, bomUTF16LE...)
+	}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		if bigEndian {
+			out = append(out, byte(unit>>8), byte(unit))
+		} else {
+			out = append(out, byte(unit), byte(unit>>8))
+		}
+	}
+	return out
+}
+
+func TestSniffKind(t *testing.T) {
+	// "Привет, мир" в Windows-1251
+	cp1251 := []byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2, ',', ' ', 0xEC, 0xE8, 0xF0, '\r', '\n'}
+	// Многобайтовый символ обрезан на границе sniffLen
+	truncatedUTF8 := append(bytes.Repeat([]byte("a"), sniffLen-1), "ж"[0])
+	truncatedUTF16 := utf16Bytes(string(bytes.Repeat([]byte("ж"), sniffLen)), false)[:sniffLen+1]
+
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"pdf", []byte("%PDF-1.7\n"), kindPDF},
+		{"ole", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1, 0x00}, kindOLE},
+		{"zip", []byte("PK\x03\x04\x14\x00"), kindZIP},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), kindPNG},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, kindJPEG},
+		{"ascii text", []byte("hello\tworld\r\n"), kindText},
+		{"utf-8 text", []byte("Привет, мир\n"), kindText},
+		{"utf-8 with bom", append(append([]byte{}, bomUTF8...), "Привет"...), kindText},
+		{"windows-1251 text", cp1251, kindText},
+		{"utf-16le with bom", utf16Bytes("Привет, мир\r\n", false), kindText},
+		{"utf-16be with bom", utf16Bytes("Привет, мир\r\n", true), kindText},
+		{"utf-8 cut at sniff limit", truncatedUTF8, kindText},
+		{"utf-16 cut at sniff limit", truncatedUTF16, kindText},
+		{"empty", nil, kindText},
+		{"binary with nul", []byte{'a', 0x00, 'b'}, ""},
+		{"binary with escape", []byte("text\x1b[0m"), ""},
+		{"single-byte with del", []byte{0xCF, 0x7F}, ""},
+		{"utf-16 odd length", append(utf16Bytes("abc", false), 'x'), ""},
+		{"utf-16 with control", utf16Bytes("a\x00b", false), ""},
+		{"elf executable", []byte("\x7fELF\x02\x01\x01"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffKind(tt.head); got != tt.want {
+				t.Fatalf("sniffKind = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatorCheckContent(t *testing.T) {
+	validator, err := NewValidator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		head     []byte
+		want     error
+	}{
+		{"pdf", "report.pdf", []byte("%PDF-1.4"), nil},
+		{"docx", "report.DOCX", []byte("PK\x03\x04"), nil},
+		{"text in cp1251", "notes.txt", []byte{0xCF, 0xF0, 0xE8}, nil},
+		{"exe renamed to pdf", "report.pdf", []byte("MZ\x90\x00"), ErrContentMismatch},
+		{"pdf renamed to txt", "notes.txt", []byte("%PDF-1.4\n\x00\x01"), ErrContentMismatch},
+		{"png renamed to jpg", "photo.jpg", []byte("\x89PNG\r\n\x1a\n"), ErrContentMismatch},
+		{"unknown extension", "script.sh", []byte("#!/bin/sh\n"), ErrInvalidFileType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validator.CheckContent(tt.filename, tt.head); !errors.Is(err, tt.want) {
+				t.Fatalf("CheckContent = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatorValidate(t *testing.T) {
+	validator, err := NewValidator([]string{"pdf", ".TXT"}, []string{"application/pdf", "text/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		filename    string
+		contentType string
+		want        error
+	}{
+		{"pdf", "a.pdf", "application/pdf", nil},
+		{"text with charset", "a.txt", "text/plain; charset=windows-1251", nil},
+		{"declared type does not match extension", "a.pdf", "text/plain", ErrContentMismatch},
+		{"extension not allowed", "a.docx", "application/pdf", ErrInvalidFileType},
+		{"path traversal", "../a.pdf", "application/pdf", ErrPathTraversal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validator.Validate(tt.filename, tt.contentType); !errors.Is(err, tt.want) {
+				t.Fatalf("Validate = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewValidatorRejectsUnknownExtension(t *testing.T) {
+	if _, err := NewValidator([]string{".pdf", ".exe"}, nil); !errors.Is(err, ErrInvalidFileType) {
+		t.Fatalf("NewValidator = %v, want ErrInvalidFileType", err)
+	}
+}
@@ -0,0 +1,254 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *service) ListVersions(ctx context.Context, id string, requester Requester) (Metadata, []Version, error) {
+	objectID, err := parseObjectID(id)
+	if err != nil {
+		return Metadata{}, nil, ErrInvalidDocumentID
+	}
+
+	doc, err := s.repo.FindByID(ctx, objectID)
+	if err != nil {
+		return Metadata{}, nil, err
+	}
+
+	if err := s.authorizeRead(ctx, doc, requester); err != nil {
+		return Metadata{}, nil, err
+	}
+
+	versions, err := s.repo.FindVersions(ctx, objectID)
+	if err != nil {
+		return Metadata{}, nil, err
+	}
+	if len(versions) == 0 {
+		versions = []Version{doc.InitialVersion()}
+	}
+
+	return doc, versions, nil
+}
+
+// RestoreVersion делает содержимое старой версии текущим. История не переписывается:
+// создаётся новая версия, ссылающаяся на тот же объект в хранилище.
+func (s *service) RestoreVersion(ctx context.Context, id string, version int, requester Requester) (Metadata, error) {
+	if version <= 0 {
+		return Metadata{}, ErrInvalidVersion
+	}
+
+	objectID, err := parseObjectID(id)
+	if err != nil {
+		return Metadata{}, ErrInvalidDocumentID
+	}
+
+	doc, err := s.repo.FindByID(ctx, objectID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	if !requester.CanManageDocument(doc) {
+		return Metadata{}, ErrForbidden
+	}
+
+	source, err := s.lookupVersion(ctx, doc, version)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	source.ID = primitive.NilObjectID
+	source.UploadedBy = requester.UserID
+	source.RestoredFrom = version
+	return s.addVersion(ctx, doc, source)
+}
+
+// findVersion проверяет права на чтение и возвращает метаданные документа
+// с содержимым запрошенной версии. Непроверенное антивирусом содержимое не отдаётся.
+func (s *service) findVersion(ctx context.Context, id string, version int, requester Requester) (Metadata, error) {
+	if version < 0 {
+		return Metadata{}, ErrInvalidVersion
+	}
+
+	objectID, err := parseObjectID(id)
+	if err != nil {
+		return Metadata{}, ErrInvalidDocumentID
+	}
+
+	doc, err := s.repo.FindByID(ctx, objectID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	if err := s.authorizeRead(ctx, doc, requester); err != nil {
+		return Metadata{}, err
+	}
+
+	if version != 0 && version != doc.Version() {
+		v, err := s.repo.FindVersion(ctx, doc.ID, version)
+		if err != nil {
+			return Metadata{}, err
+		}
+		doc = doc.WithVersion(v)
+	}
+
+	if err := checkScanStatus(doc.ScanStatus); err != nil {
+		return Metadata{}, err
+	}
+	if err := checkIntegrity(doc); err != nil {
+		return Metadata{}, err
+	}
+	return doc, nil
+}
+
+// authorizeRead разрешает чтение владельцу документа и участникам его задачи
+func (s *service) authorizeRead(ctx context.Context, doc Metadata, requester Requester) error {
+	if requester.CanAccessDocument(doc) {
+		return nil
+	}
+	return s.authorizeTaskAccess(ctx, doc.TaskID, requester)
+}
+
+func (s *service) enqueueScan(doc Metadata) {
+	if s.scans != nil && doc.ScanStatus == ScanPending {
+		s.scans.Enqueue(doc.MinioObject)
+	}
+}
+
+// lookupVersion ищет версию документа, в том числе неявную первую версию
+// у документов, загруженных до появления истории
+func (s *service) lookupVersion(ctx context.Context, doc Metadata, version int) (Version, error) {
+	if doc.CurrentVersion == 0 {
+		if version != 1 {
+			return Version{}, ErrVersionNotFound
+		}
+		return doc.InitialVersion(), nil
+	}
+	return s.repo.FindVersion(ctx, doc.ID, version)
+}
+
+// uploadTarget проверяет параметры загрузки и возвращает документ, к которому
+// добавляется новая версия, или nil, если создаётся новый документ
+func (s *service) uploadTarget(ctx context.Context, documentID, taskID, ownerID string, requester Requester) (*Metadata, error) {
+	if _, err := uuid.Parse(ownerID); err != nil {
+		return nil, ErrInvalidOwnerID
+	}
+	if requester.Role != RoleAdmin && requester.UserID != ownerID {
+		return nil, ErrForbidden
+	}
+
+	if documentID == "" {
+		if _, err := uuid.Parse(taskID); err != nil {
+			return nil, ErrInvalidTaskID
+		}
+		return nil, s.checkTask(ctx, taskID, ownerID, requester)
+	}
+
+	objectID, err := parseObjectID(documentID)
+	if err != nil {
+		return nil, ErrInvalidDocumentID
+	}
+
+	doc, err := s.repo.FindByID(ctx, objectID)
+	if err != nil {
+		return nil, err
+	}
+	if taskID != "" && taskID != doc.TaskID {
+		return nil, ErrInvalidTaskID
+	}
+	if !requester.CanManageDocument(doc) {
+		return nil, ErrForbidden
+	}
+	if err := s.checkTask(ctx, doc.TaskID, ownerID, requester); err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// attachObject сохраняет загруженный объект как новый документ (target == nil)
+// или как следующую версию существующего. uploadObject - ключ presigned загрузки, если она была.
+func (s *service) attachObject(ctx context.Context, target *Metadata, metadata Metadata, uploadObject string) (Metadata, error) {
+	insertCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if s.scans != nil {
+		metadata.ScanStatus = ScanPending
+	}
+
+	if target != nil {
+		doc, err := s.addVersion(insertCtx, *target, Version{
+			Filename:      metadata.Filename,
+			ContentType:   metadata.ContentType,
+			Size:          metadata.Size,
+			MinioObject:   metadata.MinioObject,
+			MinioBucket:   metadata.MinioBucket,
+			Checksum:      metadata.Checksum,
+			UploadedBy:    metadata.OwnerID,
+			ScanStatus:    metadata.ScanStatus,
+			UploadObject:  uploadObject,
+			EncryptionKey: metadata.EncryptionKey,
+		})
+		if err == nil {
+			s.enqueueScan(doc)
+		}
+		return doc, err
+	}
+
+	metadata.UploadedAt = time.Now()
+	metadata.CurrentVersion = 1
+
+	insertID, err := s.repo.Insert(insertCtx, metadata)
+	if err != nil {
+		return Metadata{}, err
+	}
+	metadata.ID = insertID
+
+	initial := metadata.InitialVersion()
+	initial.UploadObject = uploadObject
+	if err := s.repo.InsertVersion(insertCtx, initial); err != nil {
+		_ = s.repo.Delete(context.Background(), insertID)
+		return Metadata{}, err
+	}
+
+	s.scheduleTextExtraction(metadata)
+	s.enqueueScan(metadata)
+	s.publish(EventDocumentCreated, metadata, metadata.OwnerID)
+	return metadata, nil
+}
+
+// addVersion записывает следующую версию и переключает на неё документ.
+// Параллельная загрузка версии того же документа завершится ErrVersionConflict.
+func (s *service) addVersion(ctx context.Context, doc Metadata, v Version) (Metadata, error) {
+	current := doc.Version()
+	if doc.CurrentVersion == 0 {
+		// Документ загружен до появления версий: переносим исходное содержимое в историю
+		err := s.repo.InsertVersion(ctx, doc.InitialVersion())
+		if err != nil && !errors.Is(err, ErrVersionConflict) {
+			return Metadata{}, err
+		}
+	}
+
+	v.DocumentID = doc.ID
+	v.Version = current + 1
+	v.UploadedAt = time.Now()
+
+	if err := s.repo.InsertVersion(ctx, v); err != nil {
+		return Metadata{}, err
+	}
+	if err := s.repo.SetCurrentVersion(ctx, doc.ID, current, v); err != nil {
+		// Иначе версия, не ставшая текущей, держала бы ссылку на содержимое
+		_ = s.repo.DeleteVersion(context.Background(), doc.ID, v.Version)
+		return Metadata{}, err
+	}
+
+	doc = doc.WithVersion(v)
+	doc.LastModified = v.UploadedAt
+	s.scheduleTextExtraction(doc)
+	s.publish(EventDocumentVersionAdded, doc, v.UploadedBy)
+	return doc, nil
+}
@@ -0,0 +1,50 @@
+package taskclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Oniqq60/task_system_control/document/internal/document"
+	pb "github.com/Oniqq60/task_system_control/gen/proto/task"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Client struct {
+	rpc     pb.TaskServiceClient
+	timeout time.Duration
+}
+
+// New создаёт клиента. timeout задаёт максимальное время ожидания gRPC вызова.
+func New(conn *grpc.ClientConn, timeout time.Duration) *Client {
+	return &Client{
+		rpc:     pb.NewTaskServiceClient(conn),
+		timeout: timeout,
+	}
+}
+
+// GetTask возвращает задачу или document.ErrTaskNotFound, если её нет.
+func (c *Client) GetTask(ctx context.Context, taskID string) (document.TaskInfo, error) {
+	callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.rpc.GetTask(callCtx, &pb.GetTaskRequest{Id: taskID})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.NotFound:
+			return document.TaskInfo{}, document.ErrTaskNotFound
+		case codes.InvalidArgument:
+			return document.TaskInfo{}, document.ErrInvalidTaskID
+		}
+		return document.TaskInfo{}, fmt.Errorf("task.GetTask RPC failed: %w", err)
+	}
+
+	return document.TaskInfo{
+		ID:        resp.GetId(),
+		WorkerID:  resp.GetWorkerId(),
+		CreatedBy: resp.GetCreatedBy(),
+		Status:    resp.GetStatus(),
+	}, nil
+}
@@ -19,10 +19,11 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	AuthService_Register_FullMethodName      = "/auth.v1.AuthService/Register"
-	AuthService_Login_FullMethodName         = "/auth.v1.AuthService/Login"
-	AuthService_ValidateToken_FullMethodName = "/auth.v1.AuthService/ValidateToken"
-	AuthService_GetManager_FullMethodName    = "/auth.v1.AuthService/GetManager"
+	AuthService_Register_FullMethodName       = "/auth.v1.AuthService/Register"
+	AuthService_Login_FullMethodName          = "/auth.v1.AuthService/Login"
+	AuthService_ValidateToken_FullMethodName  = "/auth.v1.AuthService/ValidateToken"
+	AuthService_GetManager_FullMethodName     = "/auth.v1.AuthService/GetManager"
+	AuthService_GetUserContact_FullMethodName = "/auth.v1.AuthService/GetUserContact"
 )
 
 // AuthServiceClient is the client API for AuthService service.
@@ -39,6 +40,8 @@ type AuthServiceClient interface {
 	ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*ValidateTokenResponse, error)
 	// GetManager возвращает менеджера сотрудника (если есть)
 	GetManager(ctx context.Context, in *GetManagerRequest, opts ...grpc.CallOption) (*GetManagerResponse, error)
+	// GetUserContact возвращает контактные данные пользователя для уведомлений
+	GetUserContact(ctx context.Context, in *GetUserContactRequest, opts ...grpc.CallOption) (*GetUserContactResponse, error)
 }
 
 type authServiceClient struct {
@@ -89,6 +92,16 @@ func (c *authServiceClient) GetManager(ctx context.Context, in *GetManagerReques
 	return out, nil
 }
 
+func (c *authServiceClient) GetUserContact(ctx context.Context, in *GetUserContactRequest, opts ...grpc.CallOption) (*GetUserContactResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUserContactResponse)
+	err := c.cc.Invoke(ctx, AuthService_GetUserContact_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServiceServer is the server API for AuthService service.
 // All implementations must embed UnimplementedAuthServiceServer
 // for forward compatibility.
@@ -103,6 +116,8 @@ type AuthServiceServer interface {
 	ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error)
 	// GetManager возвращает менеджера сотрудника (если есть)
 	GetManager(context.Context, *GetManagerRequest) (*GetManagerResponse, error)
+	// GetUserContact возвращает контактные данные пользователя для уведомлений
+	GetUserContact(context.Context, *GetUserContactRequest) (*GetUserContactResponse, error)
 	mustEmbedUnimplementedAuthServiceServer()
 }
 
@@ -125,6 +140,9 @@ func (UnimplementedAuthServiceServer) ValidateToken(context.Context, *ValidateTo
 func (UnimplementedAuthServiceServer) GetManager(context.Context, *GetManagerRequest) (*GetManagerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetManager not implemented")
 }
+func (UnimplementedAuthServiceServer) GetUserContact(context.Context, *GetUserContactRequest) (*GetUserContactResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserContact not implemented")
+}
 func (UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
 func (UnimplementedAuthServiceServer) testEmbeddedByValue()                     {}
 
@@ -218,6 +236,24 @@ func _AuthService_GetManager_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthService_GetUserContact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserContactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).GetUserContact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_GetUserContact_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).GetUserContact(ctx, req.(*GetUserContactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AuthService_ServiceDesc is the grpc.ServiceDesc for AuthService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -241,6 +277,10 @@ var AuthService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetManager",
 			Handler:    _AuthService_GetManager_Handler,
 		},
+		{
+			MethodName: "GetUserContact",
+			Handler:    _AuthService_GetUserContact_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "auth/v1/auth.proto",
@@ -24,6 +24,17 @@ const (
 	DocumentService_GetDocument_FullMethodName         = "/document.v1.DocumentService/GetDocument"
 	DocumentService_GetDocumentsByTask_FullMethodName  = "/document.v1.DocumentService/GetDocumentsByTask"
 	DocumentService_GetDocumentsByOwner_FullMethodName = "/document.v1.DocumentService/GetDocumentsByOwner"
+	DocumentService_UploadDocument_FullMethodName      = "/document.v1.DocumentService/UploadDocument"
+	DocumentService_DownloadDocument_FullMethodName    = "/document.v1.DocumentService/DownloadDocument"
+	DocumentService_CreateUploadURL_FullMethodName     = "/document.v1.DocumentService/CreateUploadURL"
+	DocumentService_CompleteUpload_FullMethodName      = "/document.v1.DocumentService/CompleteUpload"
+	DocumentService_CreateDownloadURL_FullMethodName   = "/document.v1.DocumentService/CreateDownloadURL"
+	DocumentService_ListVersions_FullMethodName        = "/document.v1.DocumentService/ListVersions"
+	DocumentService_RestoreVersion_FullMethodName      = "/document.v1.DocumentService/RestoreVersion"
+	DocumentService_SearchDocuments_FullMethodName     = "/document.v1.DocumentService/SearchDocuments"
+	DocumentService_ReconcileStorage_FullMethodName    = "/document.v1.DocumentService/ReconcileStorage"
+	DocumentService_ListTrash_FullMethodName           = "/document.v1.DocumentService/ListTrash"
+	DocumentService_RestoreDocument_FullMethodName     = "/document.v1.DocumentService/RestoreDocument"
 )
 
 // DocumentServiceClient is the client API for DocumentService service.
@@ -34,7 +45,7 @@ const (
 type DocumentServiceClient interface {
 	// AddDocument добавляет документ к задаче
 	AddDocument(ctx context.Context, in *AddDocumentRequest, opts ...grpc.CallOption) (*AddDocumentResponse, error)
-	// DeleteDocument удаляет документ
+	// DeleteDocument перемещает документ в корзину
 	DeleteDocument(ctx context.Context, in *DeleteDocumentRequest, opts ...grpc.CallOption) (*DeleteDocumentResponse, error)
 	// GetDocument получает документ и файл
 	GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*GetDocumentResponse, error)
@@ -42,6 +53,28 @@ type DocumentServiceClient interface {
 	GetDocumentsByTask(ctx context.Context, in *GetDocumentsByTaskRequest, opts ...grpc.CallOption) (*GetDocumentsByTaskResponse, error)
 	// GetDocumentsByOwner возвращает список документов пользователя
 	GetDocumentsByOwner(ctx context.Context, in *GetDocumentsByOwnerRequest, opts ...grpc.CallOption) (*GetDocumentsByOwnerResponse, error)
+	// UploadDocument загружает документ потоком: первое сообщение содержит info, дальше идут chunk
+	UploadDocument(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadDocumentRequest, AddDocumentResponse], error)
+	// DownloadDocument отдаёт документ потоком: первое сообщение содержит info, дальше идут chunk
+	DownloadDocument(ctx context.Context, in *DownloadDocumentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadDocumentResponse], error)
+	// CreateUploadURL выдаёт короткоживущий presigned PUT URL MinIO для прямой загрузки
+	CreateUploadURL(ctx context.Context, in *CreateUploadURLRequest, opts ...grpc.CallOption) (*CreateUploadURLResponse, error)
+	// CompleteUpload проверяет загруженный по presigned URL объект и создаёт документ
+	CompleteUpload(ctx context.Context, in *CompleteUploadRequest, opts ...grpc.CallOption) (*AddDocumentResponse, error)
+	// CreateDownloadURL выдаёт короткоживущий presigned GET URL MinIO для прямого скачивания
+	CreateDownloadURL(ctx context.Context, in *CreateDownloadURLRequest, opts ...grpc.CallOption) (*CreateDownloadURLResponse, error)
+	// ListVersions возвращает историю версий документа
+	ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error)
+	// RestoreVersion делает указанную версию текущей (создаёт новую версию с её содержимым)
+	RestoreVersion(ctx context.Context, in *RestoreVersionRequest, opts ...grpc.CallOption) (*Document, error)
+	// SearchDocuments ищет документы по тегам, имени файла, типу, дате загрузки, задаче и владельцу
+	SearchDocuments(ctx context.Context, in *SearchDocumentsRequest, opts ...grpc.CallOption) (*SearchDocumentsResponse, error)
+	// ReconcileStorage сверяет объекты MinIO с метаданными и находит сирот (только admin)
+	ReconcileStorage(ctx context.Context, in *ReconcileStorageRequest, opts ...grpc.CallOption) (*ReconcileStorageResponse, error)
+	// ListTrash возвращает документы в корзине
+	ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*ListTrashResponse, error)
+	// RestoreDocument возвращает документ из корзины
+	RestoreDocument(ctx context.Context, in *RestoreDocumentRequest, opts ...grpc.CallOption) (*Document, error)
 }
 
 type documentServiceClient struct {
@@ -102,6 +135,128 @@ func (c *documentServiceClient) GetDocumentsByOwner(ctx context.Context, in *Get
 	return out, nil
 }
 
+func (c *documentServiceClient) UploadDocument(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadDocumentRequest, AddDocumentResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DocumentService_ServiceDesc.Streams[0], DocumentService_UploadDocument_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadDocumentRequest, AddDocumentResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DocumentService_UploadDocumentClient = grpc.ClientStreamingClient[UploadDocumentRequest, AddDocumentResponse]
+
+func (c *documentServiceClient) DownloadDocument(ctx context.Context, in *DownloadDocumentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadDocumentResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DocumentService_ServiceDesc.Streams[1], DocumentService_DownloadDocument_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DownloadDocumentRequest, DownloadDocumentResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DocumentService_DownloadDocumentClient = grpc.ServerStreamingClient[DownloadDocumentResponse]
+
+func (c *documentServiceClient) CreateUploadURL(ctx context.Context, in *CreateUploadURLRequest, opts ...grpc.CallOption) (*CreateUploadURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateUploadURLResponse)
+	err := c.cc.Invoke(ctx, DocumentService_CreateUploadURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentServiceClient) CompleteUpload(ctx context.Context, in *CompleteUploadRequest, opts ...grpc.CallOption) (*AddDocumentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddDocumentResponse)
+	err := c.cc.Invoke(ctx, DocumentService_CompleteUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentServiceClient) CreateDownloadURL(ctx context.Context, in *CreateDownloadURLRequest, opts ...grpc.CallOption) (*CreateDownloadURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateDownloadURLResponse)
+	err := c.cc.Invoke(ctx, DocumentService_CreateDownloadURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentServiceClient) ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListVersionsResponse)
+	err := c.cc.Invoke(ctx, DocumentService_ListVersions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentServiceClient) RestoreVersion(ctx context.Context, in *RestoreVersionRequest, opts ...grpc.CallOption) (*Document, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Document)
+	err := c.cc.Invoke(ctx, DocumentService_RestoreVersion_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentServiceClient) SearchDocuments(ctx context.Context, in *SearchDocumentsRequest, opts ...grpc.CallOption) (*SearchDocumentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchDocumentsResponse)
+	err := c.cc.Invoke(ctx, DocumentService_SearchDocuments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentServiceClient) ReconcileStorage(ctx context.Context, in *ReconcileStorageRequest, opts ...grpc.CallOption) (*ReconcileStorageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReconcileStorageResponse)
+	err := c.cc.Invoke(ctx, DocumentService_ReconcileStorage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentServiceClient) ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*ListTrashResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTrashResponse)
+	err := c.cc.Invoke(ctx, DocumentService_ListTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentServiceClient) RestoreDocument(ctx context.Context, in *RestoreDocumentRequest, opts ...grpc.CallOption) (*Document, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Document)
+	err := c.cc.Invoke(ctx, DocumentService_RestoreDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DocumentServiceServer is the server API for DocumentService service.
 // All implementations must embed UnimplementedDocumentServiceServer
 // for forward compatibility.
@@ -110,7 +265,7 @@ func (c *documentServiceClient) GetDocumentsByOwner(ctx context.Context, in *Get
 type DocumentServiceServer interface {
 	// AddDocument добавляет документ к задаче
 	AddDocument(context.Context, *AddDocumentRequest) (*AddDocumentResponse, error)
-	// DeleteDocument удаляет документ
+	// DeleteDocument перемещает документ в корзину
 	DeleteDocument(context.Context, *DeleteDocumentRequest) (*DeleteDocumentResponse, error)
 	// GetDocument получает документ и файл
 	GetDocument(context.Context, *GetDocumentRequest) (*GetDocumentResponse, error)
@@ -118,6 +273,28 @@ type DocumentServiceServer interface {
 	GetDocumentsByTask(context.Context, *GetDocumentsByTaskRequest) (*GetDocumentsByTaskResponse, error)
 	// GetDocumentsByOwner возвращает список документов пользователя
 	GetDocumentsByOwner(context.Context, *GetDocumentsByOwnerRequest) (*GetDocumentsByOwnerResponse, error)
+	// UploadDocument загружает документ потоком: первое сообщение содержит info, дальше идут chunk
+	UploadDocument(grpc.ClientStreamingServer[UploadDocumentRequest, AddDocumentResponse]) error
+	// DownloadDocument отдаёт документ потоком: первое сообщение содержит info, дальше идут chunk
+	DownloadDocument(*DownloadDocumentRequest, grpc.ServerStreamingServer[DownloadDocumentResponse]) error
+	// CreateUploadURL выдаёт короткоживущий presigned PUT URL MinIO для прямой загрузки
+	CreateUploadURL(context.Context, *CreateUploadURLRequest) (*CreateUploadURLResponse, error)
+	// CompleteUpload проверяет загруженный по presigned URL объект и создаёт документ
+	CompleteUpload(context.Context, *CompleteUploadRequest) (*AddDocumentResponse, error)
+	// CreateDownloadURL выдаёт короткоживущий presigned GET URL MinIO для прямого скачивания
+	CreateDownloadURL(context.Context, *CreateDownloadURLRequest) (*CreateDownloadURLResponse, error)
+	// ListVersions возвращает историю версий документа
+	ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsResponse, error)
+	// RestoreVersion делает указанную версию текущей (создаёт новую версию с её содержимым)
+	RestoreVersion(context.Context, *RestoreVersionRequest) (*Document, error)
+	// SearchDocuments ищет документы по тегам, имени файла, типу, дате загрузки, задаче и владельцу
+	SearchDocuments(context.Context, *SearchDocumentsRequest) (*SearchDocumentsResponse, error)
+	// ReconcileStorage сверяет объекты MinIO с метаданными и находит сирот (только admin)
+	ReconcileStorage(context.Context, *ReconcileStorageRequest) (*ReconcileStorageResponse, error)
+	// ListTrash возвращает документы в корзине
+	ListTrash(context.Context, *ListTrashRequest) (*ListTrashResponse, error)
+	// RestoreDocument возвращает документ из корзины
+	RestoreDocument(context.Context, *RestoreDocumentRequest) (*Document, error)
 	mustEmbedUnimplementedDocumentServiceServer()
 }
 
@@ -143,6 +320,39 @@ func (UnimplementedDocumentServiceServer) GetDocumentsByTask(context.Context, *G
 func (UnimplementedDocumentServiceServer) GetDocumentsByOwner(context.Context, *GetDocumentsByOwnerRequest) (*GetDocumentsByOwnerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetDocumentsByOwner not implemented")
 }
+func (UnimplementedDocumentServiceServer) UploadDocument(grpc.ClientStreamingServer[UploadDocumentRequest, AddDocumentResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadDocument not implemented")
+}
+func (UnimplementedDocumentServiceServer) DownloadDocument(*DownloadDocumentRequest, grpc.ServerStreamingServer[DownloadDocumentResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadDocument not implemented")
+}
+func (UnimplementedDocumentServiceServer) CreateUploadURL(context.Context, *CreateUploadURLRequest) (*CreateUploadURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateUploadURL not implemented")
+}
+func (UnimplementedDocumentServiceServer) CompleteUpload(context.Context, *CompleteUploadRequest) (*AddDocumentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompleteUpload not implemented")
+}
+func (UnimplementedDocumentServiceServer) CreateDownloadURL(context.Context, *CreateDownloadURLRequest) (*CreateDownloadURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDownloadURL not implemented")
+}
+func (UnimplementedDocumentServiceServer) ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (UnimplementedDocumentServiceServer) RestoreVersion(context.Context, *RestoreVersionRequest) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreVersion not implemented")
+}
+func (UnimplementedDocumentServiceServer) SearchDocuments(context.Context, *SearchDocumentsRequest) (*SearchDocumentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchDocuments not implemented")
+}
+func (UnimplementedDocumentServiceServer) ReconcileStorage(context.Context, *ReconcileStorageRequest) (*ReconcileStorageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReconcileStorage not implemented")
+}
+func (UnimplementedDocumentServiceServer) ListTrash(context.Context, *ListTrashRequest) (*ListTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrash not implemented")
+}
+func (UnimplementedDocumentServiceServer) RestoreDocument(context.Context, *RestoreDocumentRequest) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreDocument not implemented")
+}
 func (UnimplementedDocumentServiceServer) mustEmbedUnimplementedDocumentServiceServer() {}
 func (UnimplementedDocumentServiceServer) testEmbeddedByValue()                         {}
 
@@ -254,6 +464,186 @@ func _DocumentService_GetDocumentsByOwner_Handler(srv interface{}, ctx context.C
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DocumentService_UploadDocument_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DocumentServiceServer).UploadDocument(&grpc.GenericServerStream[UploadDocumentRequest, AddDocumentResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DocumentService_UploadDocumentServer = grpc.ClientStreamingServer[UploadDocumentRequest, AddDocumentResponse]
+
+func _DocumentService_DownloadDocument_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadDocumentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DocumentServiceServer).DownloadDocument(m, &grpc.GenericServerStream[DownloadDocumentRequest, DownloadDocumentResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DocumentService_DownloadDocumentServer = grpc.ServerStreamingServer[DownloadDocumentResponse]
+
+func _DocumentService_CreateUploadURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUploadURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).CreateUploadURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_CreateUploadURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).CreateUploadURL(ctx, req.(*CreateUploadURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentService_CompleteUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompleteUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).CompleteUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_CompleteUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).CompleteUpload(ctx, req.(*CompleteUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentService_CreateDownloadURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateDownloadURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).CreateDownloadURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_CreateDownloadURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).CreateDownloadURL(ctx, req.(*CreateDownloadURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentService_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_ListVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).ListVersions(ctx, req.(*ListVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentService_RestoreVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).RestoreVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_RestoreVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).RestoreVersion(ctx, req.(*RestoreVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentService_SearchDocuments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchDocumentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).SearchDocuments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_SearchDocuments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).SearchDocuments(ctx, req.(*SearchDocumentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentService_ReconcileStorage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReconcileStorageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).ReconcileStorage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_ReconcileStorage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).ReconcileStorage(ctx, req.(*ReconcileStorageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentService_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).ListTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_ListTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).ListTrash(ctx, req.(*ListTrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DocumentService_RestoreDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentServiceServer).RestoreDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DocumentService_RestoreDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentServiceServer).RestoreDocument(ctx, req.(*RestoreDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // DocumentService_ServiceDesc is the grpc.ServiceDesc for DocumentService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -281,7 +671,54 @@ var DocumentService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetDocumentsByOwner",
 			Handler:    _DocumentService_GetDocumentsByOwner_Handler,
 		},
+		{
+			MethodName: "CreateUploadURL",
+			Handler:    _DocumentService_CreateUploadURL_Handler,
+		},
+		{
+			MethodName: "CompleteUpload",
+			Handler:    _DocumentService_CompleteUpload_Handler,
+		},
+		{
+			MethodName: "CreateDownloadURL",
+			Handler:    _DocumentService_CreateDownloadURL_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _DocumentService_ListVersions_Handler,
+		},
+		{
+			MethodName: "RestoreVersion",
+			Handler:    _DocumentService_RestoreVersion_Handler,
+		},
+		{
+			MethodName: "SearchDocuments",
+			Handler:    _DocumentService_SearchDocuments_Handler,
+		},
+		{
+			MethodName: "ReconcileStorage",
+			Handler:    _DocumentService_ReconcileStorage_Handler,
+		},
+		{
+			MethodName: "ListTrash",
+			Handler:    _DocumentService_ListTrash_Handler,
+		},
+		{
+			MethodName: "RestoreDocument",
+			Handler:    _DocumentService_RestoreDocument_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadDocument",
+			Handler:       _DocumentService_UploadDocument_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadDocument",
+			Handler:       _DocumentService_DownloadDocument_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "document/v1/document.proto",
 }
@@ -0,0 +1,49 @@
+// replay-dlq публикует события из dead-letter топика (KAFKA_DLQ_TOPIC) обратно в исходные
+// топики, чтобы notification сервис обработал их снова. Прочитанные сообщения коммитятся
+// в группе KAFKA_GROUP_ID + "-dlq-replay", поэтому повторный запуск не дублирует события.
+//
+//	go run ./cmd/replay-dlq -topic task-events
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Oniqq60/task_system_control/notification/internal/cfg"
+	"github.com/Oniqq60/task_system_control/notification/internal/notification"
+)
+
+func main() {
+	topic := flag.String("topic", "", "replay only events of this source topic (default: all)")
+	idle := flag.Duration("idle", 10*time.Second, "stop after no new dead letters for this long")
+	flag.Parse()
+
+	conf := cfg.LoadConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var brokers []string
+	for _, broker := range strings.Split(conf.KafkaBrokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+
+	replayed, err := notification.ReplayDeadLetters(ctx, notification.DeadLetterReplayConfig{
+		Brokers:         brokers,
+		DeadLetterTopic: conf.KafkaDLQTopic,
+		GroupID:         conf.KafkaGroupID + "-dlq-replay",
+		Topic:           *topic,
+		Idle:            *idle,
+	}, log.Default())
+	log.Printf("replayed %d events from %s", replayed, conf.KafkaDLQTopic)
+	if err != nil {
+		log.Fatalf("replay stopped: %v", err)
+	}
+}
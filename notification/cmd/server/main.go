@@ -2,18 +2,26 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
 
+	notificationpb "github.com/Oniqq60/task_system_control/gen/proto/notification"
 	"github.com/Oniqq60/task_system_control/notification/internal/authclient"
 	"github.com/Oniqq60/task_system_control/notification/internal/cfg"
 	"github.com/Oniqq60/task_system_control/notification/internal/notification"
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -42,13 +50,185 @@ func main() {
 	}
 	defer conn.Close()
 
+	db := mustConnectDB(conf)
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Fatalf("failed to access sql DB: %v", err)
+	}
+	defer sqlDB.Close()
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     conf.RedisAddr,
+		Password: conf.RedisPassword,
+	})
+	defer redisClient.Close()
+
+	repo := notification.NewRepository(db)
+	webhookRepo := notification.NewWebhookRepository(db)
+	ruleRepo := notification.NewRuleRepository(db)
+	preferenceRepo := notification.NewPreferenceRepository(db)
+	digestRepo := notification.NewDigestRepository(db)
+	alertRepo := notification.NewAlertRepository(db)
+
+	templates, err := notification.NewTemplates(conf.NotifyLocale)
+	if err != nil {
+		logger.Fatalf("failed to load notification templates: %v", err)
+	}
+
 	authClient := authclient.New(conn, 5*time.Second)
-	notifier := notification.NewLogNotifier(logger)
-	handler := notification.NewEventHandler(notifier, authClient)
-	consumer := notification.NewKafkaConsumer(brokers, conf.KafkaTopic, conf.KafkaGroupID, handler)
+	managers := authclient.NewManagerCache(authClient, conf.ManagerCacheTTL)
+	escalation := notification.EscalationConfig{
+		Types:     splitCSV(conf.EscalationTypes),
+		Timeout:   conf.EscalationTimeout,
+		MaxLevels: conf.EscalationMaxLevels,
+	}
+	kafkaNotifier, closeKafkaNotifier := notification.NewKafkaNotifier(brokers, conf.KafkaNotificationTopic)
+	defer closeKafkaNotifier()
+	channels := map[string]notification.Notifier{
+		// Непрочитанные в ящике уведомления поднимаются по цепочке менеджеров
+		notification.ChannelInbox: notification.NewAlertTracker(notification.NewMultiNotifier(
+			notification.NewLogNotifier(logger),
+			notification.NewStoreNotifier(repo),
+			kafkaNotifier,
+		), alertRepo, escalation, logger),
+		notification.ChannelWebhook: notification.NewWebhookNotifier(webhookRepo),
+	}
+	if conf.SMTPHost != "" {
+		emailNotifier, err := notification.NewSMTPNotifier(notification.SMTPConfig{
+			Host:     conf.SMTPHost,
+			Port:     conf.SMTPPort,
+			Username: conf.SMTPUsername,
+			Password: conf.SMTPPassword,
+			From:     conf.SMTPFrom,
+			Security: conf.SMTPSecurity,
+			Timeout:  conf.SMTPTimeout,
+		}, authClient, templates)
+		if err != nil {
+			logger.Fatalf("failed to configure email notifications: %v", err)
+		}
+		channels[notification.ChannelEmail] = emailNotifier
+		logger.Printf("email notifications enabled via %s:%s", conf.SMTPHost, conf.SMTPPort)
+	}
+	// Повторно доставленное событие каждый обработчик пропускает отдельно,
+	// а уведомление не отправляется повторно в каналы, куда уже доставлено
+	dedup := notification.NewRedisDedupStore(redisClient, conf.DedupTTL, conf.DedupProcessingTTL)
+	router := notification.NewEventHandler(notification.RoutingConfig{
+		Rules:        ruleRepo,
+		Preferences:  preferenceRepo,
+		Deferred:     notification.NewDeferredRepository(db),
+		Resolver:     managers,
+		Templates:    templates,
+		Channels:     channels,
+		Deliveries:   dedup,
+		PollInterval: conf.RulesPollInterval,
+		Logger:       logger,
+	})
+	webhookEvents := notification.NewWebhookEventHandler(webhookRepo)
+	// Состояние задачи для сводок повтор не меняет
+	handler := notification.NewFanoutHandler(
+		notification.NewTaskSnapshotHandler(digestRepo),
+		notification.NewDedupHandler(dedup, "routing", router, logger),
+		notification.NewDedupHandler(dedup, "webhook", webhookEvents, logger),
+	)
+
+	var digestSchedule *notification.Schedule
+	if conf.DigestSchedule != "" {
+		if digestSchedule, err = notification.ParseSchedule(conf.DigestSchedule); err != nil {
+			logger.Fatalf("invalid DIGEST_SCHEDULE: %v", err)
+		}
+	}
+	digestLocation, err := time.LoadLocation(conf.DigestTimezone)
+	if err != nil {
+		logger.Fatalf("invalid DIGEST_TIMEZONE: %v", err)
+	}
+	scheduler := notification.NewScheduler(notification.SchedulerConfig{
+		DigestSchedule: digestSchedule,
+		Location:       digestLocation,
+		OverdueAfter:   conf.DigestOverdueAfter,
+		Escalation:     escalation,
+		Digests:        digestRepo,
+		Alerts:         alertRepo,
+		Resolver:       managers,
+		Templates:      templates,
+		Notifier:       router,
+		PollInterval:   conf.RulesPollInterval,
+		Logger:         logger,
+	})
+	consumerConfig := notification.ConsumerConfig{
+		Brokers:         brokers,
+		Topic:           conf.KafkaTopic,
+		GroupID:         conf.KafkaGroupID,
+		Workers:         conf.ConsumerWorkers,
+		MaxAttempts:     conf.ConsumerMaxAttempts,
+		BackoffBase:     conf.ConsumerBackoffBase,
+		BackoffMax:      conf.ConsumerBackoffMax,
+		DeadLetterTopic: conf.KafkaDLQTopic,
+		LagInterval:     conf.ConsumerLagInterval,
+		Logger:          logger,
+	}
+	consumer := notification.NewKafkaConsumer(consumerConfig, handler)
 	defer consumer.Close()
+	consumerConfig.Topic = conf.KafkaDocumentTopic
+	consumerConfig.GroupID = conf.KafkaDocumentGroupID
+	documentConsumer := notification.NewDocumentEventConsumer(consumerConfig, webhookEvents)
+	defer documentConsumer.Close()
+
+	dispatcher := notification.NewWebhookDispatcher(webhookRepo, notification.WebhookConfig{
+		MaxAttempts:  conf.WebhookMaxAttempts,
+		BackoffBase:  conf.WebhookBackoffBase,
+		BackoffMax:   conf.WebhookBackoffMax,
+		Timeout:      conf.WebhookTimeout,
+		PollInterval: conf.WebhookPollInterval,
+		Concurrency:  conf.WebhookConcurrency,
+	}, logger)
+
+	authorizer := notification.NewAuthorizer([]byte(conf.JWTSecret), redisClient)
+	grpcServer := grpc.NewServer()
+	notificationpb.RegisterNotificationServiceServer(grpcServer, notification.NewGrpcHandler(
+		notification.NewInbox(repo),
+		notification.NewWebhooks(webhookRepo),
+		notification.NewPreferenceService(preferenceRepo, templates),
+		notification.NewRules(ruleRepo, templates),
+		authorizer,
+	))
+	grpcListener, err := net.Listen("tcp", ":"+conf.GRPCPort)
+	if err != nil {
+		logger.Fatalf("failed to listen gRPC: %v", err)
+	}
 
-	errCh := make(chan error, 1)
+	httpServer := &http.Server{
+		Addr: ":" + conf.HTTPPort,
+		Handler: notification.NewHTTPHandler(notification.HTTPConfig{
+			Consumers: []notification.Consumer{consumer, documentConsumer},
+			Checks: map[string]notification.ReadinessCheck{
+				"kafka":    notification.KafkaReachable(brokers),
+				"auth":     authConnected(conn),
+				"postgres": sqlDB.PingContext,
+			},
+			Auth:   authorizer,
+			Logger: logger,
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errCh := make(chan error, 4)
+	dispatcherDone := make(chan struct{})
+	routerDone := make(chan struct{})
+	schedulerDone := make(chan struct{})
+
+	go func() {
+		logger.Printf("gRPC server listening on %s", grpcListener.Addr().String())
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			errCh <- fmt.Errorf("grpc server: %w", err)
+		}
+	}()
+
+	go func() {
+		logger.Printf("HTTP server listening on :%s", conf.HTTPPort)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- fmt.Errorf("http server: %w", err)
+		}
+	}()
 
 	go func() {
 		logger.Printf("Kafka consumer subscribing to topic=%s group=%s", conf.KafkaTopic, conf.KafkaGroupID)
@@ -59,18 +239,94 @@ func main() {
 		}
 	}()
 
+	go func() {
+		logger.Printf("Kafka consumer subscribing to topic=%s group=%s", conf.KafkaDocumentTopic, conf.KafkaDocumentGroupID)
+		if err := documentConsumer.Start(ctx); err != nil {
+			errCh <- err
+		} else {
+			errCh <- nil
+		}
+	}()
+
+	go func() {
+		defer close(dispatcherDone)
+		_ = dispatcher.Run(ctx)
+	}()
+
+	go func() {
+		defer close(routerDone)
+		_ = router.Run(ctx)
+	}()
+
+	go func() {
+		defer close(schedulerDone)
+		_ = scheduler.Run(ctx)
+	}()
+
 	select {
 	case <-ctx.Done():
 		logger.Println("shutdown signal received")
 	case err := <-errCh:
 		if err != nil {
-			logger.Printf("consumer error: %v", err)
+			logger.Printf("service error: %v", err)
 		}
 	}
 
+	grpcServer.GracefulStop()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	_ = httpServer.Shutdown(shutdownCtx)
+	cancelShutdown()
+	// Диспетчер дожидается начатых отправок webhook, consumer - обработки текущего сообщения
+	stop()
+	_ = consumer.Stop()
+	_ = documentConsumer.Stop()
+	<-dispatcherDone
+	<-routerDone
+	<-schedulerDone
 	logger.Println("notification service stopped")
 }
 
+// authConnected проверяет соединение с auth gRPC. Простаивающее соединение
+// переподключается при следующем вызове и считается готовым.
+func authConnected(conn *grpc.ClientConn) notification.ReadinessCheck {
+	return func(context.Context) error {
+		switch state := conn.GetState(); state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Idle:
+			conn.Connect()
+			return nil
+		default:
+			return fmt.Errorf("auth gRPC connection is %s", strings.ToLower(state.String()))
+		}
+	}
+}
+
+func mustConnectDB(conf cfg.Config) *gorm.DB {
+	dsn := fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		conf.DBHost,
+		conf.DBPort,
+		conf.DBUser,
+		conf.DBPassword,
+		conf.DBName,
+	)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("failed to init sql DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(10)
+	sqlDB.SetMaxIdleConns(5)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	return db
+}
+
 func splitCSV(value string) []string {
 	parts := strings.Split(value, ",")
 	var result []string
@@ -0,0 +1,67 @@
+package authclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxCachedManagers - при большем числе записей устаревшие удаляются
+const maxCachedManagers = 10000
+
+type managerResolver interface {
+	ResolveManager(ctx context.Context, userID string) (string, error)
+}
+
+type cachedManager struct {
+	managerID string
+	expiresAt time.Time
+}
+
+// ManagerCache кэширует ResolveManager на ttl, в том числе ответ "менеджер не назначен".
+// Ошибки не кэшируются. Смена менеджера видна не позже чем через ttl.
+type ManagerCache struct {
+	resolver managerResolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedManager
+}
+
+func NewManagerCache(resolver managerResolver, ttl time.Duration) *ManagerCache {
+	return &ManagerCache{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  make(map[string]cachedManager),
+	}
+}
+
+func (c *ManagerCache) ResolveManager(ctx context.Context, userID string) (string, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.managerID, nil
+	}
+
+	managerID, err := c.resolver.ResolveManager(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedManagers {
+		for id, cached := range c.entries {
+			if !now.Before(cached.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= maxCachedManagers {
+			clear(c.entries)
+		}
+	}
+	c.entries[userID] = cachedManager{managerID: managerID, expiresAt: now.Add(c.ttl)}
+	return managerID, nil
+}
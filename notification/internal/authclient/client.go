@@ -7,6 +7,8 @@ import (
 
 	pb "github.com/Oniqq60/task_system_control/gen/proto/auth/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Client struct {
@@ -42,3 +44,24 @@ func (c *Client) ResolveManager(ctx context.Context, userID string) (string, err
 
 	return resp.GetManagerId(), nil
 }
+
+// ResolveContact возвращает email и имя пользователя. Для неизвестного пользователя
+// возвращается пустой email без ошибки.
+func (c *Client) ResolveContact(ctx context.Context, userID string) (email, name string, err error) {
+	if userID == "" {
+		return "", "", fmt.Errorf("userID is required")
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.rpc.GetUserContact(callCtx, &pb.GetUserContactRequest{UserId: userID})
+	if status.Code(err) == codes.NotFound {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("auth.GetUserContact RPC failed: %w", err)
+	}
+
+	return resp.GetEmail(), resp.GetName(), nil
+}
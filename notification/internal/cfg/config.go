@@ -3,6 +3,8 @@ package cfg
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +17,71 @@ type Config struct {
 	KafkaGroupID string
 	LogLevel     string
 	AuthGRPCAddr string
+
+	// Топик событий документов (для webhook) и consumer group его читателя: у каждого топика своя
+	// группа, иначе читатели двух топиков в одной группе мешают друг другу при ребалансировке
+	KafkaDocumentTopic   string
+	KafkaDocumentGroupID string
+	// Топик, в который публикуются созданные уведомления (для api_gateway /events)
+	KafkaNotificationTopic string
+
+	// Повторы обработки событий и dead-letter топик для событий, которые не обработались
+	KafkaDLQTopic       string
+	ConsumerWorkers     int
+	ConsumerLagInterval time.Duration
+	ConsumerMaxAttempts int
+	ConsumerBackoffBase time.Duration
+	ConsumerBackoffMax  time.Duration
+	// Сколько помнить обработанные события, чтобы отбрасывать повторные доставки
+	DedupTTL time.Duration
+	// Сколько держится отметка обрабатываемого события, если экземпляр упал до конца обработки
+	DedupProcessingTTL time.Duration
+
+	// gRPC API ящика уведомлений
+	GRPCPort      string
+	DBHost        string
+	DBPort        string
+	DBUser        string
+	DBPassword    string
+	DBName        string
+	JWTSecret     string
+	RedisAddr     string
+	RedisPassword string
+
+	// Сколько помнить менеджера сотрудника, полученного от auth
+	ManagerCacheTTL time.Duration
+
+	// Язык шаблонов уведомлений
+	NotifyLocale string
+
+	// Email уведомления; при пустом SMTPHost письма не отправляются
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPSecurity string
+	SMTPTimeout  time.Duration
+
+	// Доставка webhook
+	WebhookMaxAttempts  int
+	WebhookBackoffBase  time.Duration
+	WebhookBackoffMax   time.Duration
+	WebhookTimeout      time.Duration
+	WebhookPollInterval time.Duration
+	WebhookConcurrency  int
+
+	// Как часто проверяются отложенные правила и уведомления (тихие часы, сводки)
+	RulesPollInterval time.Duration
+
+	// Сводки менеджерам по расписанию cron; пустое расписание - сводки выключены
+	DigestSchedule     string
+	DigestTimezone     string
+	DigestOverdueAfter time.Duration
+	// Эскалация непрочитанных уведомлений по цепочке менеджеров; пустой список типов - выключена
+	EscalationTypes     string
+	EscalationTimeout   time.Duration
+	EscalationMaxLevels int
 }
 
 // LoadConfig загружает конфигурацию из окружения/.env
@@ -28,11 +95,66 @@ func LoadConfig() Config {
 		KafkaGroupID: getEnvOrDefault("KAFKA_GROUP_ID", "notification-service"),
 		LogLevel:     getEnvOrDefault("LOG_LEVEL", "info"),
 		AuthGRPCAddr: getEnvOrDefault("AUTH_GRPC_ADDR", "auth:9090"),
+
+		KafkaDocumentTopic:     getEnvOrDefault("KAFKA_DOCUMENT_TOPIC", "document-events"),
+		KafkaDocumentGroupID:   getEnvOrDefault("KAFKA_DOCUMENT_GROUP_ID", "notification-service-documents"),
+		KafkaNotificationTopic: getEnvOrDefault("KAFKA_NOTIFICATION_TOPIC", "notification-events"),
+
+		KafkaDLQTopic:       getEnvOrDefault("KAFKA_DLQ_TOPIC", "notification-dlq"),
+		ConsumerWorkers:     getIntOrDefault("CONSUMER_WORKERS", 4),
+		ConsumerLagInterval: getDurationOrDefault("CONSUMER_LAG_INTERVAL", 30*time.Second),
+		ConsumerMaxAttempts: getIntOrDefault("CONSUMER_MAX_ATTEMPTS", 5),
+		ConsumerBackoffBase: getDurationOrDefault("CONSUMER_BACKOFF_BASE", time.Second),
+		ConsumerBackoffMax:  getDurationOrDefault("CONSUMER_BACKOFF_MAX", 30*time.Second),
+		DedupTTL:            getDurationOrDefault("DEDUP_TTL", 7*24*time.Hour),
+		DedupProcessingTTL:  getDurationOrDefault("DEDUP_PROCESSING_TTL", time.Minute),
+
+		GRPCPort:      getEnvOrDefault("GRPC_PORT", "9095"),
+		DBHost:        getEnvOrDefault("DB_HOST", "postgres"),
+		DBPort:        getEnvOrDefault("DB_PORT", "5432"),
+		DBUser:        os.Getenv("DB_USER"),
+		DBPassword:    os.Getenv("DB_PASSWORD"),
+		DBName:        getEnvOrDefault("DB_NAME", "notification"),
+		JWTSecret:     os.Getenv("JWT_SECRET"),
+		RedisAddr:     os.Getenv("REDIS_ADDR"),
+		RedisPassword: os.Getenv("REDIS_PASSWORD"),
+
+		ManagerCacheTTL: getDurationOrDefault("MANAGER_CACHE_TTL", 30*time.Second),
+
+		NotifyLocale: getEnvOrDefault("NOTIFY_LOCALE", "ru"),
+
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvOrDefault("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     getEnvOrDefault("SMTP_FROM", "Task System <noreply@task-system.local>"),
+		SMTPSecurity: getEnvOrDefault("SMTP_SECURITY", "starttls"),
+		SMTPTimeout:  getDurationOrDefault("SMTP_TIMEOUT", 10*time.Second),
+
+		WebhookMaxAttempts:  getIntOrDefault("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookBackoffBase:  getDurationOrDefault("WEBHOOK_BACKOFF_BASE", 10*time.Second),
+		WebhookBackoffMax:   getDurationOrDefault("WEBHOOK_BACKOFF_MAX", time.Hour),
+		WebhookTimeout:      getDurationOrDefault("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookPollInterval: getDurationOrDefault("WEBHOOK_POLL_INTERVAL", 2*time.Second),
+		WebhookConcurrency:  getIntOrDefault("WEBHOOK_CONCURRENCY", 4),
+
+		RulesPollInterval: getDurationOrDefault("RULES_POLL_INTERVAL", 30*time.Second),
+
+		DigestSchedule:     getEnvAllowEmpty("DIGEST_SCHEDULE", "0 9 * * *"),
+		DigestTimezone:     getEnvOrDefault("DIGEST_TIMEZONE", "UTC"),
+		DigestOverdueAfter: getDurationOrDefault("DIGEST_OVERDUE_AFTER", 72*time.Hour),
+
+		EscalationTypes:     getEnvAllowEmpty("ESCALATION_TYPES", "task_needs_help,task_escalated"),
+		EscalationTimeout:   getDurationOrDefault("ESCALATION_TIMEOUT", 4*time.Hour),
+		EscalationMaxLevels: getIntOrDefault("ESCALATION_MAX_LEVELS", 3),
 	}
 
 	if cfg.KafkaBrokers == "" {
 		log.Fatal("KAFKA_BROKERS is required")
 	}
+	if cfg.JWTSecret == "" {
+		log.Fatal("JWT_SECRET is required")
+	}
 
 	return cfg
 }
@@ -43,3 +165,35 @@ func getEnvOrDefault(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvAllowEmpty в отличие от getEnvOrDefault оставляет явно заданное пустое значение
+func getEnvAllowEmpty(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Fatalf("invalid %s: %q", key, value)
+	}
+	return duration
+}
+
+func getIntOrDefault(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Fatalf("invalid %s: %q", key, value)
+	}
+	return number
+}
@@ -3,83 +3,649 @@ package notification
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// Заголовки сообщения в dead-letter топике
+const (
+	HeaderDLQTopic     = "x-dlq-original-topic"
+	HeaderDLQPartition = "x-dlq-original-partition"
+	HeaderDLQOffset    = "x-dlq-original-offset"
+	HeaderDLQGroup     = "x-dlq-consumer-group"
+	HeaderDLQError     = "x-dlq-error"
+	HeaderDLQAttempts  = "x-dlq-attempts"
+	HeaderDLQFailedAt  = "x-dlq-failed-at"
+)
+
+const (
+	defaultConsumerMaxAttempts = 5
+	defaultConsumerBackoffBase = time.Second
+	defaultConsumerBackoffMax  = 30 * time.Second
+	defaultConsumerLagInterval = 30 * time.Second
+	// workerQueueSize - сколько прочитанных сообщений ждёт своего worker
+	workerQueueSize = 16
+)
+
+// ErrMalformedEvent - сообщение не разбирается; повтор не поможет, оно сразу уходит в dead-letter топик
+var ErrMalformedEvent = errors.New("malformed event")
+
 // Consumer читает сообщения из Kafka
 type Consumer interface {
 	Start(ctx context.Context) error
+	// Stop прекращает чтение и ждёт, пока обработаются начатые сообщения
 	Stop() error
 	Close() error
+	Status() ConsumerStatus
+	// Pause приостанавливает чтение новых сообщений; начатые сообщения дообрабатываются
+	Pause()
+	Resume()
+}
+
+// ConsumerStatus - состояние чтения топика. Lag - сколько сообщений ещё не закоммичено
+// группой (по high watermark последнего прочитанного сообщения партиции).
+// LastError - последняя ошибка чтения, обработки или коммита.
+type ConsumerStatus struct {
+	Topic       string            `json:"topic"`
+	GroupID     string            `json:"group_id"`
+	Workers     int               `json:"workers"`
+	Paused      bool              `json:"paused"`
+	Lag         int64             `json:"lag"`
+	Partitions  []PartitionStatus `json:"partitions"`
+	LastError   string            `json:"last_error,omitempty"`
+	LastErrorAt *time.Time        `json:"last_error_at,omitempty"`
+}
+
+type PartitionStatus struct {
+	Partition     int   `json:"partition"`
+	Committed     int64 `json:"committed"`
+	HighWaterMark int64 `json:"high_water_mark"`
+	Lag           int64 `json:"lag"`
 }
 
 type EventHandler interface {
 	HandleEvent(ctx context.Context, event TaskEvent) error
 }
 
+type DocumentEventHandler interface {
+	HandleDocumentEvent(ctx context.Context, event DocumentEvent) error
+}
+
+// ConsumerConfig - параметры чтения топика. Сообщение, которое не обработалось за MaxAttempts
+// попыток (пауза BackoffBase * 2^(n-1), не больше BackoffMax), публикуется в DeadLetterTopic
+// и коммитится. Пустой DeadLetterTopic - такое сообщение только пишется в лог.
+// Сообщения обрабатывают Workers worker; сообщения с одним ключом - всегда один и тот же,
+// по порядку. Раз в LagInterval в лог пишется отставание группы, если оно есть.
+type ConsumerConfig struct {
+	Brokers         []string
+	Topic           string
+	GroupID         string
+	Workers         int
+	MaxAttempts     int
+	BackoffBase     time.Duration
+	BackoffMax      time.Duration
+	DeadLetterTopic string
+	LagInterval     time.Duration
+	Logger          *log.Logger
+}
+
 type kafkaConsumer struct {
-	reader  *kafka.Reader
-	handler EventHandler
-	topic   string
-	groupID string
+	config ConsumerConfig
+	reader *kafka.Reader
+	dlq    *kafka.Writer
+	handle func(ctx context.Context, value []byte) error
+	logger *log.Logger
+
+	offsets  *offsetTracker
+	commitMu sync.Mutex
+
+	mu       sync.Mutex
+	stopped  bool
+	stopping chan struct{}
+	running  sync.WaitGroup
+	// resumed не nil, пока чтение приостановлено; закрывается в Resume
+	resumed     chan struct{}
+	lastError   string
+	lastErrorAt time.Time
 }
 
-func NewKafkaConsumer(brokers []string, topic, groupID string, handler EventHandler) Consumer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  brokers,
-		Topic:    topic,
-		GroupID:  groupID,
-		MinBytes: 10e3,
-		MaxBytes: 10e6,
+// NewKafkaConsumer читает события задач
+func NewKafkaConsumer(config ConsumerConfig, handler EventHandler) Consumer {
+	return newKafkaConsumer(config, func(ctx context.Context, value []byte) error {
+		var event TaskEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("%w: unmarshal task event: %v", ErrMalformedEvent, err)
+		}
+		return handler.HandleEvent(ctx, event)
 	})
+}
 
-	return &kafkaConsumer{
-		reader:  reader,
-		handler: handler,
-		topic:   topic,
-		groupID: groupID,
+// NewDocumentEventConsumer читает события документов
+func NewDocumentEventConsumer(config ConsumerConfig, handler DocumentEventHandler) Consumer {
+	return newKafkaConsumer(config, func(ctx context.Context, value []byte) error {
+		var event DocumentEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("%w: unmarshal document event: %v", ErrMalformedEvent, err)
+		}
+		return handler.HandleDocumentEvent(ctx, event)
+	})
+}
+
+func newKafkaConsumer(config ConsumerConfig, handle func(ctx context.Context, value []byte) error) *kafkaConsumer {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultConsumerMaxAttempts
+	}
+	if config.LagInterval <= 0 {
+		config.LagInterval = defaultConsumerLagInterval
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = defaultConsumerBackoffBase
 	}
+	if config.BackoffMax < config.BackoffBase {
+		config.BackoffMax = max(defaultConsumerBackoffMax, config.BackoffBase)
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	consumer := &kafkaConsumer{
+		config: config,
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:  config.Brokers,
+			Topic:    config.Topic,
+			GroupID:  config.GroupID,
+			MinBytes: 10e3,
+			MaxBytes: 10e6,
+		}),
+		handle:   handle,
+		logger:   logger,
+		offsets:  newOffsetTracker(),
+		stopping: make(chan struct{}),
+	}
+	if config.DeadLetterTopic != "" {
+		consumer.dlq = &kafka.Writer{
+			Addr:     kafka.TCP(config.Brokers...),
+			Topic:    config.DeadLetterTopic,
+			Balancer: &kafka.Hash{},
+		}
+	}
+	return consumer
 }
 
-// Start читает сообщения в цикле до отмены контекста
-// TODO: обработка ошибок, json.Unmarshal, graceful shutdown
+// Start читает сообщения до отмены контекста или Stop и раздаёт их worker по ключу.
+// Offset партиции коммитится, когда обработаны (или опубликованы в dead-letter топик)
+// все прочитанные до него сообщения; необработанные при остановке сообщения будут прочитаны снова.
 func (c *kafkaConsumer) Start(ctx context.Context) error {
-	log.Printf("Kafka consumer started (topic=%s, group=%s)", c.topic, c.groupID)
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return nil
+	}
+	c.running.Add(1)
+	c.mu.Unlock()
+	defer c.running.Done()
 
-	for {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
 		select {
+		case <-c.stopping:
+			cancel()
 		case <-ctx.Done():
-			log.Println("Kafka consumer context cancelled")
-			return ctx.Err()
-		default:
-			msg, err := c.reader.ReadMessage(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					return ctx.Err()
+		}
+	}()
+
+	var workers sync.WaitGroup
+	queues := make([]chan kafka.Message, c.config.Workers)
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, workerQueueSize)
+		workers.Add(1)
+		go func(queue <-chan kafka.Message) {
+			defer workers.Done()
+			for msg := range queue {
+				// После остановки новые сообщения не начинаются
+				if ctx.Err() == nil {
+					c.process(ctx, msg)
 				}
-				log.Printf("read message error: %v", err)
-				continue
 			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		workers.Wait()
+	}()
 
-			var event TaskEvent
-			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				log.Printf("unmarshal task event error: %v", err)
-				continue
+	go c.reportLag(ctx)
+
+	c.logger.Printf("Kafka consumer started (topic=%s, group=%s, workers=%d)", c.config.Topic, c.config.GroupID, c.config.Workers)
+	for {
+		if resumed := c.pausedUntil(); resumed != nil {
+			select {
+			case <-resumed:
+			case <-ctx.Done():
+				c.logger.Printf("Kafka consumer stopped (topic=%s)", c.config.Topic)
+				return nil
 			}
+		}
 
-			if err := c.handler.HandleEvent(ctx, event); err != nil {
-				log.Printf("handle event error: %v", err)
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.logger.Printf("Kafka consumer stopped (topic=%s)", c.config.Topic)
+				return nil
 			}
+			c.logger.Printf("fetch message error (topic=%s): %v", c.config.Topic, err)
+			c.recordError(fmt.Errorf("fetch message: %w", err))
+			if !sleepContext(ctx, c.config.BackoffBase) {
+				return nil
+			}
+			continue
+		}
+
+		c.offsets.fetched(msg)
+		select {
+		case queues[c.worker(msg)] <- msg:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// worker выбирает worker по ключу сообщения, чтобы события одной задачи обрабатывались по порядку;
+// сообщения без ключа распределяются по партиции
+func (c *kafkaConsumer) worker(msg kafka.Message) int {
+	if len(msg.Key) == 0 {
+		return msg.Partition % c.config.Workers
+	}
+	hash := fnv.New32a()
+	hash.Write(msg.Key)
+	return int(hash.Sum32() % uint32(c.config.Workers))
+}
+
+// process обрабатывает сообщение с повторами и коммитит его. Начатая попытка завершается
+// даже при остановке, новые попытки после остановки не начинаются и сообщение не коммитится.
+func (c *kafkaConsumer) process(ctx context.Context, msg kafka.Message) {
+	work := context.WithoutCancel(ctx)
+
+	var err error
+	attempts := 0
+	for attempts < c.config.MaxAttempts {
+		attempts++
+		if err = c.handle(work, msg.Value); err == nil || errors.Is(err, ErrMalformedEvent) {
+			break
+		}
+		c.logger.Printf("handle event error (topic=%s offset=%d attempt=%d/%d): %v",
+			c.config.Topic, msg.Offset, attempts, c.config.MaxAttempts, err)
+		if attempts < c.config.MaxAttempts && !sleepContext(ctx, backoff(c.config.BackoffBase, c.config.BackoffMax, attempts)) {
+			return
+		}
+	}
+
+	if err != nil {
+		c.recordError(fmt.Errorf("handle event (partition=%d offset=%d): %w", msg.Partition, msg.Offset, err))
+		if !c.deadLetter(ctx, msg, err, attempts) {
+			return
+		}
+	}
+	c.commit(work, msg)
+}
+
+// commit отмечает сообщение обработанным и коммитит offset партиции, до которого обработано всё
+func (c *kafkaConsumer) commit(ctx context.Context, msg kafka.Message) {
+	offset, ok := c.offsets.done(msg)
+	if !ok {
+		return
+	}
+
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
+	// Другой worker мог уже закоммитить offset дальше
+	if offset < c.offsets.committed(msg.Partition) {
+		return
+	}
+	err := c.reader.CommitMessages(ctx, kafka.Message{Topic: msg.Topic, Partition: msg.Partition, Offset: offset})
+	if err != nil {
+		c.logger.Printf("commit offset error (topic=%s partition=%d offset=%d): %v", c.config.Topic, msg.Partition, offset, err)
+		c.recordError(fmt.Errorf("commit offset (partition=%d offset=%d): %w", msg.Partition, offset, err))
+		return
+	}
+	c.offsets.commit(msg.Partition, offset)
+}
+
+func (c *kafkaConsumer) Status() ConsumerStatus {
+	status := ConsumerStatus{
+		Topic:      c.config.Topic,
+		GroupID:    c.config.GroupID,
+		Workers:    c.config.Workers,
+		Partitions: c.offsets.status(),
+	}
+	for _, partition := range status.Partitions {
+		status.Lag += partition.Lag
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status.Paused = c.resumed != nil
+	if c.lastError != "" {
+		at := c.lastErrorAt
+		status.LastError, status.LastErrorAt = c.lastError, &at
+	}
+	return status
+}
+
+func (c *kafkaConsumer) recordError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastError, c.lastErrorAt = err.Error(), time.Now()
+}
+
+func (c *kafkaConsumer) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed == nil {
+		c.resumed = make(chan struct{})
+		c.logger.Printf("Kafka consumer paused (topic=%s)", c.config.Topic)
+	}
+}
+
+func (c *kafkaConsumer) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+		c.logger.Printf("Kafka consumer resumed (topic=%s)", c.config.Topic)
+	}
+}
+
+// pausedUntil возвращает канал, который закроется при Resume; nil - чтение не приостановлено
+func (c *kafkaConsumer) pausedUntil() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resumed
+}
+
+// reportLag пишет в лог отставание группы, пока оно есть
+func (c *kafkaConsumer) reportLag(ctx context.Context) {
+	ticker := time.NewTicker(c.config.LagInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		status := c.Status()
+		if status.Lag == 0 {
+			continue
+		}
+		parts := make([]string, 0, len(status.Partitions))
+		for _, partition := range status.Partitions {
+			parts = append(parts, fmt.Sprintf("%d:%d", partition.Partition, partition.Lag))
+		}
+		c.logger.Printf("consumer lag topic=%s group=%s lag=%d partitions=%s",
+			status.Topic, status.GroupID, status.Lag, strings.Join(parts, ","))
+	}
+}
+
+// deadLetter публикует сообщение в dead-letter топик; пока публикация не удалась,
+// сообщение не коммитится
+func (c *kafkaConsumer) deadLetter(ctx context.Context, msg kafka.Message, cause error, attempts int) bool {
+	if c.dlq == nil {
+		c.logger.Printf("drop event (topic=%s offset=%d) after %d attempts: %v", c.config.Topic, msg.Offset, attempts, cause)
+		return true
+	}
+
+	headers := append([]kafka.Header{}, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDLQTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderDLQPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderDLQOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: HeaderDLQGroup, Value: []byte(c.config.GroupID)},
+		kafka.Header{Key: HeaderDLQError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDLQAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+	dead := kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers}
+
+	for attempt := 1; ; attempt++ {
+		err := c.dlq.WriteMessages(context.WithoutCancel(ctx), dead)
+		if err == nil {
+			c.logger.Printf("event (topic=%s offset=%d) moved to %s after %d attempts: %v",
+				c.config.Topic, msg.Offset, c.config.DeadLetterTopic, attempts, cause)
+			return true
+		}
+		c.logger.Printf("publish to dead-letter topic %s: %v", c.config.DeadLetterTopic, err)
+		c.recordError(fmt.Errorf("publish to dead-letter topic: %w", err))
+		if !sleepContext(ctx, backoff(c.config.BackoffBase, c.config.BackoffMax, attempt)) {
+			return false
 		}
 	}
 }
 
 func (c *kafkaConsumer) Stop() error {
+	c.mu.Lock()
+	if !c.stopped {
+		c.stopped = true
+		close(c.stopping)
+	}
+	c.mu.Unlock()
+	c.running.Wait()
 	return nil
 }
 
 func (c *kafkaConsumer) Close() error {
-	return c.reader.Close()
+	errs := []error{c.reader.Close()}
+	if c.dlq != nil {
+		errs = append(errs, c.dlq.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// offsetTracker помнит порядок прочитанных сообщений каждой партиции. Сообщения одной
+// партиции с разными ключами обрабатывают разные worker, поэтому они завершаются не по порядку,
+// а коммитить можно только offset, до которого обработано всё.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	pending       []int64 // прочитанные, но не закоммиченные offset в порядке чтения
+	done          map[int64]int
+	committed     int64 // следующий offset после закоммиченного
+	highWaterMark int64
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets)}
+}
+
+func (t *offsetTracker) partition(partition int) *partitionOffsets {
+	p, ok := t.partitions[partition]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]int), committed: -1}
+		t.partitions[partition] = p
+	}
+	return p
+}
+
+func (t *offsetTracker) fetched(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.partition(msg.Partition)
+	p.pending = append(p.pending, msg.Offset)
+	p.highWaterMark = msg.HighWaterMark
+}
+
+// done отмечает сообщение обработанным и возвращает последний offset, до которого
+// обработаны все прочитанные сообщения партиции; false - коммитить пока нечего
+func (t *offsetTracker) done(msg kafka.Message) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.partition(msg.Partition)
+	p.done[msg.Offset]++
+
+	last := int64(-1)
+	for len(p.pending) > 0 && p.done[p.pending[0]] > 0 {
+		last = p.pending[0]
+		if p.done[last]--; p.done[last] == 0 {
+			delete(p.done, last)
+		}
+		p.pending = p.pending[1:]
+	}
+	return last, last >= 0
+}
+
+// committed возвращает следующий offset после закоммиченного; -1 - коммитов не было
+func (t *offsetTracker) committed(partition int) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.partition(partition).committed
+}
+
+func (t *offsetTracker) commit(partition int, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.partition(partition)
+	p.committed = max(p.committed, offset+1)
+}
+
+func (t *offsetTracker) status() []PartitionStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]PartitionStatus, 0, len(t.partitions))
+	for partition, p := range t.partitions {
+		status := PartitionStatus{Partition: partition, Committed: p.committed, HighWaterMark: p.highWaterMark}
+		if p.committed >= 0 {
+			status.Lag = max(p.highWaterMark-p.committed, 0)
+		} else if len(p.pending) > 0 {
+			status.Lag = max(p.highWaterMark-p.pending[0], 0)
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Partition < result[j].Partition })
+	return result
+}
+
+// backoff возвращает паузу перед попыткой attempts+1: base * 2^(attempts-1), не больше limit
+func backoff(base, limit time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// sleepContext ждёт d; false - контекст отменён раньше
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// DeadLetterReplayConfig - параметры повторной публикации из dead-letter топика.
+// Topic ограничивает повтор сообщениями одного исходного топика (пусто - все).
+type DeadLetterReplayConfig struct {
+	Brokers         []string
+	DeadLetterTopic string
+	GroupID         string
+	Topic           string
+	// Idle - сколько ждать новых сообщений, прежде чем считать топик прочитанным
+	Idle time.Duration
+}
+
+// ReplayDeadLetters публикует сообщения dead-letter топика обратно в исходные топики
+// и коммитит их в группе GroupID; повторный запуск продолжает с места остановки.
+// Сообщения других топиков при заданном Topic пропускаются и тоже коммитятся.
+func ReplayDeadLetters(ctx context.Context, config DeadLetterReplayConfig, logger *log.Logger) (int, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.Idle <= 0 {
+		config.Idle = 10 * time.Second
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     config.Brokers,
+		Topic:       config.DeadLetterTopic,
+		GroupID:     config.GroupID,
+		StartOffset: kafka.FirstOffset,
+	})
+	defer reader.Close()
+	// Топик задаётся в каждом сообщении
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(config.Brokers...),
+		Balancer: &kafka.Hash{},
+	}
+	defer writer.Close()
+
+	replayed := 0
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, config.Idle)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return replayed, nil
+			}
+			return replayed, err
+		}
+
+		topic, headers := splitDeadLetterHeaders(msg.Headers)
+		switch {
+		case topic == "":
+			logger.Printf("skip dead letter at offset %d: no %s header", msg.Offset, HeaderDLQTopic)
+		case config.Topic != "" && topic != config.Topic:
+		default:
+			err := writer.WriteMessages(ctx, kafka.Message{
+				Topic:   topic,
+				Key:     msg.Key,
+				Value:   msg.Value,
+				Headers: headers,
+			})
+			if err != nil {
+				return replayed, fmt.Errorf("publish to %s: %w", topic, err)
+			}
+			replayed++
+		}
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			return replayed, fmt.Errorf("commit dead letter offset: %w", err)
+		}
+	}
+}
+
+// splitDeadLetterHeaders возвращает исходный топик и заголовки исходного сообщения
+func splitDeadLetterHeaders(headers []kafka.Header) (string, []kafka.Header) {
+	var topic string
+	var original []kafka.Header
+	for _, header := range headers {
+		switch header.Key {
+		case HeaderDLQTopic:
+			topic = string(header.Value)
+		case HeaderDLQPartition, HeaderDLQOffset, HeaderDLQGroup, HeaderDLQError, HeaderDLQAttempts, HeaderDLQFailedAt:
+		default:
+			original = append(original, header)
+		}
+	}
+	return topic, original
 }
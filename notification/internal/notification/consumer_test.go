@@ -0,0 +1,91 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Offset коммитится только до первого необработанного сообщения партиции
+func TestOffsetTrackerCommitsContiguousOffsets(t *testing.T) {
+	type step struct {
+		partition int
+		offset    int64
+		commit    int64 // -1 - коммитить нечего
+	}
+	tests := []struct {
+		name    string
+		fetched map[int][]int64
+		done    []step
+	}{
+		{
+			name:    "in order",
+			fetched: map[int][]int64{0: {10, 11, 12}},
+			done:    []step{{0, 10, 10}, {0, 11, 11}, {0, 12, 12}},
+		},
+		{
+			name:    "later offsets wait for the first",
+			fetched: map[int][]int64{0: {10, 11, 12, 13, 14}},
+			done:    []step{{0, 12, -1}, {0, 11, -1}, {0, 10, 12}, {0, 14, -1}, {0, 13, 14}},
+		},
+		{
+			name:    "gap in the middle",
+			fetched: map[int][]int64{0: {10, 11, 12, 13}},
+			done:    []step{{0, 10, 10}, {0, 12, -1}, {0, 13, -1}, {0, 11, 13}},
+		},
+		{
+			name:    "partitions are independent",
+			fetched: map[int][]int64{0: {5, 6}, 1: {20, 21}},
+			done:    []step{{0, 6, -1}, {1, 20, 20}, {0, 5, 6}, {1, 21, 21}},
+		},
+		{
+			// После ребалансировки сообщение может прийти ещё раз, пока первое не обработано
+			name:    "redelivered offset",
+			fetched: map[int][]int64{0: {10, 11, 10, 11}},
+			done:    []step{{0, 10, 10}, {0, 11, 11}, {0, 11, -1}, {0, 10, 11}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newOffsetTracker()
+			for partition, offsets := range tt.fetched {
+				for _, offset := range offsets {
+					tracker.fetched(kafka.Message{Partition: partition, Offset: offset})
+				}
+			}
+			for i, s := range tt.done {
+				offset, ok := tracker.done(kafka.Message{Partition: s.partition, Offset: s.offset})
+				if s.commit < 0 {
+					if ok {
+						t.Fatalf("step %d: done(%d[%d]) = %d, want nothing to commit", i, s.partition, s.offset, offset)
+					}
+					continue
+				}
+				if !ok || offset != s.commit {
+					t.Fatalf("step %d: done(%d[%d]) = %d, %v; want %d", i, s.partition, s.offset, offset, ok, s.commit)
+				}
+			}
+		})
+	}
+}
+
+func TestOffsetTrackerStatus(t *testing.T) {
+	tracker := newOffsetTracker()
+	for _, offset := range []int64{10, 11, 12} {
+		tracker.fetched(kafka.Message{Partition: 0, Offset: offset, HighWaterMark: 20})
+	}
+	if status := tracker.status(); len(status) != 1 || status[0].Committed != -1 || status[0].Lag != 10 {
+		t.Fatalf("before commit status = %+v, want lag 10 from the first fetched offset", status)
+	}
+
+	offset, _ := tracker.done(kafka.Message{Partition: 0, Offset: 10})
+	tracker.commit(0, offset)
+	// Поздний коммит меньшего offset не откатывает закоммиченный
+	tracker.commit(0, 5)
+	if got := tracker.committed(0); got != 11 {
+		t.Fatalf("committed = %d, want 11", got)
+	}
+	if status := tracker.status(); status[0].Lag != 9 {
+		t.Fatalf("after commit lag = %d, want 9", status[0].Lag)
+	}
+}
@@ -0,0 +1,147 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	dedupKeyPrefix            = "notification:event:"
+	defaultDedupTTL           = 7 * 24 * time.Hour
+	defaultDedupProcessingTTL = time.Minute
+
+	// dedupProcessing - значение ключа, пока событие обрабатывается; любое другое значение - событие обработано
+	dedupProcessing = "processing"
+	dedupDone       = "done"
+
+	// dedupBusyPollInterval - как часто проверять событие, которое обрабатывает другой экземпляр
+	dedupBusyPollInterval = time.Second
+)
+
+// ErrDedupBusy - событие сейчас обрабатывает другой экземпляр
+var ErrDedupBusy = errors.New("event is being processed")
+
+// DedupStore помнит обработанные события
+type DedupStore interface {
+	// Claim отмечает ключ на время обработки. false - событие уже обработано,
+	// ErrDedupBusy - событие обрабатывается, отметка снимется по Complete, Release или истечении TTL обработки.
+	Claim(ctx context.Context, key string) (bool, error)
+	// Complete отмечает событие обработанным
+	Complete(ctx context.Context, key string) error
+	// Release снимает отметку, если обработка не удалась
+	Release(ctx context.Context, key string) error
+}
+
+// redisDedupStore хранит отметки в Redis. Отметка обработки живёт processingTTL: если экземпляр
+// упал посреди обработки, событие обработает повторная доставка. Отметка готовности живёт ttl:
+// дубликат, пришедший позже, обработается снова.
+type redisDedupStore struct {
+	client        *redis.Client
+	ttl           time.Duration
+	processingTTL time.Duration
+}
+
+func NewRedisDedupStore(client *redis.Client, ttl, processingTTL time.Duration) DedupStore {
+	if ttl <= 0 {
+		ttl = defaultDedupTTL
+	}
+	if processingTTL <= 0 {
+		processingTTL = defaultDedupProcessingTTL
+	}
+	return &redisDedupStore{client: client, ttl: ttl, processingTTL: processingTTL}
+}
+
+func (s *redisDedupStore) Claim(ctx context.Context, key string) (bool, error) {
+	claimed, err := s.client.SetNX(ctx, dedupKeyPrefix+key, dedupProcessing, s.processingTTL).Result()
+	if err != nil || claimed {
+		return claimed, err
+	}
+	value, err := s.client.Get(ctx, dedupKeyPrefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		// Отметка истекла между SETNX и GET
+		return false, ErrDedupBusy
+	}
+	if err != nil {
+		return false, err
+	}
+	if value == dedupProcessing {
+		return false, ErrDedupBusy
+	}
+	return false, nil
+}
+
+func (s *redisDedupStore) Complete(ctx context.Context, key string) error {
+	return s.client.Set(ctx, dedupKeyPrefix+key, dedupDone, s.ttl).Err()
+}
+
+func (s *redisDedupStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, dedupKeyPrefix+key).Err()
+}
+
+// dedupHandler пропускает события, которые handler уже обработал. Ключ - name и EventID,
+// поэтому при повторе после ошибки одного из обработчиков fanout остальные событие пропустят.
+type dedupHandler struct {
+	store   DedupStore
+	name    string
+	handler EventHandler
+	logger  *log.Logger
+}
+
+func NewDedupHandler(store DedupStore, name string, handler EventHandler, logger *log.Logger) EventHandler {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &dedupHandler{store: store, name: name, handler: handler, logger: logger}
+}
+
+func (h *dedupHandler) HandleEvent(ctx context.Context, event TaskEvent) error {
+	// Событие без ID отличить от повтора нельзя
+	if event.EventID == "" {
+		return h.handler.HandleEvent(ctx, event)
+	}
+
+	key := h.name + ":" + event.EventID
+	claimed, err := h.claim(ctx, key)
+	if err != nil {
+		return fmt.Errorf("claim event %s: %w", event.EventID, err)
+	}
+	if !claimed {
+		h.logger.Printf("skip duplicate event %s (%s, task %s) for %s", event.EventID, event.EventType(), event.TaskID, h.name)
+		return nil
+	}
+
+	if err := h.handler.HandleEvent(ctx, event); err != nil {
+		// Повторная доставка должна обработать событие снова
+		if releaseErr := h.store.Release(context.WithoutCancel(ctx), key); releaseErr != nil {
+			h.logger.Printf("release event %s for %s: %v", event.EventID, h.name, releaseErr)
+		}
+		return err
+	}
+	if err := h.store.Complete(context.WithoutCancel(ctx), key); err != nil {
+		// Отметка обработки истечёт, и повтор обработает событие ещё раз: уведомления
+		// с тем же ID не продублируются
+		h.logger.Printf("complete event %s for %s: %v", event.EventID, h.name, err)
+	}
+	return nil
+}
+
+// claim ждёт, пока другой экземпляр обработает событие или его отметка истечёт:
+// пропустить событие, которое обрабатывается, нельзя - обработка может не удаться
+func (h *dedupHandler) claim(ctx context.Context, key string) (bool, error) {
+	for {
+		claimed, err := h.store.Claim(ctx, key)
+		if !errors.Is(err, ErrDedupBusy) {
+			return claimed, err
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(dedupBusyPollInterval):
+		}
+	}
+}
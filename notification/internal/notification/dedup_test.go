@@ -0,0 +1,425 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryDedupStore - DedupStore в памяти для тестов. Отметка обработки живёт processingTTL.
+type memoryDedupStore struct {
+	processingTTL time.Duration
+
+	mu   sync.Mutex
+	keys map[string]dedupEntry
+}
+
+type dedupEntry struct {
+	done      bool
+	expiresAt time.Time
+}
+
+func newMemoryDedupStore() *memoryDedupStore {
+	return &memoryDedupStore{processingTTL: time.Minute, keys: make(map[string]dedupEntry)}
+}
+
+func (s *memoryDedupStore) Claim(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.keys[key]; ok {
+		if entry.done {
+			return false, nil
+		}
+		if time.Now().Before(entry.expiresAt) {
+			return false, ErrDedupBusy
+		}
+	}
+	s.keys[key] = dedupEntry{expiresAt: time.Now().Add(s.processingTTL)}
+	return true, nil
+}
+
+func (s *memoryDedupStore) Complete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = dedupEntry{done: true}
+	return nil
+}
+
+func (s *memoryDedupStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+	return nil
+}
+
+// countingHandler считает вызовы; пока fail > 0, возвращает ошибку
+type countingHandler struct {
+	mu    sync.Mutex
+	calls int
+	fail  int
+}
+
+func (h *countingHandler) HandleEvent(context.Context, TaskEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls++
+	if h.fail > 0 {
+		h.fail--
+		return errors.New("temporary failure")
+	}
+	return nil
+}
+
+func needsHelpEvent() TaskEvent {
+	return TaskEvent{
+		EventID:   uuid.NewString(),
+		Type:      EventTaskStatusChanged,
+		TaskID:    uuid.NewString(),
+		UserID:    uuid.NewString(),
+		Status:    "NEEDS_HELP",
+		Reason:    "blocked",
+		Timestamp: time.Now(),
+	}
+}
+
+func TestDedupHandlerSkipsRedeliveredEvent(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingHandler{}
+	handler := NewDedupHandler(newMemoryDedupStore(), "routing", inner, nil)
+
+	event := needsHelpEvent()
+	for i := 0; i < 3; i++ {
+		if err := handler.HandleEvent(ctx, event); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+	if inner.calls != 1 {
+		t.Fatalf("handler called %d times, want 1", inner.calls)
+	}
+
+	if err := handler.HandleEvent(ctx, needsHelpEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 2 {
+		t.Fatalf("new event: handler called %d times, want 2", inner.calls)
+	}
+}
+
+func TestDedupHandlerRetriesFailedEvent(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingHandler{fail: 1}
+	handler := NewDedupHandler(newMemoryDedupStore(), "routing", inner, nil)
+
+	event := needsHelpEvent()
+	if err := handler.HandleEvent(ctx, event); err == nil {
+		t.Fatal("first delivery: want error")
+	}
+	// Ошибка снимает отметку: повторная доставка обрабатывается
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	// После успеха следующий повтор пропускается
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("second redelivery: %v", err)
+	}
+	if inner.calls != 2 {
+		t.Fatalf("handler called %d times, want 2", inner.calls)
+	}
+}
+
+func TestDedupHandlerWithoutEventID(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingHandler{}
+	handler := NewDedupHandler(newMemoryDedupStore(), "routing", inner, nil)
+
+	event := needsHelpEvent()
+	event.EventID = ""
+	for i := 0; i < 2; i++ {
+		if err := handler.HandleEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inner.calls != 2 {
+		t.Fatalf("handler called %d times, want 2", inner.calls)
+	}
+}
+
+func TestDedupHandlerConcurrentRedelivery(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingHandler{}
+	handler := NewDedupHandler(newMemoryDedupStore(), "routing", inner, nil)
+
+	event := needsHelpEvent()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := handler.HandleEvent(ctx, event); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if inner.calls != 1 {
+		t.Fatalf("handler called %d times, want 1", inner.calls)
+	}
+}
+
+// Экземпляр упал, не закончив обработку: повтор обрабатывает событие после истечения отметки
+func TestDedupHandlerRecoversAfterCrash(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryDedupStore()
+	store.processingTTL = 100 * time.Millisecond
+	inner := &countingHandler{}
+	handler := NewDedupHandler(store, "routing", inner, nil)
+
+	event := needsHelpEvent()
+	if claimed, err := store.Claim(ctx, "routing:"+event.EventID); !claimed || err != nil {
+		t.Fatalf("claim = %v, %v", claimed, err)
+	}
+
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("handler called %d times, want 1", inner.calls)
+	}
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("after completion handler called %d times, want 1", inner.calls)
+	}
+}
+
+// Повтор, пришедший во время обработки, ждёт её и пропускает событие, если она удалась
+func TestDedupHandlerWaitsForProcessing(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryDedupStore()
+	inner := &countingHandler{}
+	handler := NewDedupHandler(store, "routing", inner, nil)
+
+	event := needsHelpEvent()
+	key := "routing:" + event.EventID
+	if claimed, err := store.Claim(ctx, key); !claimed || err != nil {
+		t.Fatalf("claim = %v, %v", claimed, err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		store.Complete(ctx, key)
+	}()
+
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 0 {
+		t.Fatalf("handler called %d times, want 0", inner.calls)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	other := needsHelpEvent()
+	store.Claim(ctx, "routing:"+other.EventID)
+	if err := handler.HandleEvent(cancelled, other); !errors.Is(err, context.Canceled) {
+		t.Fatalf("HandleEvent with cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+// При повторе после ошибки одного обработчика fanout другой событие не обрабатывает снова
+func TestDedupHandlerPerHandlerKeys(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryDedupStore()
+	routing := &countingHandler{}
+	webhook := &countingHandler{fail: 1}
+	handler := NewFanoutHandler(
+		NewDedupHandler(store, "routing", routing, nil),
+		NewDedupHandler(store, "webhook", webhook, nil),
+	)
+
+	event := needsHelpEvent()
+	if err := handler.HandleEvent(ctx, event); err == nil {
+		t.Fatal("first delivery: want error")
+	}
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	if routing.calls != 1 || webhook.calls != 2 {
+		t.Fatalf("routing called %d times, webhook %d; want 1 and 2", routing.calls, webhook.calls)
+	}
+}
+
+// Повторно доставленное NEEDS_HELP событие не создаёт второе уведомление менеджеру
+func TestRedeliveredNeedsHelpNotifiesManagerOnce(t *testing.T) {
+	ctx := context.Background()
+	event := needsHelpEvent()
+	managerID := uuid.NewString()
+
+	inbox := &recordingNotifier{}
+	router := NewEventHandler(RoutingConfig{
+		Rules: &staticRules{rules: []Rule{{
+			ID:               uuid.New(),
+			Name:             "NEEDS_HELP",
+			EventType:        EventTaskStatusChanged,
+			Status:           "NEEDS_HELP",
+			Recipient:        RecipientManager,
+			ManagerLevel:     1,
+			NotificationType: "task_needs_help",
+			Enabled:          true,
+		}}},
+		Preferences: defaultPreferences{},
+		Deferred:    noDeferred{},
+		Resolver:    staticManager(managerID),
+		Templates:   mustTemplates(t),
+		Channels:    map[string]Notifier{ChannelInbox: inbox},
+	})
+	handler := NewDedupHandler(newMemoryDedupStore(), "routing", router, nil)
+
+	for i := 0; i < 2; i++ {
+		if err := handler.HandleEvent(ctx, event); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+	if len(inbox.sent) != 1 {
+		t.Fatalf("manager got %d notifications, want 1", len(inbox.sent))
+	}
+	if got := inbox.sent[0]; got.RecipientID != managerID || got.TaskID != event.TaskID {
+		t.Fatalf("unexpected notification %+v", got)
+	}
+}
+
+// Ошибка одного канала повторяет обработку, но уведомление в ящике не дублируется:
+// его ID одинаков во всех попытках
+func TestRedeliveryAfterPartialFailureKeepsOneInboxItem(t *testing.T) {
+	ctx := context.Background()
+	event := needsHelpEvent()
+	managerID := uuid.NewString()
+
+	inbox := newMemoryInbox()
+	email := &failingNotifier{fail: 1}
+	router := NewEventHandler(RoutingConfig{
+		Rules: &staticRules{rules: []Rule{{
+			ID:               uuid.New(),
+			Name:             "NEEDS_HELP",
+			EventType:        EventTaskStatusChanged,
+			Status:           "NEEDS_HELP",
+			Recipient:        RecipientManager,
+			ManagerLevel:     1,
+			NotificationType: "task_needs_help",
+			Enabled:          true,
+		}}},
+		Preferences: defaultPreferences{},
+		Deferred:    noDeferred{},
+		Resolver:    staticManager(managerID),
+		Templates:   mustTemplates(t),
+		Channels:    map[string]Notifier{ChannelInbox: inbox, ChannelEmail: email},
+	})
+	handler := NewDedupHandler(newMemoryDedupStore(), "routing", router, nil)
+
+	if err := handler.HandleEvent(ctx, event); err == nil {
+		t.Fatal("first delivery: want email error")
+	}
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	if len(inbox.items) != 1 {
+		t.Fatalf("inbox has %d notifications, want 1", len(inbox.items))
+	}
+	if len(email.attempts) != 2 || email.attempts[0].ID != email.attempts[1].ID {
+		t.Fatalf("email attempts %+v, want two with the same notification ID", email.attempts)
+	}
+}
+
+func mustTemplates(t *testing.T) *Templates {
+	t.Helper()
+	templates, err := NewTemplates("ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return templates
+}
+
+type recordingNotifier struct {
+	sent []Notification
+}
+
+func (n *recordingNotifier) SendNotification(_ context.Context, notification Notification) error {
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+// memoryInbox сохраняет уведомление с уже известным ID один раз, как ящик в Postgres
+type memoryInbox struct {
+	items map[string]Notification
+}
+
+func newMemoryInbox() *memoryInbox {
+	return &memoryInbox{items: make(map[string]Notification)}
+}
+
+func (n *memoryInbox) SendNotification(_ context.Context, notification Notification) error {
+	if _, ok := n.items[notification.ID]; !ok {
+		n.items[notification.ID] = notification
+	}
+	return nil
+}
+
+// failingNotifier запоминает попытки; пока fail > 0, возвращает ошибку
+type failingNotifier struct {
+	attempts []Notification
+	fail     int
+}
+
+func (n *failingNotifier) SendNotification(_ context.Context, notification Notification) error {
+	n.attempts = append(n.attempts, notification)
+	if n.fail > 0 {
+		n.fail--
+		return errors.New("smtp unavailable")
+	}
+	return nil
+}
+
+type staticManager string
+
+func (m staticManager) ResolveManager(context.Context, string) (string, error) {
+	return string(m), nil
+}
+
+type defaultPreferences struct{}
+
+func (defaultPreferences) Get(_ context.Context, userID uuid.UUID) (Preferences, error) {
+	return DefaultPreferences(userID), nil
+}
+
+func (defaultPreferences) Save(context.Context, *Preferences) error { return nil }
+
+type noDeferred struct{}
+
+func (noDeferred) Create(context.Context, []DeferredNotification) error { return nil }
+
+func (noDeferred) ClaimDue(context.Context, time.Time, int) ([]DeferredNotification, error) {
+	return nil, nil
+}
+
+// staticRules - RuleRepository с неизменным набором правил и без отложенных срабатываний
+type staticRules struct {
+	rules []Rule
+}
+
+func (r *staticRules) List(context.Context) ([]Rule, error)        { return r.rules, nil }
+func (r *staticRules) ListEnabled(context.Context) ([]Rule, error) { return r.rules, nil }
+func (r *staticRules) Get(context.Context, uuid.UUID) (Rule, error) {
+	return Rule{}, ErrRuleNotFound
+}
+func (r *staticRules) Create(context.Context, *Rule) error                { return nil }
+func (r *staticRules) Update(context.Context, *Rule) error                { return nil }
+func (r *staticRules) Delete(context.Context, uuid.UUID) error            { return nil }
+func (r *staticRules) ScheduleTimer(context.Context, *RuleTimer) error    { return nil }
+func (r *staticRules) CancelTimers(context.Context, string, string) error { return nil }
+func (r *staticRules) ClaimDueTimers(context.Context, time.Time, int) ([]RuleTimer, error) {
+	return nil, nil
+}
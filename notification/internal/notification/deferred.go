@@ -0,0 +1,105 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeferredNotification - уведомление, отложенное до конца тихих часов или до сводки
+type DeferredNotification struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	RecipientID    uuid.UUID `gorm:"type:uuid;not null"`
+	Channel        string    `gorm:"type:text;not null"`
+	NotificationID uuid.UUID `gorm:"type:uuid;not null"`
+	Type           string    `gorm:"type:text;not null"`
+	TaskID         string    `gorm:"type:text;not null;default:''"`
+	UserID         string    `gorm:"type:text;not null;default:''"`
+	Reason         string    `gorm:"type:text;not null;default:''"`
+	Message        string    `gorm:"type:text;not null"`
+	CreatedAt      time.Time `gorm:"not null"`
+	DeliverAfter   time.Time `gorm:"not null"`
+}
+
+func (DeferredNotification) TableName() string {
+	return "deferred_notifications"
+}
+
+func newDeferredNotification(notification Notification, recipientID uuid.UUID, channel string, deliverAfter time.Time) DeferredNotification {
+	// Повторная обработка события откладывает уведомление в канал один раз
+	id, err := uuid.Parse(notification.ID)
+	deferredID := derivedID(notification.ID, channel)
+	if err != nil {
+		id, deferredID = uuid.New(), uuid.New()
+	}
+	return DeferredNotification{
+		ID:             deferredID,
+		RecipientID:    recipientID,
+		Channel:        channel,
+		NotificationID: id,
+		Type:           notification.Type,
+		TaskID:         notification.TaskID,
+		UserID:         notification.UserID,
+		Reason:         notification.Reason,
+		Message:        notification.Message,
+		CreatedAt:      notification.CreatedAt,
+		DeliverAfter:   deliverAfter,
+	}
+}
+
+func (d DeferredNotification) notification() Notification {
+	return Notification{
+		ID:          d.NotificationID.String(),
+		Type:        d.Type,
+		TaskID:      d.TaskID,
+		UserID:      d.UserID,
+		Reason:      d.Reason,
+		Message:     d.Message,
+		RecipientID: d.RecipientID.String(),
+		CreatedAt:   d.CreatedAt,
+	}
+}
+
+type DeferredRepository interface {
+	Create(ctx context.Context, items []DeferredNotification) error
+	// ClaimDue удаляет и возвращает до limit уведомлений, время которых пришло
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]DeferredNotification, error)
+}
+
+type deferredRepository struct {
+	db *gorm.DB
+}
+
+func NewDeferredRepository(db *gorm.DB) DeferredRepository {
+	return &deferredRepository{db: db}
+}
+
+func (r *deferredRepository) Create(ctx context.Context, items []DeferredNotification) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+func (r *deferredRepository) ClaimDue(ctx context.Context, now time.Time, limit int) ([]DeferredNotification, error) {
+	var items []DeferredNotification
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("deliver_after <= ?", now).
+			Order("created_at, id").
+			Limit(limit).
+			Find(&items).Error
+		if err != nil || len(items) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		return tx.Where("id IN ?", ids).Delete(&DeferredNotification{}).Error
+	})
+	return items, err
+}
@@ -0,0 +1,187 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Статусы задач, как в task/internal/task/orm.go
+const (
+	TaskStatusInProgress = "IN_PROGRESS"
+	TaskStatusCompleted  = "COMPLETED"
+	TaskStatusNeedsHelp  = "NEEDS_HELP"
+)
+
+// ManagerDigestType - ежедневная сводка менеджеру по задачам подчинённых (Notification.Digest)
+const ManagerDigestType = "manager_digest"
+
+// TaskSnapshot - последнее известное состояние задачи по событиям из Kafka.
+// CreatedAt - время создания задачи; для задач, созданных до появления сводок, - время первого события.
+type TaskSnapshot struct {
+	TaskID          string     `gorm:"type:text;primary_key"`
+	UserID          string     `gorm:"type:text;not null"`
+	CreatedBy       string     `gorm:"type:text;not null;default:''"`
+	Status          string     `gorm:"type:text;not null"`
+	Reason          string     `gorm:"type:text;not null;default:''"`
+	CreatedAt       time.Time  `gorm:"not null"`
+	StatusChangedAt time.Time  `gorm:"not null"`
+	CompletedAt     *time.Time `gorm:"type:timestamptz"`
+}
+
+func (TaskSnapshot) TableName() string {
+	return "task_snapshots"
+}
+
+// SchedulerRun - последний запуск задания по расписанию
+type SchedulerRun struct {
+	Name      string    `gorm:"type:text;primary_key"`
+	LastRunAt time.Time `gorm:"not null"`
+}
+
+func (SchedulerRun) TableName() string {
+	return "scheduler_runs"
+}
+
+// ManagerDigest - задачи подчинённых менеджера для сводки за период (Since, Until]
+type ManagerDigest struct {
+	Since     time.Time
+	Until     time.Time
+	NeedsHelp []TaskSnapshot
+	Overdue   []TaskSnapshot
+	Completed []TaskSnapshot
+}
+
+func (d *ManagerDigest) empty() bool {
+	return len(d.NeedsHelp) == 0 && len(d.Overdue) == 0 && len(d.Completed) == 0
+}
+
+type DigestRepository interface {
+	// Apply обновляет состояние задачи по событию; более старое событие состояние не меняет
+	Apply(ctx context.Context, snapshot *TaskSnapshot) error
+	Get(ctx context.Context, taskID string) (TaskSnapshot, error)
+	// ListForDigest возвращает задачи в NEEDS_HELP, незавершённые задачи, созданные до overdueBefore,
+	// и задачи, завершённые после completedSince
+	ListForDigest(ctx context.Context, overdueBefore, completedSince time.Time) ([]TaskSnapshot, error)
+	// PurgeCompleted удаляет задачи, завершённые до before: в сводки они больше не попадут
+	PurgeCompleted(ctx context.Context, before time.Time) error
+	// ClaimRun отмечает запуск задания name в at и возвращает предыдущий запуск (нулевое время - первый).
+	// false - запуск в at уже отмечен другим экземпляром сервиса.
+	ClaimRun(ctx context.Context, name string, at time.Time) (time.Time, bool, error)
+	// ReleaseRun возвращает отметку запуска в at к previous, чтобы запуск повторился;
+	// отметку более позднего запуска не меняет
+	ReleaseRun(ctx context.Context, name string, at, previous time.Time) error
+}
+
+type digestRepository struct {
+	db *gorm.DB
+}
+
+func NewDigestRepository(db *gorm.DB) DigestRepository {
+	return &digestRepository{db: db}
+}
+
+func (r *digestRepository) Apply(ctx context.Context, snapshot *TaskSnapshot) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "created_by", "status", "reason", "status_changed_at", "completed_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "task_snapshots.status_changed_at <= excluded.status_changed_at"},
+		}},
+	}).Create(snapshot).Error
+}
+
+func (r *digestRepository) Get(ctx context.Context, taskID string) (TaskSnapshot, error) {
+	var snapshot TaskSnapshot
+	err := r.db.WithContext(ctx).Where("task_id = ?", taskID).Take(&snapshot).Error
+	return snapshot, err
+}
+
+func (r *digestRepository) ListForDigest(ctx context.Context, overdueBefore, completedSince time.Time) ([]TaskSnapshot, error) {
+	var snapshots []TaskSnapshot
+	err := r.db.WithContext(ctx).
+		Where("status = ?", TaskStatusNeedsHelp).
+		Or("status <> ? AND created_at <= ?", TaskStatusCompleted, overdueBefore).
+		Or("status = ? AND completed_at > ?", TaskStatusCompleted, completedSince).
+		Order("created_at, task_id").
+		Find(&snapshots).Error
+	return snapshots, err
+}
+
+func (r *digestRepository) PurgeCompleted(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("status = ? AND completed_at < ?", TaskStatusCompleted, before).
+		Delete(&TaskSnapshot{}).Error
+}
+
+func (r *digestRepository) ClaimRun(ctx context.Context, name string, at time.Time) (time.Time, bool, error) {
+	var previous time.Time
+	claimed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var run SchedulerRun
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", name).Take(&run).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&SchedulerRun{Name: name, LastRunAt: at})
+			claimed = result.RowsAffected == 1
+			return result.Error
+		}
+		if err != nil || !run.LastRunAt.Before(at) {
+			return err
+		}
+		previous, claimed = run.LastRunAt, true
+		return tx.Model(&SchedulerRun{}).Where("name = ?", name).Update("last_run_at", at).Error
+	})
+	return previous, claimed && err == nil, err
+}
+
+func (r *digestRepository) ReleaseRun(ctx context.Context, name string, at, previous time.Time) error {
+	query := r.db.WithContext(ctx).Where("name = ? AND last_run_at = ?", name, at)
+	if previous.IsZero() {
+		return query.Delete(&SchedulerRun{}).Error
+	}
+	return query.Model(&SchedulerRun{}).Update("last_run_at", previous).Error
+}
+
+// snapshotHandler сохраняет состояние задач для сводок и эскалации
+type snapshotHandler struct {
+	repo DigestRepository
+}
+
+func NewTaskSnapshotHandler(repo DigestRepository) EventHandler {
+	return &snapshotHandler{repo: repo}
+}
+
+func (h *snapshotHandler) HandleEvent(ctx context.Context, event TaskEvent) error {
+	if strings.TrimSpace(event.TaskID) == "" {
+		return ErrEmptyTaskID
+	}
+	if strings.TrimSpace(event.UserID) == "" {
+		return ErrEmptyUserID
+	}
+
+	occurredAt := event.Timestamp
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+	snapshot := TaskSnapshot{
+		TaskID:          event.TaskID,
+		UserID:          event.UserID,
+		CreatedBy:       event.CreatedBy,
+		Status:          strings.ToUpper(event.Status),
+		Reason:          event.Reason,
+		CreatedAt:       occurredAt,
+		StatusChangedAt: occurredAt,
+	}
+	if snapshot.Status == TaskStatusCompleted {
+		snapshot.CompletedAt = &occurredAt
+	}
+	if err := h.repo.Apply(ctx, &snapshot); err != nil {
+		return fmt.Errorf("save task snapshot: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Режимы шифрования соединения с SMTP сервером
+const (
+	SMTPSecurityNone     = "none"     // без TLS (локальный SMTP sink)
+	SMTPSecurityStartTLS = "starttls" // STARTTLS обязателен
+	SMTPSecurityTLS      = "tls"      // TLS с момента подключения (обычно порт 465)
+)
+
+const defaultSMTPTimeout = 10 * time.Second
+
+// ContactResolver возвращает email и имя пользователя; пустой email - адреса нет
+type ContactResolver interface {
+	ResolveContact(ctx context.Context, userID string) (email, name string, err error)
+}
+
+// SMTPConfig - параметры подключения к SMTP серверу
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	Security string
+	Timeout  time.Duration
+}
+
+type smtpNotifier struct {
+	config    SMTPConfig
+	from      *mail.Address
+	contacts  ContactResolver
+	templates *Templates
+}
+
+// NewSMTPNotifier создаёт notifier, отправляющий письмо получателю уведомления.
+// Адрес получателя запрашивается у contacts.
+func NewSMTPNotifier(config SMTPConfig, contacts ContactResolver, templates *Templates) (Notifier, error) {
+	if config.Host == "" {
+		return nil, errors.New("smtp host is required")
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp from address: %w", err)
+	}
+	switch config.Security {
+	case "":
+		config.Security = SMTPSecurityStartTLS
+	case SMTPSecurityNone, SMTPSecurityStartTLS, SMTPSecurityTLS:
+	default:
+		return nil, fmt.Errorf("unknown smtp security mode %q", config.Security)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultSMTPTimeout
+	}
+	return &smtpNotifier{config: config, from: from, contacts: contacts, templates: templates}, nil
+}
+
+func (n *smtpNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	email, name, err := n.contacts.ResolveContact(ctx, notification.RecipientID)
+	if err != nil {
+		return fmt.Errorf("resolve recipient email: %w", err)
+	}
+	if email == "" {
+		log.Printf("no email for user %s, skip email notification", notification.RecipientID)
+		return nil
+	}
+	to, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("invalid recipient email %q: %w", email, err)
+	}
+	to.Name = name
+
+	rendered, err := n.templates.Render(TemplateData{Notification: notification, RecipientName: name})
+	if err != nil {
+		return fmt.Errorf("render email: %w", err)
+	}
+	message, err := buildMessage(n.from, to, rendered, time.Now())
+	if err != nil {
+		return err
+	}
+	return n.send(ctx, to.Address, message)
+}
+
+// send доставляет письмо через отдельное SMTP соединение
+func (n *smtpNotifier) send(ctx context.Context, to string, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, n.config.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(n.config.Host, n.config.Port)
+	tlsConfig := &tls.Config{ServerName: n.config.Host}
+	dialer := &net.Dialer{}
+
+	var conn net.Conn
+	var err error
+	if n.config.Security == SMTPSecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if n.config.Security == SMTPSecurityStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if n.config.Username != "" {
+		// PlainAuth сам откажется передавать пароль по незашифрованному соединению
+		if err := client.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := client.Mail(n.from.Address); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage собирает MIME письмо: text/plain и, если есть, text/html в multipart/alternative
+func buildMessage(from, to *mail.Address, rendered Rendered, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", rendered.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	if rendered.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, rendered.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", rendered.Text},
+		{"text/html; charset=utf-8", rendered.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(writer, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	// Письмо передаётся с CRLF переводами строк
+	if _, err := qp.Write([]byte(strings.ReplaceAll(content, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	random := make([]byte, 12)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain)
+}
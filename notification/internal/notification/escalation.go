@@ -0,0 +1,170 @@
+package notification
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EscalatedType - уведомление менеджеру выше по цепочке о задаче без решения
+const EscalatedType = "task_escalated"
+
+const (
+	defaultEscalationTimeout   = 4 * time.Hour
+	defaultEscalationMaxLevels = 3
+)
+
+// EscalationConfig - какие уведомления эскалируются, если получатель не прочитал их в ящике за Timeout.
+// Пустой Types - эскалация выключена. MaxLevels - на сколько уровней менеджеров поднимается уведомление.
+type EscalationConfig struct {
+	Types     []string
+	Timeout   time.Duration
+	MaxLevels int
+}
+
+func (c EscalationConfig) withDefaults() EscalationConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultEscalationTimeout
+	}
+	if c.MaxLevels <= 0 {
+		c.MaxLevels = defaultEscalationMaxLevels
+	}
+	return c
+}
+
+// Alert - уведомление в ящике, которое эскалируется, если его не прочитали к EscalateAt.
+// ID совпадает с ID уведомления в ящике.
+type Alert struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key"`
+	RecipientID uuid.UUID `gorm:"type:uuid;not null"`
+	Type        string    `gorm:"type:text;not null"`
+	TaskID      string    `gorm:"type:text;not null;default:''"`
+	UserID      string    `gorm:"type:text;not null;default:''"`
+	Status      string    `gorm:"type:text;not null;default:''"`
+	Reason      string    `gorm:"type:text;not null;default:''"`
+	Escalation  int       `gorm:"not null;default:0"`
+	CreatedAt   time.Time `gorm:"not null"`
+	EscalateAt  time.Time `gorm:"not null"`
+}
+
+func (Alert) TableName() string {
+	return "notification_alerts"
+}
+
+type AlertRepository interface {
+	Create(ctx context.Context, alert *Alert) error
+	// ClaimDue удаляет до limit уведомлений, время эскалации которых пришло, и возвращает
+	// непрочитанные из них и число удалённых
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]Alert, int, error)
+}
+
+type alertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository(db *gorm.DB) AlertRepository {
+	return &alertRepository{db: db}
+}
+
+func (r *alertRepository) Create(ctx context.Context, alert *Alert) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"escalate_at"}),
+	}).Create(alert).Error
+}
+
+func (r *alertRepository) ClaimDue(ctx context.Context, now time.Time, limit int) ([]Alert, int, error) {
+	var alerts []Alert
+	var unread []Alert
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("escalate_at <= ?", now).
+			Order("escalate_at, id").
+			Limit(limit).
+			Find(&alerts).Error
+		if err != nil || len(alerts) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, 0, len(alerts))
+		for _, alert := range alerts {
+			ids = append(ids, alert.ID)
+		}
+		var read []uuid.UUID
+		err = tx.Model(&InboxItem{}).
+			Where("id IN ? AND read_at IS NOT NULL", ids).
+			Pluck("id", &read).Error
+		if err != nil {
+			return err
+		}
+		for _, alert := range alerts {
+			if !slices.Contains(read, alert.ID) {
+				unread = append(unread, alert)
+			}
+		}
+		return tx.Where("id IN ?", ids).Delete(&Alert{}).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return unread, len(alerts), nil
+}
+
+// alertTracker запоминает уведомления, доставленные в ящик, для эскалации
+type alertTracker struct {
+	next   Notifier
+	alerts AlertRepository
+	config EscalationConfig
+	logger *log.Logger
+}
+
+// NewAlertTracker оборачивает notifier ящика: уведомления типов config.Types после доставки
+// ставятся на эскалацию. Ошибка постановки только пишется в лог - уведомление уже доставлено.
+func NewAlertTracker(next Notifier, alerts AlertRepository, config EscalationConfig, logger *log.Logger) Notifier {
+	if len(config.Types) == 0 {
+		return next
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &alertTracker{next: next, alerts: alerts, config: config.withDefaults(), logger: logger}
+}
+
+func (t *alertTracker) SendNotification(ctx context.Context, notification Notification) error {
+	if err := t.next.SendNotification(ctx, notification); err != nil {
+		return err
+	}
+	if !slices.Contains(t.config.Types, notification.Type) {
+		return nil
+	}
+	id, err := uuid.Parse(notification.ID)
+	if err != nil {
+		return nil
+	}
+	recipientID, err := uuid.Parse(notification.RecipientID)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	err = t.alerts.Create(ctx, &Alert{
+		ID:          id,
+		RecipientID: recipientID,
+		Type:        notification.Type,
+		TaskID:      notification.TaskID,
+		UserID:      notification.UserID,
+		Status:      notification.Status,
+		Reason:      notification.Reason,
+		Escalation:  notification.Escalation,
+		CreatedAt:   now,
+		EscalateAt:  now.Add(t.config.Timeout),
+	})
+	if err != nil {
+		t.logger.Printf("track notification %s for escalation: %v", notification.ID, err)
+	}
+	return nil
+}
@@ -1,49 +1,105 @@
 package notification
 
 import (
-	"fmt"
 	"strings"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий задач и документов. Совпадают с task/internal/task/kafka.go
+// и document/internal/document/events.go
+const (
+	EventTaskCreated          = "task.created"
+	EventTaskStatusChanged    = "task.status_changed"
+	EventDocumentCreated      = "document.created"
+	EventDocumentVersionAdded = "document.version_added"
+	EventDocumentDeleted      = "document.deleted"
+	EventDocumentRestored     = "document.restored"
+	// EventNotificationCreated - уведомление пользователю, который выбрал канал webhook
+	EventNotificationCreated = "notification.created"
 )
 
 // TaskEvent представляет событие изменения задачи из Kafka.
-// Структура должна совпадать с task/internal/task/kafka.go
+// Структура должна совпадать с task/internal/task/kafka.go.
+// EventID пуст у событий, опубликованных до его появления.
 type TaskEvent struct {
-	TaskID    string    `json:"taskId"`
-	UserID    string    `json:"userId"`
-	Status    string    `json:"status"`
-	Reason    string    `json:"reason,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
+	EventID        string    `json:"eventId,omitempty"`
+	Type           string    `json:"type"`
+	TaskID         string    `json:"taskId"`
+	UserID         string    `json:"userId"`
+	CreatedBy      string    `json:"createdBy,omitempty"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previousStatus,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// EventType возвращает тип события; старые сообщения без type - смена статуса
+func (e TaskEvent) EventType() string {
+	if e.Type == "" {
+		return EventTaskStatusChanged
+	}
+	return e.Type
+}
+
+// DocumentEvent представляет событие документа из Kafka.
+// Структура должна совпадать с document/internal/document/events.go
+type DocumentEvent struct {
+	Type        string    `json:"type"`
+	DocumentID  string    `json:"documentId"`
+	TaskID      string    `json:"taskId"`
+	OwnerID     string    `json:"ownerId"`
+	ActorID     string    `json:"actorId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Version     int       `json:"version"`
+	Checksum    string    `json:"checksum,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
 }
 
 // Notification описывает уведомление, которое будет отправлено.
+// Message заполняется по шаблону типа уведомления (см. Templates).
+// ID совпадает с ID уведомления в ящике получателя.
 type Notification struct {
+	ID          string
 	Type        string
 	TaskID      string
 	UserID      string
+	Status      string
+	Reason      string
 	Message     string
 	RecipientID string
 	CreatedAt   time.Time
+	// UnresolvedHours - сколько часов задача в статусе правила (для отложенных правил)
+	UnresolvedHours int
+	// Items - уведомления, собранные в сводку (тип DigestType)
+	Items []Notification
+	// Digest - задачи подчинённых для сводки менеджеру (тип ManagerDigestType)
+	Digest *ManagerDigest
+	// Escalation - сколько раз уведомление поднималось по цепочке менеджеров
+	Escalation int
 }
 
-// NeedsAttention возвращает true, если событие требует уведомления администратора.
-func (e TaskEvent) NeedsAttention() bool {
-	return strings.EqualFold(e.Status, "NEEDS_HELP")
-}
-
-// NewNotificationFromEvent создаёт Notification из TaskEvent.
-func NewNotificationFromEvent(event TaskEvent) Notification {
-	message := fmt.Sprintf(
-		"Задача %s требует помощи. Причина: %s",
-		event.TaskID,
-		event.Reason,
-	)
-
+// NewNotificationFromEvent создаёт Notification типа notificationType из TaskEvent.
+func NewNotificationFromEvent(event TaskEvent, notificationType string) Notification {
 	return Notification{
-		Type:      "task_needs_help",
+		ID:        uuid.NewString(),
+		Type:      notificationType,
 		TaskID:    event.TaskID,
 		UserID:    event.UserID,
-		Message:   message,
+		Status:    event.Status,
+		Reason:    event.Reason,
 		CreatedAt: time.Now(),
 	}
 }
+
+// derivedNamespace - пространство имён UUID, выводимых из ID событий
+var derivedNamespace = uuid.MustParse("7d3c9f5e-2a41-4b8e-9c06-5e1f8a2b4d73")
+
+// derivedID возвращает UUID, одинаковый при каждой обработке одного и того же источника:
+// повтор события не создаёт второе уведомление или таймер
+func derivedID(parts ...string) uuid.UUID {
+	return uuid.NewSHA1(derivedNamespace, []byte(strings.Join(parts, ":")))
+}
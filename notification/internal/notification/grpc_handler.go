@@ -0,0 +1,500 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	pb "github.com/Oniqq60/task_system_control/gen/proto/notification"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errUnauthorized      = errors.New("unauthorized")
+	tokenBlacklistPrefix = "auth:token:blacklist:"
+)
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleEmployee Role = "employee"
+)
+
+// Requester - пользователь из JWT
+type Requester struct {
+	UserID string
+	Role   Role
+}
+
+// GrpcHandler реализует NotificationService. Пользователь всегда берётся из JWT,
+// поэтому чужие уведомления недоступны.
+type GrpcHandler struct {
+	pb.UnimplementedNotificationServiceServer
+	inbox       Inbox
+	webhooks    Webhooks
+	preferences PreferenceService
+	rules       Rules
+	auth        Authorizer
+}
+
+type authClaims struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Authorizer проверяет JWT и возвращает пользователя
+type Authorizer interface {
+	Authorize(ctx context.Context, token string) (Requester, error)
+}
+
+func NewGrpcHandler(inbox Inbox, webhooks Webhooks, preferences PreferenceService, rules Rules, auth Authorizer) *GrpcHandler {
+	return &GrpcHandler{
+		inbox:       inbox,
+		webhooks:    webhooks,
+		preferences: preferences,
+		rules:       rules,
+		auth:        auth,
+	}
+}
+
+func (h *GrpcHandler) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items, next, err := h.inbox.List(ctx, requester.UserID, req.GetUnreadOnly(), int(req.GetLimit()), req.GetBeforeId())
+	if err != nil {
+		return nil, handleInboxErr(err)
+	}
+
+	notifications := make([]*pb.Notification, 0, len(items))
+	for _, item := range items {
+		notifications = append(notifications, mapInboxItem(item))
+	}
+	return &pb.ListResponse{
+		Notifications: notifications,
+		NextBeforeId:  next,
+	}, nil
+}
+
+func (h *GrpcHandler) MarkRead(ctx context.Context, req *pb.MarkReadRequest) (*pb.MarkReadResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.GetIds()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "ids are required")
+	}
+
+	updated, err := h.inbox.MarkRead(ctx, requester.UserID, req.GetIds())
+	if err != nil {
+		return nil, handleInboxErr(err)
+	}
+	return &pb.MarkReadResponse{Updated: updated}, nil
+}
+
+func (h *GrpcHandler) MarkAllRead(ctx context.Context, req *pb.MarkAllReadRequest) (*pb.MarkReadResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := h.inbox.MarkAllRead(ctx, requester.UserID)
+	if err != nil {
+		return nil, handleInboxErr(err)
+	}
+	return &pb.MarkReadResponse{Updated: updated}, nil
+}
+
+func (h *GrpcHandler) UnreadCount(ctx context.Context, req *pb.UnreadCountRequest) (*pb.UnreadCountResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := h.inbox.UnreadCount(ctx, requester.UserID)
+	if err != nil {
+		return nil, handleInboxErr(err)
+	}
+	return &pb.UnreadCountResponse{Count: count}, nil
+}
+
+func (h *GrpcHandler) CreateWebhook(ctx context.Context, req *pb.CreateWebhookRequest) (*pb.Webhook, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := h.webhooks.Create(ctx, CreateWebhookInput{
+		URL:         req.GetUrl(),
+		EventTypes:  req.GetEventTypes(),
+		Secret:      req.GetSecret(),
+		Description: req.GetDescription(),
+	}, requester)
+	if err != nil {
+		return nil, handleWebhookErr(err)
+	}
+	// Секрет возвращается один раз - при создании
+	resp := mapWebhook(webhook)
+	resp.Secret = webhook.Secret
+	return resp, nil
+}
+
+func (h *GrpcHandler) ListWebhooks(ctx context.Context, req *pb.ListWebhooksRequest) (*pb.ListWebhooksResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := h.webhooks.List(ctx, requester)
+	if err != nil {
+		return nil, handleWebhookErr(err)
+	}
+	resp := &pb.ListWebhooksResponse{Webhooks: make([]*pb.Webhook, 0, len(webhooks))}
+	for _, webhook := range webhooks {
+		resp.Webhooks = append(resp.Webhooks, mapWebhook(webhook))
+	}
+	return resp, nil
+}
+
+func (h *GrpcHandler) DeleteWebhook(ctx context.Context, req *pb.DeleteWebhookRequest) (*pb.DeleteWebhookResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.webhooks.Delete(ctx, req.GetId(), requester); err != nil {
+		return nil, handleWebhookErr(err)
+	}
+	return &pb.DeleteWebhookResponse{}, nil
+}
+
+func (h *GrpcHandler) ListWebhookDeliveries(ctx context.Context, req *pb.ListWebhookDeliveriesRequest) (*pb.ListWebhookDeliveriesResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, next, err := h.webhooks.Deliveries(ctx, req.GetWebhookId(), req.GetStatus(), int(req.GetLimit()), req.GetBeforeId(), requester)
+	if err != nil {
+		return nil, handleWebhookErr(err)
+	}
+	resp := &pb.ListWebhookDeliveriesResponse{
+		Deliveries:   make([]*pb.WebhookDelivery, 0, len(deliveries)),
+		NextBeforeId: next,
+	}
+	for _, delivery := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, mapWebhookDelivery(delivery))
+	}
+	return resp, nil
+}
+
+func (h *GrpcHandler) ReplayWebhookDeliveries(ctx context.Context, req *pb.ReplayWebhookDeliveriesRequest) (*pb.ReplayWebhookDeliveriesResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed, err := h.webhooks.Replay(ctx, req.GetWebhookId(), req.GetDeliveryIds(), requester)
+	if err != nil {
+		return nil, handleWebhookErr(err)
+	}
+	return &pb.ReplayWebhookDeliveriesResponse{Replayed: replayed}, nil
+}
+
+func (h *GrpcHandler) GetPreferences(ctx context.Context, req *pb.GetPreferencesRequest) (*pb.Preferences, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences, err := h.preferences.Get(ctx, requester)
+	if err != nil {
+		return nil, handleRoutingErr(err)
+	}
+	return mapPreferences(preferences), nil
+}
+
+func (h *GrpcHandler) UpdatePreferences(ctx context.Context, req *pb.UpdatePreferencesRequest) (*pb.Preferences, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	in := req.GetPreferences()
+	preferences, err := h.preferences.Update(ctx, Preferences{
+		NotificationTypes: in.GetNotificationTypes(),
+		Channels:          in.GetChannels(),
+		QuietHoursStart:   in.GetQuietHoursStart(),
+		QuietHoursEnd:     in.GetQuietHoursEnd(),
+		Timezone:          in.GetTimezone(),
+		Digest:            in.GetDigest(),
+	}, requester)
+	if err != nil {
+		return nil, handleRoutingErr(err)
+	}
+	return mapPreferences(preferences), nil
+}
+
+func (h *GrpcHandler) ListRules(ctx context.Context, req *pb.ListRulesRequest) (*pb.ListRulesResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := h.rules.List(ctx, requester)
+	if err != nil {
+		return nil, handleRoutingErr(err)
+	}
+	resp := &pb.ListRulesResponse{Rules: make([]*pb.Rule, 0, len(rules))}
+	for _, rule := range rules {
+		resp.Rules = append(resp.Rules, mapRule(rule))
+	}
+	return resp, nil
+}
+
+func (h *GrpcHandler) CreateRule(ctx context.Context, req *pb.CreateRuleRequest) (*pb.Rule, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := h.rules.Create(ctx, ruleFromProto(req.GetRule()), requester)
+	if err != nil {
+		return nil, handleRoutingErr(err)
+	}
+	return mapRule(rule), nil
+}
+
+func (h *GrpcHandler) UpdateRule(ctx context.Context, req *pb.UpdateRuleRequest) (*pb.Rule, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := ruleFromProto(req.GetRule())
+	id, err := uuid.Parse(req.GetRule().GetId())
+	if err != nil {
+		return nil, handleRoutingErr(ErrRuleNotFound)
+	}
+	rule.ID = id
+	rule, err = h.rules.Update(ctx, rule, requester)
+	if err != nil {
+		return nil, handleRoutingErr(err)
+	}
+	return mapRule(rule), nil
+}
+
+func (h *GrpcHandler) DeleteRule(ctx context.Context, req *pb.DeleteRuleRequest) (*pb.DeleteRuleResponse, error) {
+	requester, err := h.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.rules.Delete(ctx, req.GetId(), requester); err != nil {
+		return nil, handleRoutingErr(err)
+	}
+	return &pb.DeleteRuleResponse{}, nil
+}
+
+func NewAuthorizer(jwtSecret []byte, redis *redis.Client) Authorizer {
+	return &metadataAuthorizer{
+		jwtSecret: jwtSecret,
+		redis:     redis,
+	}
+}
+
+type metadataAuthorizer struct {
+	jwtSecret []byte
+	redis     *redis.Client
+}
+
+func (a *metadataAuthorizer) Authorize(ctx context.Context, token string) (Requester, error) {
+	claims := &authClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return a.jwtSecret, nil
+	})
+	if err != nil || !parsed.Valid {
+		return Requester{}, errUnauthorized
+	}
+
+	if claims.ID == "" || claims.UserID == "" {
+		return Requester{}, errUnauthorized
+	}
+
+	if a.redis != nil {
+		key := tokenBlacklistPrefix + claims.ID
+		exists, redisErr := a.redis.Exists(ctx, key).Result()
+		if redisErr != nil {
+			return Requester{}, redisErr
+		}
+		if exists > 0 {
+			return Requester{}, errUnauthorized
+		}
+	}
+
+	return Requester{
+		UserID: claims.UserID,
+		Role:   Role(strings.ToLower(claims.Role)),
+	}, nil
+}
+
+func (h *GrpcHandler) authorize(ctx context.Context) (Requester, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Requester{}, status.Error(codes.Unauthenticated, errUnauthorized.Error())
+	}
+
+	tokens := md.Get("authorization")
+	if len(tokens) == 0 {
+		return Requester{}, status.Error(codes.Unauthenticated, errUnauthorized.Error())
+	}
+
+	token := strings.TrimPrefix(tokens[0], "Bearer ")
+	requester, err := h.auth.Authorize(ctx, token)
+	if errors.Is(err, errUnauthorized) {
+		return Requester{}, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return Requester{}, status.Error(codes.Unavailable, "token check failed")
+	}
+	return requester, nil
+}
+
+func mapInboxItem(item InboxItem) *pb.Notification {
+	notification := &pb.Notification{
+		Id:        item.ID.String(),
+		Type:      item.Type,
+		TaskId:    item.TaskID,
+		UserId:    item.UserID,
+		Message:   item.Message,
+		CreatedAt: item.CreatedAt.Unix(),
+	}
+	if item.ReadAt != nil {
+		notification.ReadAt = item.ReadAt.Unix()
+	}
+	return notification
+}
+
+func handleInboxErr(err error) error {
+	switch {
+	case errors.Is(err, ErrCursorNotFound):
+		return status.Error(codes.InvalidArgument, "unknown before_id")
+	case errors.Is(err, ErrInvalidRecipient):
+		return status.Error(codes.Unauthenticated, "token has no valid user id")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func mapWebhook(webhook Webhook) *pb.Webhook {
+	return &pb.Webhook{
+		Id:          webhook.ID.String(),
+		Url:         webhook.URL,
+		EventTypes:  webhook.EventTypes,
+		Description: webhook.Description,
+		CreatedBy:   webhook.CreatedBy.String(),
+		CreatedAt:   webhook.CreatedAt.Unix(),
+	}
+}
+
+func mapWebhookDelivery(delivery WebhookDelivery) *pb.WebhookDelivery {
+	resp := &pb.WebhookDelivery{
+		Id:             delivery.ID.String(),
+		WebhookId:      delivery.WebhookID.String(),
+		EventId:        delivery.EventID.String(),
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       int32(delivery.Attempts),
+		ResponseStatus: int32(delivery.ResponseStatus),
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt.Unix(),
+		Payload:        delivery.Payload,
+	}
+	if delivery.Status == DeliveryPending {
+		resp.NextAttemptAt = delivery.NextAttemptAt.Unix()
+	}
+	if delivery.LastAttemptAt != nil {
+		resp.LastAttemptAt = delivery.LastAttemptAt.Unix()
+	}
+	if delivery.DeliveredAt != nil {
+		resp.DeliveredAt = delivery.DeliveredAt.Unix()
+	}
+	return resp
+}
+
+func handleWebhookErr(err error) error {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrWebhookNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrInvalidWebhookURL), errors.Is(err, ErrInvalidEventTypes), errors.Is(err, ErrInvalidDeliveryStatus):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return handleInboxErr(err)
+	}
+}
+
+func mapPreferences(preferences Preferences) *pb.Preferences {
+	return &pb.Preferences{
+		NotificationTypes: preferences.NotificationTypes,
+		Channels:          preferences.Channels,
+		QuietHoursStart:   preferences.QuietHoursStart,
+		QuietHoursEnd:     preferences.QuietHoursEnd,
+		Timezone:          preferences.Timezone,
+		Digest:            preferences.Digest,
+	}
+}
+
+func ruleFromProto(rule *pb.Rule) Rule {
+	return Rule{
+		Name:             rule.GetName(),
+		EventType:        rule.GetEventType(),
+		Status:           rule.GetStatus(),
+		Recipient:        rule.GetRecipient(),
+		ManagerLevel:     int(rule.GetManagerLevel()),
+		DelaySeconds:     rule.GetDelaySeconds(),
+		NotificationType: rule.GetNotificationType(),
+		Enabled:          rule.GetEnabled(),
+	}
+}
+
+func mapRule(rule Rule) *pb.Rule {
+	return &pb.Rule{
+		Id:               rule.ID.String(),
+		Name:             rule.Name,
+		EventType:        rule.EventType,
+		Status:           rule.Status,
+		Recipient:        rule.Recipient,
+		ManagerLevel:     int32(rule.ManagerLevel),
+		DelaySeconds:     rule.DelaySeconds,
+		NotificationType: rule.NotificationType,
+		Enabled:          rule.Enabled,
+		CreatedAt:        rule.CreatedAt.Unix(),
+	}
+}
+
+func handleRoutingErr(err error) error {
+	switch {
+	case errors.Is(err, ErrRuleNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrInvalidRule), errors.Is(err, ErrInvalidPreferences):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return handleWebhookErr(err)
+	}
+}
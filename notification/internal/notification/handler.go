@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// Событие без задачи или пользователя не обработается и при повторе
 var (
-	ErrEmptyUserID = errors.New("userID is required")
-	ErrEmptyTaskID = errors.New("taskID is required")
+	ErrEmptyUserID = fmt.Errorf("%w: userID is required", ErrMalformedEvent)
+	ErrEmptyTaskID = fmt.Errorf("%w: taskID is required", ErrMalformedEvent)
 )
 
 // ManagerResolver возвращает ID менеджера/админа для сотрудника.
@@ -18,16 +22,55 @@ type ManagerResolver interface {
 	ResolveManager(ctx context.Context, userID string) (string, error)
 }
 
+const (
+	defaultRoutingPollInterval = 30 * time.Second
+	// routingBatchSize - сколько отложенных срабатываний и уведомлений выбирается за раз
+	routingBatchSize = 100
+	// routingRetryDelay - через сколько повторить срабатывание или отправку после ошибки
+	routingRetryDelay = time.Minute
+	// deliveryKeyPrefix - префикс ключей DedupStore для доставок в каналы
+	deliveryKeyPrefix = "delivery:"
+)
+
+// RoutingConfig - зависимости обработчика правил
+type RoutingConfig struct {
+	Rules       RuleRepository
+	Preferences PreferenceRepository
+	Deferred    DeferredRepository
+	Resolver    ManagerResolver
+	Templates   *Templates
+	// Channels - notifier каналов ChannelInbox, ChannelEmail, ChannelWebhook; канал без notifier пропускается
+	Channels map[string]Notifier
+	// Deliveries помнит каналы, в которые уведомление уже доставлено: повтор обработки
+	// после ошибки одного канала не отправит его в остальные снова. nil - не помнить.
+	Deliveries   DedupStore
+	PollInterval time.Duration
+	Logger       *log.Logger
+}
+
+// RoutingHandler выбирает получателей уведомлений по правилам маршрутизации и доставляет их
+// по настройкам получателя. Run срабатывает отложенные правила и отправляет отложенные уведомления.
+// SendNotification доставляет готовое уведомление (сводки, эскалации) по настройкам получателя.
+type RoutingHandler interface {
+	EventHandler
+	Notifier
+	Run(ctx context.Context) error
+}
+
 type eventHandler struct {
-	notifier Notifier
-	resolver ManagerResolver
+	config RoutingConfig
+	logger *log.Logger
 }
 
-func NewEventHandler(notifier Notifier, resolver ManagerResolver) EventHandler {
-	return &eventHandler{
-		notifier: notifier,
-		resolver: resolver,
+func NewEventHandler(config RoutingConfig) RoutingHandler {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultRoutingPollInterval
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = log.Default()
 	}
+	return &eventHandler{config: config, logger: logger}
 }
 
 func (h *eventHandler) HandleEvent(ctx context.Context, event TaskEvent) error {
@@ -37,25 +80,339 @@ func (h *eventHandler) HandleEvent(ctx context.Context, event TaskEvent) error {
 	if strings.TrimSpace(event.UserID) == "" {
 		return ErrEmptyUserID
 	}
-	if !event.NeedsAttention() {
+
+	// Задача вышла из статуса - отложенные правила для прежнего статуса больше не срабатывают
+	if event.EventType() == EventTaskStatusChanged {
+		if err := h.config.Rules.CancelTimers(ctx, event.TaskID, strings.ToUpper(event.Status)); err != nil {
+			return fmt.Errorf("cancel rule timers: %w", err)
+		}
+	}
+
+	rules, err := h.config.Rules.ListEnabled(ctx)
+	if err != nil {
+		return fmt.Errorf("list notification rules: %w", err)
+	}
+
+	// ID уведомлений и таймеров выводятся из ID события, чтобы повтор не создал дубликаты
+	source := ""
+	if event.EventID != "" {
+		source = "event:" + event.EventID
+	}
+	var errs []error
+	for _, rule := range rules {
+		if !rule.Matches(event) {
+			continue
+		}
+		if rule.DelaySeconds > 0 {
+			if err := h.schedule(ctx, rule, event, source); err != nil {
+				errs = append(errs, fmt.Errorf("schedule rule %q: %w", rule.Name, err))
+			}
+			continue
+		}
+		if err := h.fire(ctx, rule, event, source); err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: %w", rule.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *eventHandler) schedule(ctx context.Context, rule Rule, event TaskEvent, source string) error {
+	occurredAt := event.Timestamp
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+	id := uuid.New()
+	if source != "" {
+		id = derivedID(source, rule.ID.String())
+	}
+	return h.config.Rules.ScheduleTimer(ctx, &RuleTimer{
+		ID:        id,
+		RuleID:    rule.ID,
+		TaskID:    event.TaskID,
+		UserID:    event.UserID,
+		CreatedBy: event.CreatedBy,
+		Status:    rule.Status,
+		Reason:    event.Reason,
+		FireAt:    occurredAt.Add(rule.Delay()),
+		CreatedAt: occurredAt,
+	})
+}
+
+// fire отправляет уведомление правила получателю. source - событие или таймер, из которого
+// выводится ID уведомления; пустой - уведомление получает случайный ID.
+func (h *eventHandler) fire(ctx context.Context, rule Rule, event TaskEvent, source string) error {
+	recipientID, err := h.recipient(ctx, rule, event)
+	if err != nil {
+		return err
+	}
+	if recipientID == "" {
+		h.logger.Printf("no %s recipient for task %s (rule %q), skip notification", rule.Recipient, event.TaskID, rule.Name)
 		return nil
 	}
 
-	managerID, err := h.resolver.ResolveManager(ctx, event.UserID)
+	notification := NewNotificationFromEvent(event, rule.NotificationType)
+	if source != "" {
+		notification.ID = derivedID(source, rule.ID.String()).String()
+	}
+	notification.RecipientID = recipientID
+	if rule.DelaySeconds > 0 && !event.Timestamp.IsZero() {
+		notification.UnresolvedHours = int(time.Since(event.Timestamp).Hours())
+	}
+	notification.Message, err = h.config.Templates.Message(notification)
+	if err != nil {
+		return fmt.Errorf("render notification: %w", err)
+	}
+	return h.deliver(ctx, notification)
+}
+
+// recipient возвращает получателя правила; для manager поднимается по цепочке на ManagerLevel уровней
+func (h *eventHandler) recipient(ctx context.Context, rule Rule, event TaskEvent) (string, error) {
+	switch rule.Recipient {
+	case RecipientWorker:
+		return event.UserID, nil
+	case RecipientCreator:
+		return event.CreatedBy, nil
+	}
+
+	userID := event.UserID
+	for level := 0; level < rule.ManagerLevel; level++ {
+		managerID, err := h.config.Resolver.ResolveManager(ctx, userID)
+		if err != nil {
+			return "", fmt.Errorf("resolve manager: %w", err)
+		}
+		// Цепочка кончилась раньше нужного уровня
+		if managerID == "" || managerID == userID {
+			return "", nil
+		}
+		userID = managerID
+	}
+	return userID, nil
+}
+
+func (h *eventHandler) SendNotification(ctx context.Context, notification Notification) error {
+	return h.deliver(ctx, notification)
+}
+
+// deliver отправляет уведомление в каналы, выбранные получателем. В тихие часы и при
+// сводке письма и webhook откладываются (см. Preferences.DeliverAfter).
+func (h *eventHandler) deliver(ctx context.Context, notification Notification) error {
+	recipientID, err := uuid.Parse(notification.RecipientID)
+	if err != nil {
+		return ErrInvalidRecipient
+	}
+	preferences, err := h.config.Preferences.Get(ctx, recipientID)
 	if err != nil {
-		return fmt.Errorf("resolve manager: %w", err)
+		return fmt.Errorf("get notification preferences: %w", err)
 	}
-	if managerID == "" {
-		log.Printf("manager not found for user %s, skip notification", event.UserID)
+	if !preferences.Wants(notification.Type) {
 		return nil
 	}
 
-	notification := NewNotificationFromEvent(event)
-	notification.RecipientID = managerID
+	now := time.Now()
+	var deferred []DeferredNotification
+	var errs []error
+	for _, channel := range []string{ChannelInbox, ChannelEmail, ChannelWebhook} {
+		if h.config.Channels[channel] == nil || !preferences.Uses(channel) {
+			continue
+		}
+		if after, ok := preferences.DeliverAfter(channel, now); ok {
+			deferred = append(deferred, newDeferredNotification(notification, recipientID, channel, after))
+			continue
+		}
+		if err := h.send(ctx, channel, notification); err != nil {
+			errs = append(errs, fmt.Errorf("send %s notification: %w", channel, err))
+		}
+	}
+	if err := h.config.Deferred.Create(ctx, deferred); err != nil {
+		errs = append(errs, fmt.Errorf("defer notification: %w", err))
+	}
+	return errors.Join(errs...)
+}
 
-	if err := h.notifier.SendNotification(ctx, notification); err != nil {
-		return fmt.Errorf("send notification: %w", err)
+// send отправляет уведомление в канал, если оно ещё не доставлено туда
+func (h *eventHandler) send(ctx context.Context, channel string, notification Notification) error {
+	notifier := h.config.Channels[channel]
+	if h.config.Deliveries == nil || notification.ID == "" {
+		return notifier.SendNotification(ctx, notification)
+	}
+	key := deliveryKeyPrefix + notification.ID + ":" + channel
+	claimed, err := h.config.Deliveries.Claim(ctx, key)
+	if err != nil {
+		return fmt.Errorf("claim delivery: %w", err)
 	}
+	if !claimed {
+		return nil
+	}
+	if err := notifier.SendNotification(ctx, notification); err != nil {
+		if releaseErr := h.config.Deliveries.Release(context.WithoutCancel(ctx), key); releaseErr != nil {
+			h.logger.Printf("release delivery %s: %v", key, releaseErr)
+		}
+		return err
+	}
+	if err := h.config.Deliveries.Complete(context.WithoutCancel(ctx), key); err != nil {
+		h.logger.Printf("complete delivery %s: %v", key, err)
+	}
+	return nil
+}
+
+func (h *eventHandler) Run(ctx context.Context) error {
+	h.logger.Printf("notification rules scheduler started (interval=%s)", h.config.PollInterval)
+	ticker := time.NewTicker(h.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := h.runDue(ctx); err != nil && ctx.Err() == nil {
+			h.logger.Printf("notification rules scheduler: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *eventHandler) runDue(ctx context.Context) error {
+	for {
+		claimed, err := h.fireDueTimers(ctx)
+		if err != nil || claimed < routingBatchSize {
+			break
+		}
+	}
+	for {
+		claimed, err := h.flushDeferred(ctx)
+		if err != nil {
+			return err
+		}
+		if claimed < routingBatchSize {
+			return nil
+		}
+	}
+}
 
+// fireDueTimers срабатывает отложенные правила, время которых пришло. Таймер, который
+// не удалось отработать, переносится на routingRetryDelay.
+func (h *eventHandler) fireDueTimers(ctx context.Context) (int, error) {
+	now := time.Now()
+	timers, err := h.config.Rules.ClaimDueTimers(ctx, now, routingBatchSize)
+	if err != nil || len(timers) == 0 {
+		return 0, err
+	}
+	rules, err := h.config.Rules.ListEnabled(ctx)
+	if err != nil {
+		h.retryTimers(ctx, timers, now)
+		return 0, fmt.Errorf("list notification rules: %w", err)
+	}
+	byID := make(map[uuid.UUID]Rule, len(rules))
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+
+	for _, timer := range timers {
+		rule, ok := byID[timer.RuleID]
+		if !ok {
+			// Правило выключили, пока таймер ждал
+			continue
+		}
+		if err := h.fire(ctx, rule, timer.event(), "timer:"+timer.ID.String()); err != nil {
+			h.logger.Printf("rule %q for task %s: %v", rule.Name, timer.TaskID, err)
+			h.retryTimers(ctx, []RuleTimer{timer}, now)
+		}
+	}
+	return len(timers), nil
+}
+
+func (h *eventHandler) retryTimers(ctx context.Context, timers []RuleTimer, now time.Time) {
+	for _, timer := range timers {
+		timer.FireAt = now.Add(routingRetryDelay)
+		if err := h.config.Rules.ScheduleTimer(ctx, &timer); err != nil {
+			h.logger.Printf("reschedule rule timer for task %s: %v", timer.TaskID, err)
+		}
+	}
+}
+
+// flushDeferred отправляет отложенные уведомления. Несколько писем одному получателю
+// уходят одной сводкой.
+func (h *eventHandler) flushDeferred(ctx context.Context) (int, error) {
+	now := time.Now()
+	items, err := h.config.Deferred.ClaimDue(ctx, now, routingBatchSize)
+	if err != nil || len(items) == 0 {
+		return 0, err
+	}
+
+	type groupKey struct {
+		recipientID uuid.UUID
+		channel     string
+	}
+	var order []groupKey
+	groups := make(map[groupKey][]DeferredNotification)
+	for _, item := range items {
+		key := groupKey{recipientID: item.RecipientID, channel: item.Channel}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], item)
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		if err := h.sendDeferred(ctx, key.channel, group, now); err != nil {
+			h.logger.Printf("send deferred %s notifications to %s: %v", key.channel, key.recipientID, err)
+			for i := range group {
+				group[i].ID = uuid.New()
+				group[i].DeliverAfter = now.Add(routingRetryDelay)
+			}
+			if err := h.config.Deferred.Create(ctx, group); err != nil {
+				h.logger.Printf("reschedule deferred notifications: %v", err)
+			}
+		}
+	}
+	return len(items), nil
+}
+
+func (h *eventHandler) sendDeferred(ctx context.Context, channel string, items []DeferredNotification, now time.Time) error {
+	notifier := h.config.Channels[channel]
+	if notifier == nil {
+		return nil
+	}
+	if channel == ChannelEmail && len(items) > 1 {
+		digest := Notification{
+			ID:          uuid.NewString(),
+			Type:        DigestType,
+			RecipientID: items[0].RecipientID.String(),
+			CreatedAt:   now,
+		}
+		for _, item := range items {
+			digest.Items = append(digest.Items, item.notification())
+		}
+		var err error
+		if digest.Message, err = h.config.Templates.Message(digest); err != nil {
+			return fmt.Errorf("render digest: %w", err)
+		}
+		return notifier.SendNotification(ctx, digest)
+	}
+	for _, item := range items {
+		if err := h.send(ctx, channel, item.notification()); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// fanoutHandler передаёт событие всем обработчикам; ошибка одного не останавливает остальных
+type fanoutHandler struct {
+	handlers []EventHandler
+}
+
+func NewFanoutHandler(handlers ...EventHandler) EventHandler {
+	return &fanoutHandler{handlers: handlers}
+}
+
+func (h *fanoutHandler) HandleEvent(ctx context.Context, event TaskEvent) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if err := handler.HandleEvent(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// countingNotifier считает отправленные уведомления
+type countingNotifier struct {
+	sent int
+}
+
+func (n *countingNotifier) SendNotification(context.Context, Notification) error {
+	n.sent++
+	return nil
+}
+
+func needsHelpRouter(t *testing.T, managerID string, channels map[string]Notifier, deliveries DedupStore) RoutingHandler {
+	t.Helper()
+	return NewEventHandler(RoutingConfig{
+		Rules: &staticRules{rules: []Rule{{
+			ID:               uuid.New(),
+			Name:             "NEEDS_HELP",
+			EventType:        EventTaskStatusChanged,
+			Status:           "NEEDS_HELP",
+			Recipient:        RecipientManager,
+			ManagerLevel:     1,
+			NotificationType: "task_needs_help",
+			Enabled:          true,
+		}}},
+		Preferences: defaultPreferences{},
+		Deferred:    noDeferred{},
+		Resolver:    staticManager(managerID),
+		Templates:   mustTemplates(t),
+		Channels:    channels,
+		Deliveries:  deliveries,
+	})
+}
+
+// Повтор после ошибки письма не отправляет уведомление в ящик снова
+func TestRetryDeliversOnlyFailedChannels(t *testing.T) {
+	ctx := context.Background()
+	inbox := &countingNotifier{}
+	email := &failingNotifier{fail: 1}
+	router := needsHelpRouter(t, uuid.NewString(), map[string]Notifier{
+		ChannelInbox: inbox,
+		ChannelEmail: email,
+	}, newMemoryDedupStore())
+	handler := NewDedupHandler(newMemoryDedupStore(), "routing", router, nil)
+
+	event := needsHelpEvent()
+	if err := handler.HandleEvent(ctx, event); err == nil {
+		t.Fatal("first delivery: want email error")
+	}
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	if err := handler.HandleEvent(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if inbox.sent != 1 || len(email.attempts) != 2 {
+		t.Fatalf("inbox got %d notifications, email %d attempts; want 1 and 2", inbox.sent, len(email.attempts))
+	}
+}
+
+// multiNotifier не отправляет уведомление дальше после ошибки: повтор отправит его во все
+func TestMultiNotifierStopsAtFirstError(t *testing.T) {
+	ctx := context.Background()
+	first := &failingNotifier{fail: 1}
+	second := &countingNotifier{}
+	notifier := NewMultiNotifier(first, second)
+
+	if err := notifier.SendNotification(ctx, Notification{ID: uuid.NewString()}); err == nil {
+		t.Fatal("want error of the first notifier")
+	}
+	if second.sent != 0 {
+		t.Fatalf("second notifier called %d times after an error, want 0", second.sent)
+	}
+	if err := notifier.SendNotification(ctx, Notification{ID: uuid.NewString()}); err != nil {
+		t.Fatal(err)
+	}
+	if second.sent != 1 {
+		t.Fatalf("second notifier called %d times, want 1", second.sent)
+	}
+}
+
+func TestRuleRejectsSchedulerTypes(t *testing.T) {
+	templates := mustTemplates(t)
+	for _, notificationType := range []string{ManagerDigestType, EscalatedType} {
+		rule := Rule{
+			Name:             "escalate",
+			EventType:        EventTaskStatusChanged,
+			Status:           "NEEDS_HELP",
+			Recipient:        RecipientManager,
+			ManagerLevel:     2,
+			DelaySeconds:     86400,
+			NotificationType: notificationType,
+		}
+		if err := rule.normalize(templates); !errors.Is(err, ErrInvalidRule) {
+			t.Fatalf("rule with type %s: normalize = %v, want ErrInvalidRule", notificationType, err)
+		}
+	}
+}
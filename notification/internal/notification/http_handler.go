@@ -0,0 +1,187 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// readinessTimeout - сколько ждать одну проверку готовности
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck проверяет зависимость сервиса; ошибка - сервис не готов
+type ReadinessCheck func(ctx context.Context) error
+
+// HTTPConfig - зависимости служебного HTTP API
+type HTTPConfig struct {
+	Consumers []Consumer
+	// Checks - проверки готовности по имени зависимости
+	Checks map[string]ReadinessCheck
+	Auth   Authorizer
+	Logger *log.Logger
+}
+
+type httpHandler struct {
+	config HTTPConfig
+	logger *log.Logger
+}
+
+// NewHTTPHandler возвращает служебное HTTP API сервиса:
+//
+//	GET  /healthz                  - процесс жив
+//	GET  /readyz                   - все проверки готовности прошли, иначе 503
+//	GET  /consumers                - партиции, offset, отставание и последняя ошибка consumer
+//	POST /admin/consumers/pause    - приостановить чтение (админ; ?topic= - один топик)
+//	POST /admin/consumers/resume   - возобновить чтение
+func NewHTTPHandler(config HTTPConfig) http.Handler {
+	logger := config.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	h := &httpHandler{config: config, logger: logger}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", h.healthz)
+	mux.HandleFunc("GET /readyz", h.readyz)
+	mux.HandleFunc("GET /consumers", h.consumers)
+	mux.HandleFunc("POST /admin/consumers/pause", h.pause)
+	mux.HandleFunc("POST /admin/consumers/resume", h.resume)
+	return mux
+}
+
+func (h *httpHandler) healthz(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz выполняет проверки параллельно, каждую не дольше readinessTimeout
+func (h *httpHandler) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]string, len(h.config.Checks))
+	ready := true
+	for name, check := range h.config.Checks {
+		wg.Add(1)
+		go func(name string, check ReadinessCheck) {
+			defer wg.Done()
+			result := "ok"
+			err := check(ctx)
+			if err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if err != nil {
+				ready = false
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	if !ready {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "not ready", "checks": results})
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"status": "ready", "checks": results})
+}
+
+func (h *httpHandler) consumers(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, map[string]any{"consumers": statuses(h.config.Consumers)})
+}
+
+func (h *httpHandler) pause(w http.ResponseWriter, r *http.Request) {
+	h.control(w, r, Consumer.Pause)
+}
+
+func (h *httpHandler) resume(w http.ResponseWriter, r *http.Request) {
+	h.control(w, r, Consumer.Resume)
+}
+
+// control применяет action к consumer топика ?topic= или ко всем consumer
+func (h *httpHandler) control(w http.ResponseWriter, r *http.Request, action func(Consumer)) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	topic := r.URL.Query().Get("topic")
+	var selected []Consumer
+	for _, consumer := range h.config.Consumers {
+		if topic == "" || consumer.Status().Topic == topic {
+			selected = append(selected, consumer)
+		}
+	}
+	if len(selected) == 0 {
+		h.writeError(w, http.StatusNotFound, "consumer not found")
+		return
+	}
+	for _, consumer := range selected {
+		action(consumer)
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"consumers": statuses(selected)})
+}
+
+func (h *httpHandler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		h.writeError(w, http.StatusUnauthorized, errUnauthorized.Error())
+		return false
+	}
+	requester, err := h.config.Auth.Authorize(r.Context(), strings.TrimPrefix(header, "Bearer "))
+	if errors.Is(err, errUnauthorized) {
+		h.writeError(w, http.StatusUnauthorized, err.Error())
+		return false
+	}
+	if err != nil {
+		h.writeError(w, http.StatusServiceUnavailable, "token check failed")
+		return false
+	}
+	if requester.Role != RoleAdmin {
+		h.writeError(w, http.StatusForbidden, "admin role required")
+		return false
+	}
+	return true
+}
+
+func statuses(consumers []Consumer) []ConsumerStatus {
+	result := make([]ConsumerStatus, 0, len(consumers))
+	for _, consumer := range consumers {
+		result = append(result, consumer.Status())
+	}
+	return result
+}
+
+func (h *httpHandler) writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		h.logger.Printf("write json error: %v", err)
+	}
+}
+
+func (h *httpHandler) writeError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, map[string]string{"error": message})
+}
+
+// KafkaReachable проверяет, что доступен хотя бы один broker
+func KafkaReachable(brokers []string) ReadinessCheck {
+	return func(ctx context.Context) error {
+		err := errors.New("no kafka brokers")
+		for _, broker := range brokers {
+			var conn *kafka.Conn
+			if conn, err = kafka.DialContext(ctx, "tcp", broker); err == nil {
+				return conn.Close()
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,202 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultInboxPageSize = 50
+	maxInboxPageSize     = 200
+)
+
+var (
+	// ErrCursorNotFound - уведомление, с которого продолжается список, не найдено
+	ErrCursorNotFound = errors.New("notification cursor not found")
+	// ErrInvalidRecipient - у уведомления нет корректного получателя
+	ErrInvalidRecipient = errors.New("notification recipient is invalid")
+)
+
+// InboxItem - уведомление, сохранённое в ящике получателя
+type InboxItem struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	RecipientID uuid.UUID  `gorm:"type:uuid;not null"`
+	Type        string     `gorm:"type:text;not null"`
+	TaskID      string     `gorm:"type:text;not null;default:''"`
+	UserID      string     `gorm:"type:text;not null;default:''"`
+	Message     string     `gorm:"type:text;not null"`
+	CreatedAt   time.Time  `gorm:"not null;default:now()"`
+	ReadAt      *time.Time `gorm:"type:timestamptz"`
+}
+
+func (InboxItem) TableName() string {
+	return "notifications"
+}
+
+// InboxQuery - параметры выборки ящика. BeforeID - последнее уведомление предыдущей страницы.
+type InboxQuery struct {
+	UnreadOnly bool
+	Limit      int
+	BeforeID   *uuid.UUID
+}
+
+type Repository interface {
+	Create(ctx context.Context, item *InboxItem) error
+	List(ctx context.Context, recipientID uuid.UUID, query InboxQuery) ([]InboxItem, error)
+	MarkRead(ctx context.Context, recipientID uuid.UUID, ids []uuid.UUID, at time.Time) (int64, error)
+	MarkAllRead(ctx context.Context, recipientID uuid.UUID, at time.Time) (int64, error)
+	UnreadCount(ctx context.Context, recipientID uuid.UUID) (int64, error)
+}
+
+type inboxRepository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &inboxRepository{db: db}
+}
+
+// Create не создаёт уведомление, если уведомление с таким ID уже есть: повторная доставка
+// того же события сохраняет его один раз
+func (r *inboxRepository) Create(ctx context.Context, item *InboxItem) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error
+}
+
+// List возвращает уведомления получателя от новых к старым. Страницы продолжаются
+// по (created_at, id), поэтому уведомления с одинаковым временем не теряются.
+func (r *inboxRepository) List(ctx context.Context, recipientID uuid.UUID, query InboxQuery) ([]InboxItem, error) {
+	db := r.db.WithContext(ctx).Where("recipient_id = ?", recipientID)
+	if query.UnreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+	if query.BeforeID != nil {
+		var cursor InboxItem
+		err := r.db.WithContext(ctx).
+			Select("id", "created_at").
+			Where("id = ? AND recipient_id = ?", *query.BeforeID, recipientID).
+			Take(&cursor).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCursorNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	var items []InboxItem
+	err := db.Order("created_at DESC, id DESC").Limit(query.Limit).Find(&items).Error
+	return items, err
+}
+
+func (r *inboxRepository) MarkRead(ctx context.Context, recipientID uuid.UUID, ids []uuid.UUID, at time.Time) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).Model(&InboxItem{}).
+		Where("recipient_id = ? AND id IN ? AND read_at IS NULL", recipientID, ids).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (r *inboxRepository) MarkAllRead(ctx context.Context, recipientID uuid.UUID, at time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&InboxItem{}).
+		Where("recipient_id = ? AND read_at IS NULL", recipientID).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (r *inboxRepository) UnreadCount(ctx context.Context, recipientID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&InboxItem{}).
+		Where("recipient_id = ? AND read_at IS NULL", recipientID).
+		Count(&count).Error
+	return count, err
+}
+
+// Inbox - операции пользователя над своим ящиком уведомлений
+type Inbox interface {
+	List(ctx context.Context, recipientID string, unreadOnly bool, limit int, beforeID string) ([]InboxItem, string, error)
+	MarkRead(ctx context.Context, recipientID string, ids []string) (int64, error)
+	MarkAllRead(ctx context.Context, recipientID string) (int64, error)
+	UnreadCount(ctx context.Context, recipientID string) (int64, error)
+}
+
+type inbox struct {
+	repo Repository
+}
+
+func NewInbox(repo Repository) Inbox {
+	return &inbox{repo: repo}
+}
+
+// List возвращает страницу уведомлений и before_id следующей страницы (пусто, если страниц больше нет)
+func (i *inbox) List(ctx context.Context, recipientID string, unreadOnly bool, limit int, beforeID string) ([]InboxItem, string, error) {
+	recipient, err := uuid.Parse(recipientID)
+	if err != nil {
+		return nil, "", ErrInvalidRecipient
+	}
+	if limit <= 0 {
+		limit = defaultInboxPageSize
+	}
+	if limit > maxInboxPageSize {
+		limit = maxInboxPageSize
+	}
+
+	query := InboxQuery{UnreadOnly: unreadOnly, Limit: limit + 1}
+	if beforeID != "" {
+		cursor, err := uuid.Parse(beforeID)
+		if err != nil {
+			return nil, "", ErrCursorNotFound
+		}
+		query.BeforeID = &cursor
+	}
+
+	items, err := i.repo.List(ctx, recipient, query)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(items) <= limit {
+		return items, "", nil
+	}
+	items = items[:limit]
+	return items, items[limit-1].ID.String(), nil
+}
+
+func (i *inbox) MarkRead(ctx context.Context, recipientID string, ids []string) (int64, error) {
+	recipient, err := uuid.Parse(recipientID)
+	if err != nil {
+		return 0, ErrInvalidRecipient
+	}
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		value, err := uuid.Parse(id)
+		if err != nil {
+			// Чужие и несуществующие уведомления просто не отмечаются
+			continue
+		}
+		parsed = append(parsed, value)
+	}
+	return i.repo.MarkRead(ctx, recipient, parsed, time.Now())
+}
+
+func (i *inbox) MarkAllRead(ctx context.Context, recipientID string) (int64, error) {
+	recipient, err := uuid.Parse(recipientID)
+	if err != nil {
+		return 0, ErrInvalidRecipient
+	}
+	return i.repo.MarkAllRead(ctx, recipient, time.Now())
+}
+
+func (i *inbox) UnreadCount(ctx context.Context, recipientID string) (int64, error) {
+	recipient, err := uuid.Parse(recipientID)
+	if err != nil {
+		return 0, ErrInvalidRecipient
+	}
+	return i.repo.UnreadCount(ctx, recipient)
+}
@@ -2,9 +2,13 @@ package notification
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
 )
 
 // Notifier отвечает за доставку уведомлений
@@ -36,3 +40,103 @@ func (n *logNotifier) SendNotification(ctx context.Context, notification Notific
 	n.logger.Println(entry)
 	return nil
 }
+
+// storeNotifier сохраняет уведомление в ящик получателя
+type storeNotifier struct {
+	repo Repository
+}
+
+func NewStoreNotifier(repo Repository) Notifier {
+	return &storeNotifier{repo: repo}
+}
+
+func (n *storeNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	recipient, err := uuid.Parse(notification.RecipientID)
+	if err != nil {
+		return ErrInvalidRecipient
+	}
+	createdAt := notification.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	// Без ID уведомления ящик сгенерирует его сам
+	id, _ := uuid.Parse(notification.ID)
+	return n.repo.Create(ctx, &InboxItem{
+		ID:          id,
+		RecipientID: recipient,
+		Type:        notification.Type,
+		TaskID:      notification.TaskID,
+		UserID:      notification.UserID,
+		Message:     notification.Message,
+		CreatedAt:   createdAt,
+	})
+}
+
+// NotificationEvent - уведомление в Kafka для доставки подключённым клиентам (api_gateway /events)
+type NotificationEvent struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	TaskID      string    `json:"taskId"`
+	UserID      string    `json:"userId"`
+	RecipientID string    `json:"recipientId"`
+	Message     string    `json:"message"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func newNotificationEvent(notification Notification) NotificationEvent {
+	return NotificationEvent{
+		ID:          notification.ID,
+		Type:        notification.Type,
+		TaskID:      notification.TaskID,
+		UserID:      notification.UserID,
+		RecipientID: notification.RecipientID,
+		Message:     notification.Message,
+		CreatedAt:   notification.CreatedAt,
+	}
+}
+
+// kafkaNotifier публикует уведомление в Kafka; ключ сообщения - получатель
+type kafkaNotifier struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaNotifier возвращает notifier и функцию закрытия writer
+func NewKafkaNotifier(brokers []string, topic string) (Notifier, func() error) {
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Topic:    topic,
+		Balancer: &kafka.Hash{},
+	}
+	return &kafkaNotifier{writer: writer}, writer.Close
+}
+
+func (n *kafkaNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	value, err := json.Marshal(newNotificationEvent(notification))
+	if err != nil {
+		return err
+	}
+	return n.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(notification.RecipientID),
+		Value: value,
+	})
+}
+
+// multiNotifier отправляет уведомление notifier по очереди и останавливается на первой ошибке:
+// повтор отправит уведомление заново во все, поэтому каждый notifier должен принимать
+// уведомление с уже известным ID повторно (ящик не создаёт второе, Kafka публикует тот же id)
+type multiNotifier struct {
+	notifiers []Notifier
+}
+
+func NewMultiNotifier(notifiers ...Notifier) Notifier {
+	return &multiNotifier{notifiers: notifiers}
+}
+
+func (n *multiNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	for _, notifier := range n.notifiers {
+		if err := notifier.SendNotification(ctx, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	// Часовые пояса пользователей не зависят от tzdata в образе
+	_ "time/tzdata"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Каналы доставки уведомлений
+const (
+	ChannelInbox   = "inbox"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Частота писем со сводкой
+const (
+	DigestNone   = "none"
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+// dailyDigestHour - час (по времени пользователя), в который отправляется ежедневная сводка
+const dailyDigestHour = 9
+
+// ErrInvalidPreferences - настройки уведомлений заполнены неверно
+var ErrInvalidPreferences = errors.New("invalid notification preferences")
+
+// Preferences - настройки уведомлений пользователя. Пустой NotificationTypes - все типы.
+type Preferences struct {
+	UserID            uuid.UUID `gorm:"type:uuid;primary_key"`
+	NotificationTypes []string  `gorm:"type:jsonb;serializer:json;not null"`
+	Channels          []string  `gorm:"type:jsonb;serializer:json;not null"`
+	QuietHoursStart   string    `gorm:"type:text;not null;default:''"`
+	QuietHoursEnd     string    `gorm:"type:text;not null;default:''"`
+	Timezone          string    `gorm:"type:text;not null;default:''"`
+	Digest            string    `gorm:"type:text;not null;default:'none'"`
+	UpdatedAt         time.Time `gorm:"not null;default:now()"`
+}
+
+func (Preferences) TableName() string {
+	return "notification_preferences"
+}
+
+// DefaultPreferences - настройки пользователя, который их не менял
+func DefaultPreferences(userID uuid.UUID) Preferences {
+	return Preferences{
+		UserID:            userID,
+		NotificationTypes: []string{},
+		Channels:          []string{ChannelInbox, ChannelEmail},
+		Digest:            DigestNone,
+	}
+}
+
+// Wants возвращает true, если пользователь получает уведомления этого типа
+func (p Preferences) Wants(notificationType string) bool {
+	return len(p.NotificationTypes) == 0 || slices.Contains(p.NotificationTypes, notificationType)
+}
+
+// Uses возвращает true, если канал включён
+func (p Preferences) Uses(channel string) bool {
+	return slices.Contains(p.Channels, channel)
+}
+
+// DeliverAfter возвращает время, до которого уведомление в канал channel откладывается;
+// false - отправить сразу. Письма при включённой сводке копятся до следующей сводки,
+// письма и webhook в тихие часы ждут их окончания. Ящик не откладывается.
+func (p Preferences) DeliverAfter(channel string, now time.Time) (time.Time, bool) {
+	switch channel {
+	case ChannelEmail:
+		if next, ok := p.nextDigest(now); ok {
+			if end, quiet := p.quietUntil(next); quiet {
+				return end, true
+			}
+			return next, true
+		}
+		return p.quietUntil(now)
+	case ChannelWebhook:
+		return p.quietUntil(now)
+	default:
+		return time.Time{}, false
+	}
+}
+
+func (p Preferences) location() *time.Location {
+	if p.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// quietUntil возвращает конец тихих часов, если now попадает в них
+func (p Preferences) quietUntil(now time.Time) (time.Time, bool) {
+	start, okStart := parseClock(p.QuietHoursStart)
+	end, okEnd := parseClock(p.QuietHoursEnd)
+	if !okStart || !okEnd || start == end {
+		return time.Time{}, false
+	}
+
+	local := now.In(p.location())
+	minute := local.Hour()*60 + local.Minute()
+	var quiet bool
+	if start < end {
+		quiet = minute >= start && minute < end
+	} else {
+		// Тихие часы через полночь, например 22:00-07:00
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+// nextDigest возвращает время следующей сводки
+func (p Preferences) nextDigest(now time.Time) (time.Time, bool) {
+	local := now.In(p.location())
+	switch p.Digest {
+	case DigestHourly:
+		return time.Date(local.Year(), local.Month(), local.Day(), local.Hour()+1, 0, 0, 0, local.Location()), true
+	case DigestDaily:
+		next := time.Date(local.Year(), local.Month(), local.Day(), dailyDigestHour, 0, 0, 0, local.Location())
+		if !next.After(local) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// parseClock разбирает "HH:MM" в минуты от начала суток
+func parseClock(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return parsed.Hour()*60 + parsed.Minute(), true
+}
+
+// normalize проверяет настройки и убирает повторы; templates - известные типы уведомлений
+func (p *Preferences) normalize(templates *Templates) error {
+	types := make([]string, 0, len(p.NotificationTypes))
+	for _, notificationType := range p.NotificationTypes {
+		notificationType = strings.TrimSpace(notificationType)
+		if !templates.Has(notificationType) {
+			return fmt.Errorf("%w: unknown notification type %q", ErrInvalidPreferences, notificationType)
+		}
+		if !slices.Contains(types, notificationType) {
+			types = append(types, notificationType)
+		}
+	}
+	p.NotificationTypes = types
+
+	channels := make([]string, 0, len(p.Channels))
+	for _, channel := range p.Channels {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		switch channel {
+		case ChannelInbox, ChannelEmail, ChannelWebhook:
+		default:
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidPreferences, channel)
+		}
+		if !slices.Contains(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	p.Channels = channels
+
+	p.QuietHoursStart = strings.TrimSpace(p.QuietHoursStart)
+	p.QuietHoursEnd = strings.TrimSpace(p.QuietHoursEnd)
+	if (p.QuietHoursStart == "") != (p.QuietHoursEnd == "") {
+		return fmt.Errorf("%w: quiet hours need both start and end", ErrInvalidPreferences)
+	}
+	for _, value := range []string{p.QuietHoursStart, p.QuietHoursEnd} {
+		if _, ok := parseClock(value); value != "" && !ok {
+			return fmt.Errorf("%w: quiet hours must be HH:MM", ErrInvalidPreferences)
+		}
+	}
+
+	p.Timezone = strings.TrimSpace(p.Timezone)
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPreferences, p.Timezone)
+		}
+	}
+
+	switch p.Digest {
+	case "":
+		p.Digest = DigestNone
+	case DigestNone, DigestHourly, DigestDaily:
+	default:
+		return fmt.Errorf("%w: digest must be none, hourly or daily", ErrInvalidPreferences)
+	}
+	return nil
+}
+
+type PreferenceRepository interface {
+	// Get возвращает настройки пользователя; если он их не менял - настройки по умолчанию
+	Get(ctx context.Context, userID uuid.UUID) (Preferences, error)
+	Save(ctx context.Context, preferences *Preferences) error
+}
+
+type preferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewPreferenceRepository(db *gorm.DB) PreferenceRepository {
+	return &preferenceRepository{db: db}
+}
+
+func (r *preferenceRepository) Get(ctx context.Context, userID uuid.UUID) (Preferences, error) {
+	var preferences Preferences
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Take(&preferences).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultPreferences(userID), nil
+	}
+	return preferences, err
+}
+
+func (r *preferenceRepository) Save(ctx context.Context, preferences *Preferences) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, UpdateAll: true}).
+		Create(preferences).Error
+}
+
+// PreferenceService - настройки уведомлений текущего пользователя
+type PreferenceService interface {
+	Get(ctx context.Context, requester Requester) (Preferences, error)
+	Update(ctx context.Context, preferences Preferences, requester Requester) (Preferences, error)
+}
+
+type preferenceService struct {
+	repo      PreferenceRepository
+	templates *Templates
+}
+
+func NewPreferenceService(repo PreferenceRepository, templates *Templates) PreferenceService {
+	return &preferenceService{repo: repo, templates: templates}
+}
+
+func (s *preferenceService) Get(ctx context.Context, requester Requester) (Preferences, error) {
+	userID, err := uuid.Parse(requester.UserID)
+	if err != nil {
+		return Preferences{}, ErrInvalidRecipient
+	}
+	return s.repo.Get(ctx, userID)
+}
+
+func (s *preferenceService) Update(ctx context.Context, preferences Preferences, requester Requester) (Preferences, error) {
+	userID, err := uuid.Parse(requester.UserID)
+	if err != nil {
+		return Preferences{}, ErrInvalidRecipient
+	}
+	if err := preferences.normalize(s.templates); err != nil {
+		return Preferences{}, err
+	}
+	preferences.UserID = userID
+	preferences.UpdatedAt = time.Now()
+	if err := s.repo.Save(ctx, &preferences); err != nil {
+		return Preferences{}, err
+	}
+	return preferences, nil
+}
@@ -0,0 +1,321 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Получатели уведомления по правилу
+const (
+	RecipientWorker  = "worker"  // исполнитель задачи
+	RecipientCreator = "creator" // автор задачи
+	RecipientManager = "manager" // менеджер исполнителя уровня ManagerLevel
+)
+
+// maxManagerLevel ограничивает подъём по цепочке менеджеров
+const maxManagerLevel = 5
+
+var (
+	// ErrRuleNotFound - правило не найдено
+	ErrRuleNotFound = errors.New("notification rule not found")
+	// ErrInvalidRule - правило заполнено неверно
+	ErrInvalidRule = errors.New("invalid notification rule")
+)
+
+// Rule - правило маршрутизации. Срабатывает на событие EventType со статусом Status
+// (пусто - любой). Delay > 0 - уведомление отправляется, только если задача всё ещё
+// в статусе Status через Delay.
+type Rule struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Name             string     `gorm:"type:text;not null"`
+	EventType        string     `gorm:"type:text;not null"`
+	Status           string     `gorm:"type:text;not null;default:''"`
+	Recipient        string     `gorm:"type:text;not null"`
+	ManagerLevel     int        `gorm:"not null;default:1"`
+	DelaySeconds     int64      `gorm:"not null;default:0"`
+	NotificationType string     `gorm:"type:text;not null"`
+	Enabled          bool       `gorm:"not null;default:true"`
+	CreatedBy        *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt        time.Time  `gorm:"not null;default:now()"`
+}
+
+func (Rule) TableName() string {
+	return "notification_rules"
+}
+
+func (r Rule) Delay() time.Duration {
+	return time.Duration(r.DelaySeconds) * time.Second
+}
+
+// Matches возвращает true, если правило срабатывает на событие
+func (r Rule) Matches(event TaskEvent) bool {
+	if !r.Enabled || r.EventType != event.EventType() {
+		return false
+	}
+	return r.Status == "" || strings.EqualFold(r.Status, event.Status)
+}
+
+func (r *Rule) normalize(templates *Templates) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	switch r.EventType {
+	case EventTaskCreated, EventTaskStatusChanged:
+	default:
+		return fmt.Errorf("%w: event type must be %s or %s", ErrInvalidRule, EventTaskCreated, EventTaskStatusChanged)
+	}
+	r.Status = strings.ToUpper(strings.TrimSpace(r.Status))
+	switch r.Recipient {
+	case RecipientWorker, RecipientCreator:
+		r.ManagerLevel = 1
+	case RecipientManager:
+		if r.ManagerLevel == 0 {
+			r.ManagerLevel = 1
+		}
+		if r.ManagerLevel < 1 || r.ManagerLevel > maxManagerLevel {
+			return fmt.Errorf("%w: manager level must be between 1 and %d", ErrInvalidRule, maxManagerLevel)
+		}
+	default:
+		return fmt.Errorf("%w: recipient must be worker, creator or manager", ErrInvalidRule)
+	}
+	if r.DelaySeconds < 0 {
+		return fmt.Errorf("%w: delay must not be negative", ErrInvalidRule)
+	}
+	// Отложенное правило проверяет, что задача осталась в статусе, поэтому статус обязателен
+	if r.DelaySeconds > 0 && r.Status == "" {
+		return fmt.Errorf("%w: delayed rule needs a status", ErrInvalidRule)
+	}
+	// Сводку менеджеру собирает планировщик, правило её не заполнит. Эскалацию непрочитанных
+	// уведомлений тоже ведёт планировщик: правило дублировало бы её.
+	if r.NotificationType == ManagerDigestType || r.NotificationType == EscalatedType || !templates.Has(r.NotificationType) {
+		return fmt.Errorf("%w: unknown notification type %q", ErrInvalidRule, r.NotificationType)
+	}
+	return nil
+}
+
+// RuleTimer - отложенное срабатывание правила для задачи
+type RuleTimer struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	RuleID    uuid.UUID `gorm:"type:uuid;not null"`
+	TaskID    string    `gorm:"type:text;not null"`
+	UserID    string    `gorm:"type:text;not null"`
+	CreatedBy string    `gorm:"type:text;not null;default:''"`
+	Status    string    `gorm:"type:text;not null"`
+	Reason    string    `gorm:"type:text;not null;default:''"`
+	FireAt    time.Time `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null;default:now()"`
+}
+
+func (RuleTimer) TableName() string {
+	return "rule_timers"
+}
+
+// event восстанавливает событие, на которое сработало правило
+func (t RuleTimer) event() TaskEvent {
+	return TaskEvent{
+		Type:      EventTaskStatusChanged,
+		TaskID:    t.TaskID,
+		UserID:    t.UserID,
+		CreatedBy: t.CreatedBy,
+		Status:    t.Status,
+		Reason:    t.Reason,
+		Timestamp: t.CreatedAt,
+	}
+}
+
+type RuleRepository interface {
+	List(ctx context.Context) ([]Rule, error)
+	ListEnabled(ctx context.Context) ([]Rule, error)
+	Get(ctx context.Context, id uuid.UUID) (Rule, error)
+	Create(ctx context.Context, rule *Rule) error
+	Update(ctx context.Context, rule *Rule) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// ScheduleTimer создаёт отложенное срабатывание; повтор для того же правила, задачи и статуса игнорируется
+	ScheduleTimer(ctx context.Context, timer *RuleTimer) error
+	// CancelTimers удаляет срабатывания задачи, ждущие другого статуса
+	CancelTimers(ctx context.Context, taskID, status string) error
+	// ClaimDueTimers удаляет и возвращает до limit срабатываний, время которых пришло
+	ClaimDueTimers(ctx context.Context, now time.Time, limit int) ([]RuleTimer, error)
+}
+
+type ruleRepository struct {
+	db *gorm.DB
+}
+
+func NewRuleRepository(db *gorm.DB) RuleRepository {
+	return &ruleRepository{db: db}
+}
+
+func (r *ruleRepository) List(ctx context.Context) ([]Rule, error) {
+	var rules []Rule
+	err := r.db.WithContext(ctx).Order("created_at, id").Find(&rules).Error
+	return rules, err
+}
+
+func (r *ruleRepository) ListEnabled(ctx context.Context) ([]Rule, error) {
+	var rules []Rule
+	err := r.db.WithContext(ctx).Where("enabled").Order("created_at, id").Find(&rules).Error
+	return rules, err
+}
+
+func (r *ruleRepository) Get(ctx context.Context, id uuid.UUID) (Rule, error) {
+	var rule Rule
+	err := r.db.WithContext(ctx).Where("id = ?", id).Take(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Rule{}, ErrRuleNotFound
+	}
+	return rule, err
+}
+
+func (r *ruleRepository) Create(ctx context.Context, rule *Rule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+func (r *ruleRepository) Update(ctx context.Context, rule *Rule) error {
+	result := r.db.WithContext(ctx).Model(&Rule{}).
+		Where("id = ?", rule.ID).
+		Updates(map[string]interface{}{
+			"name":              rule.Name,
+			"event_type":        rule.EventType,
+			"status":            rule.Status,
+			"recipient":         rule.Recipient,
+			"manager_level":     rule.ManagerLevel,
+			"delay_seconds":     rule.DelaySeconds,
+			"notification_type": rule.NotificationType,
+			"enabled":           rule.Enabled,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+// Delete удаляет правило; его срабатывания удаляются каскадно
+func (r *ruleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&Rule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func (r *ruleRepository) ScheduleTimer(ctx context.Context, timer *RuleTimer) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(timer).Error
+}
+
+func (r *ruleRepository) CancelTimers(ctx context.Context, taskID, status string) error {
+	return r.db.WithContext(ctx).
+		Where("task_id = ? AND status <> ?", taskID, status).
+		Delete(&RuleTimer{}).Error
+}
+
+func (r *ruleRepository) ClaimDueTimers(ctx context.Context, now time.Time, limit int) ([]RuleTimer, error) {
+	var timers []RuleTimer
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("fire_at <= ?", now).
+			Order("fire_at").
+			Limit(limit).
+			Find(&timers).Error
+		if err != nil || len(timers) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, 0, len(timers))
+		for _, timer := range timers {
+			ids = append(ids, timer.ID)
+		}
+		return tx.Where("id IN ?", ids).Delete(&RuleTimer{}).Error
+	})
+	return timers, err
+}
+
+// Rules - управление правилами маршрутизации, только для admin
+type Rules interface {
+	List(ctx context.Context, requester Requester) ([]Rule, error)
+	Create(ctx context.Context, rule Rule, requester Requester) (Rule, error)
+	Update(ctx context.Context, rule Rule, requester Requester) (Rule, error)
+	Delete(ctx context.Context, id string, requester Requester) error
+}
+
+type rules struct {
+	repo      RuleRepository
+	templates *Templates
+}
+
+func NewRules(repo RuleRepository, templates *Templates) Rules {
+	return &rules{repo: repo, templates: templates}
+}
+
+func (s *rules) List(ctx context.Context, requester Requester) ([]Rule, error) {
+	if requester.Role != RoleAdmin {
+		return nil, ErrForbidden
+	}
+	return s.repo.List(ctx)
+}
+
+func (s *rules) Create(ctx context.Context, rule Rule, requester Requester) (Rule, error) {
+	if requester.Role != RoleAdmin {
+		return Rule{}, ErrForbidden
+	}
+	createdBy, err := uuid.Parse(requester.UserID)
+	if err != nil {
+		return Rule{}, ErrInvalidRecipient
+	}
+	if err := rule.normalize(s.templates); err != nil {
+		return Rule{}, err
+	}
+	rule.ID = uuid.New()
+	rule.CreatedBy = &createdBy
+	rule.CreatedAt = time.Now()
+	if err := s.repo.Create(ctx, &rule); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+func (s *rules) Update(ctx context.Context, rule Rule, requester Requester) (Rule, error) {
+	if requester.Role != RoleAdmin {
+		return Rule{}, ErrForbidden
+	}
+	existing, err := s.repo.Get(ctx, rule.ID)
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := rule.normalize(s.templates); err != nil {
+		return Rule{}, err
+	}
+	if err := s.repo.Update(ctx, &rule); err != nil {
+		return Rule{}, err
+	}
+	rule.CreatedBy = existing.CreatedBy
+	rule.CreatedAt = existing.CreatedAt
+	return rule, nil
+}
+
+func (s *rules) Delete(ctx context.Context, id string, requester Requester) error {
+	if requester.Role != RoleAdmin {
+		return ErrForbidden
+	}
+	ruleID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrRuleNotFound
+	}
+	return s.repo.Delete(ctx, ruleID)
+}
@@ -0,0 +1,123 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleFields(t *testing.T) {
+	tests := []struct {
+		spec   string
+		minute []int
+		hour   []int
+		dow    []int
+	}{
+		{spec: "0 9 * * *", minute: []int{0}, hour: []int{9}},
+		{spec: "15,45 8-10 * * *", minute: []int{15, 45}, hour: []int{8, 9, 10}},
+		{spec: "*/20 */6 * * *", minute: []int{0, 20, 40}, hour: []int{0, 6, 12, 18}},
+		{spec: "5-30/10 22/1 * * *", minute: []int{5, 15, 25}, hour: []int{22, 23}},
+		{spec: "0 0 * * 1-5", minute: []int{0}, hour: []int{0}, dow: []int{1, 2, 3, 4, 5}},
+		{spec: "0 0 * * 7", minute: []int{0}, hour: []int{0}, dow: []int{0, 7}},
+		{spec: " @Hourly ", minute: []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bitsOf(tt.minute); s.minute != got {
+				t.Fatalf("minute = %b, want %b", s.minute, got)
+			}
+			if tt.hour != nil && s.hour != bitsOf(tt.hour) {
+				t.Fatalf("hour = %b, want %b", s.hour, bitsOf(tt.hour))
+			}
+			if tt.dow != nil && s.dow != bitsOf(tt.dow) {
+				t.Fatalf("day of week = %b, want %b", s.dow, bitsOf(tt.dow))
+			}
+		})
+	}
+}
+
+func TestParseScheduleRejectsInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"0 9 * *",
+		"0 9 * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"10-5 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"@yearly",
+	} {
+		if _, err := ParseSchedule(spec); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("ParseSchedule(%q) = %v, want ErrInvalidSchedule", spec, err)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, moscow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		name string
+		spec string
+		from string
+		want string
+	}{
+		{"later today", "0 9 * * *", "2026-03-02 08:30", "2026-03-02 09:00"},
+		{"strictly after", "0 9 * * *", "2026-03-02 09:00", "2026-03-03 09:00"},
+		{"seconds are dropped", "* * * * *", "2026-03-02 09:00", "2026-03-02 09:01"},
+		{"step within the hour", "*/15 * * * *", "2026-03-02 09:16", "2026-03-02 09:30"},
+		{"next hour", "*/15 * * * *", "2026-03-02 09:50", "2026-03-02 10:00"},
+		{"end of year", "0 0 1 1 *", "2026-12-31 23:59", "2027-01-01 00:00"},
+		// 2026-03-07 - суббота
+		{"weekdays skip the weekend", "30 8 * * 1-5", "2026-03-06 09:00", "2026-03-09 08:30"},
+		{"sunday as 7", "0 12 * * 7", "2026-03-02 00:00", "2026-03-08 12:00"},
+		// День месяца или день недели: 10-е число или ближайший понедельник
+		{"day of month or week", "0 0 10 * 1", "2026-03-03 00:00", "2026-03-09 00:00"},
+		{"day of month and any week day", "0 0 31 * *", "2026-04-01 00:00", "2026-05-31 00:00"},
+		{"leap day", "0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := s.Next(at(tt.from)), at(tt.want); !got.Equal(want) || got.Location() != moscow {
+				t.Fatalf("Next(%s) = %s, want %s", tt.from, got, want)
+			}
+		})
+	}
+
+	never, err := ParseSchedule("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := never.Next(at("2026-03-02 00:00")); !got.IsZero() {
+		t.Fatalf("Next of 31 February = %s, want zero time", got)
+	}
+}
+
+func bitsOf(values []int) uint64 {
+	var bits uint64
+	for _, value := range values {
+		bits |= 1 << value
+	}
+	return bits
+}
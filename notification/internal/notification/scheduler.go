@@ -0,0 +1,282 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	digestRunName             = "manager_digest"
+	defaultDigestOverdueAfter = 72 * time.Hour
+	// defaultDigestWindow - за какой период первая сводка показывает завершённые задачи
+	defaultDigestWindow      = 24 * time.Hour
+	defaultSchedulerInterval = 30 * time.Second
+)
+
+// SchedulerConfig - зависимости планировщика сводок и эскалаций
+type SchedulerConfig struct {
+	// DigestSchedule - когда отправлять сводки менеджерам; nil - сводки выключены
+	DigestSchedule *Schedule
+	// Location - часовой пояс расписания; nil - UTC
+	Location *time.Location
+	// OverdueAfter - через сколько после создания незавершённая задача считается просроченной
+	OverdueAfter time.Duration
+	Escalation   EscalationConfig
+	Digests      DigestRepository
+	Alerts       AlertRepository
+	Resolver     ManagerResolver
+	Templates    *Templates
+	// Notifier доставляет сводки и эскалации по настройкам получателя (RoutingHandler)
+	Notifier     Notifier
+	PollInterval time.Duration
+	Logger       *log.Logger
+}
+
+// Scheduler отправляет сводки менеджерам по расписанию и поднимает непрочитанные
+// уведомления по цепочке менеджеров
+type Scheduler interface {
+	Run(ctx context.Context) error
+}
+
+type scheduler struct {
+	config SchedulerConfig
+	logger *log.Logger
+}
+
+func NewScheduler(config SchedulerConfig) Scheduler {
+	if config.Location == nil {
+		config.Location = time.UTC
+	}
+	if config.OverdueAfter <= 0 {
+		config.OverdueAfter = defaultDigestOverdueAfter
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultSchedulerInterval
+	}
+	config.Escalation = config.Escalation.withDefaults()
+	logger := config.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &scheduler{config: config, logger: logger}
+}
+
+func (s *scheduler) Run(ctx context.Context) error {
+	// at - время сводки, due - когда её отправить: после ошибки сводка за at повторяется
+	at := s.nextDigest()
+	due := at
+	switch {
+	case s.config.DigestSchedule == nil:
+	case at.IsZero():
+		s.logger.Printf("manager digest schedule never fires")
+	default:
+		s.logger.Printf("manager digests scheduled, next at %s", at.Format(time.RFC3339))
+	}
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		if len(s.config.Escalation.Types) > 0 {
+			if err := s.escalateDue(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Printf("notification escalation: %v", err)
+			}
+		}
+		if !at.IsZero() && !time.Now().Before(due) {
+			err := s.sendDigests(ctx, at)
+			if err != nil && ctx.Err() == nil {
+				s.logger.Printf("manager digests: %v", err)
+			}
+			// Если до следующей сводки далеко, эта повторяется; иначе следующая
+			// включит задачи, завершённые после последней отправленной сводки
+			next := s.nextDigest()
+			if retry := time.Now().Add(routingRetryDelay); err != nil && retry.Before(next) {
+				due = retry
+			} else {
+				at, due = next, next
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// nextDigest возвращает время следующей сводки; нулевое - сводок нет
+func (s *scheduler) nextDigest() time.Time {
+	if s.config.DigestSchedule == nil {
+		return time.Time{}
+	}
+	return s.config.DigestSchedule.Next(time.Now().In(s.config.Location))
+}
+
+// sendDigests отправляет каждому менеджеру сводку по задачам его подчинённых: задачи в NEEDS_HELP,
+// просроченные задачи и задачи, завершённые после предыдущей сводки. Сводку за время at
+// отправляет один экземпляр сервиса. Если хоть одна сводка не отправлена, отметка запуска
+// снимается и завершённые задачи остаются: повтор отправит сводку заново, а получившие её
+// менеджеры второй не получат - ID сводки выводится из менеджера и at.
+func (s *scheduler) sendDigests(ctx context.Context, at time.Time) error {
+	previous, claimed, err := s.config.Digests.ClaimRun(ctx, digestRunName, at)
+	if err != nil {
+		return fmt.Errorf("claim digest run: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+	since := previous
+	if since.IsZero() {
+		since = at.Add(-defaultDigestWindow)
+	}
+
+	if err := s.deliverDigests(ctx, at, since); err != nil {
+		if releaseErr := s.config.Digests.ReleaseRun(context.WithoutCancel(ctx), digestRunName, at, previous); releaseErr != nil {
+			err = errors.Join(err, fmt.Errorf("release digest run: %w", releaseErr))
+		}
+		return err
+	}
+
+	// Завершённые до начала этой сводки задачи в следующие сводки не попадут
+	if err := s.config.Digests.PurgeCompleted(ctx, since); err != nil {
+		return fmt.Errorf("purge completed tasks: %w", err)
+	}
+	return nil
+}
+
+func (s *scheduler) deliverDigests(ctx context.Context, at, since time.Time) error {
+	snapshots, err := s.config.Digests.ListForDigest(ctx, at.Add(-s.config.OverdueAfter), since)
+	if err != nil {
+		return fmt.Errorf("list tasks for digest: %w", err)
+	}
+
+	var errs []error
+	var order []string
+	digests := make(map[string]*ManagerDigest)
+	for _, snapshot := range snapshots {
+		managerID, err := s.config.Resolver.ResolveManager(ctx, snapshot.UserID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resolve manager of %s: %w", snapshot.UserID, err))
+			continue
+		}
+		if managerID == "" || managerID == snapshot.UserID {
+			continue
+		}
+		digest, ok := digests[managerID]
+		if !ok {
+			digest = &ManagerDigest{Since: since, Until: at}
+			digests[managerID] = digest
+			order = append(order, managerID)
+		}
+		switch snapshot.Status {
+		case TaskStatusNeedsHelp:
+			digest.NeedsHelp = append(digest.NeedsHelp, snapshot)
+		case TaskStatusCompleted:
+			digest.Completed = append(digest.Completed, snapshot)
+		default:
+			digest.Overdue = append(digest.Overdue, snapshot)
+		}
+	}
+
+	sent := 0
+	for _, managerID := range order {
+		notification := Notification{
+			ID:          derivedID("digest", managerID, at.UTC().Format(time.RFC3339)).String(),
+			Type:        ManagerDigestType,
+			RecipientID: managerID,
+			CreatedAt:   at,
+			Digest:      digests[managerID],
+		}
+		if notification.Digest.empty() {
+			continue
+		}
+		if notification.Message, err = s.config.Templates.Message(notification); err != nil {
+			errs = append(errs, fmt.Errorf("render digest to %s: %w", managerID, err))
+			continue
+		}
+		if err := s.config.Notifier.SendNotification(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("send digest to %s: %w", managerID, err))
+			continue
+		}
+		sent++
+	}
+	s.logger.Printf("manager digests sent: %d", sent)
+	return errors.Join(errs...)
+}
+
+// escalateDue поднимает на уровень выше уведомления, которые не прочитали вовремя.
+// Уведомление, которое не удалось поднять, повторяется через routingRetryDelay.
+func (s *scheduler) escalateDue(ctx context.Context) error {
+	for {
+		now := time.Now()
+		alerts, claimed, err := s.config.Alerts.ClaimDue(ctx, now, routingBatchSize)
+		if err != nil {
+			return fmt.Errorf("claim alerts: %w", err)
+		}
+		for _, alert := range alerts {
+			if err := s.escalate(ctx, alert, now); err != nil {
+				s.logger.Printf("escalate notification %s for task %s: %v", alert.ID, alert.TaskID, err)
+				alert.EscalateAt = now.Add(routingRetryDelay)
+				if err := s.config.Alerts.Create(ctx, &alert); err != nil {
+					s.logger.Printf("reschedule escalation of %s: %v", alert.ID, err)
+				}
+			}
+		}
+		if claimed < routingBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *scheduler) escalate(ctx context.Context, alert Alert, now time.Time) error {
+	if alert.Escalation >= s.config.Escalation.MaxLevels {
+		s.logger.Printf("notification %s for task %s reached escalation limit %d", alert.ID, alert.TaskID, s.config.Escalation.MaxLevels)
+		return nil
+	}
+
+	unresolvedSince := alert.CreatedAt
+	snapshot, err := s.config.Digests.Get(ctx, alert.TaskID)
+	switch {
+	case err == nil:
+		// Задача вышла из статуса уведомления - поднимать нечего
+		if alert.Status != "" && !strings.EqualFold(snapshot.Status, alert.Status) {
+			return nil
+		}
+		unresolvedSince = snapshot.StatusChangedAt
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("get task snapshot: %w", err)
+	}
+
+	recipientID := alert.RecipientID.String()
+	managerID, err := s.config.Resolver.ResolveManager(ctx, recipientID)
+	if err != nil {
+		return fmt.Errorf("resolve manager: %w", err)
+	}
+	if managerID == "" || managerID == recipientID {
+		s.logger.Printf("no manager above %s for task %s, stop escalation", recipientID, alert.TaskID)
+		return nil
+	}
+
+	// Повтор после ошибки отправки не поднимет уведомление на тот же уровень второй раз
+	notification := Notification{
+		ID:              derivedID("escalation", alert.ID.String(), strconv.Itoa(alert.Escalation+1)).String(),
+		Type:            EscalatedType,
+		TaskID:          alert.TaskID,
+		UserID:          alert.UserID,
+		Status:          alert.Status,
+		Reason:          alert.Reason,
+		RecipientID:     managerID,
+		CreatedAt:       now,
+		UnresolvedHours: int(now.Sub(unresolvedSince).Hours()),
+		Escalation:      alert.Escalation + 1,
+	}
+	if notification.Message, err = s.config.Templates.Message(notification); err != nil {
+		return fmt.Errorf("render escalation: %w", err)
+	}
+	return s.config.Notifier.SendNotification(ctx, notification)
+}
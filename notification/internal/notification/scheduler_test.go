@@ -0,0 +1,417 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryAlerts - AlertRepository в памяти; прочитанные уведомления перечислены в read
+type memoryAlerts struct {
+	mu     sync.Mutex
+	alerts map[uuid.UUID]Alert
+	read   map[uuid.UUID]bool
+}
+
+func newMemoryAlerts() *memoryAlerts {
+	return &memoryAlerts{alerts: make(map[uuid.UUID]Alert), read: make(map[uuid.UUID]bool)}
+}
+
+func (r *memoryAlerts) Create(_ context.Context, alert *Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts[alert.ID] = *alert
+	return nil
+}
+
+func (r *memoryAlerts) ClaimDue(_ context.Context, now time.Time, limit int) ([]Alert, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []Alert
+	for _, alert := range r.alerts {
+		if !alert.EscalateAt.After(now) && len(due) < limit {
+			due = append(due, alert)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].EscalateAt.Before(due[j].EscalateAt) })
+	var unread []Alert
+	for _, alert := range due {
+		delete(r.alerts, alert.ID)
+		if !r.read[alert.ID] {
+			unread = append(unread, alert)
+		}
+	}
+	return unread, len(due), nil
+}
+
+// expire переносит время эскалации всех уведомлений в прошлое
+func (r *memoryAlerts) expire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, alert := range r.alerts {
+		alert.EscalateAt = time.Now().Add(-time.Second)
+		r.alerts[id] = alert
+	}
+}
+
+func (r *memoryAlerts) pending() []Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var alerts []Alert
+	for _, alert := range r.alerts {
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// memoryDigests - DigestRepository в памяти
+type memoryDigests struct {
+	mu        sync.Mutex
+	snapshots map[string]TaskSnapshot
+	runs      map[string]time.Time
+}
+
+func newMemoryDigests(snapshots ...TaskSnapshot) *memoryDigests {
+	r := &memoryDigests{snapshots: make(map[string]TaskSnapshot), runs: make(map[string]time.Time)}
+	for _, snapshot := range snapshots {
+		r.snapshots[snapshot.TaskID] = snapshot
+	}
+	return r
+}
+
+func (r *memoryDigests) Apply(_ context.Context, snapshot *TaskSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshots[snapshot.TaskID] = *snapshot
+	return nil
+}
+
+func (r *memoryDigests) Get(_ context.Context, taskID string) (TaskSnapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot, ok := r.snapshots[taskID]
+	if !ok {
+		return TaskSnapshot{}, gorm.ErrRecordNotFound
+	}
+	return snapshot, nil
+}
+
+func (r *memoryDigests) ListForDigest(_ context.Context, overdueBefore, completedSince time.Time) ([]TaskSnapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var snapshots []TaskSnapshot
+	for _, snapshot := range r.snapshots {
+		switch {
+		case snapshot.Status == TaskStatusNeedsHelp,
+			snapshot.Status != TaskStatusCompleted && !snapshot.CreatedAt.After(overdueBefore),
+			snapshot.Status == TaskStatusCompleted && snapshot.CompletedAt.After(completedSince):
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].TaskID < snapshots[j].TaskID })
+	return snapshots, nil
+}
+
+func (r *memoryDigests) PurgeCompleted(_ context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, snapshot := range r.snapshots {
+		if snapshot.Status == TaskStatusCompleted && snapshot.CompletedAt.Before(before) {
+			delete(r.snapshots, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryDigests) ClaimRun(_ context.Context, name string, at time.Time) (time.Time, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, ok := r.runs[name]
+	if ok && !previous.Before(at) {
+		return time.Time{}, false, nil
+	}
+	r.runs[name] = at
+	return previous, true, nil
+}
+
+func (r *memoryDigests) ReleaseRun(_ context.Context, name string, at, previous time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if run, ok := r.runs[name]; !ok || !run.Equal(at) {
+		return nil
+	}
+	if previous.IsZero() {
+		delete(r.runs, name)
+	} else {
+		r.runs[name] = previous
+	}
+	return nil
+}
+
+// managerChain - менеджер каждого сотрудника; у отсутствующего в карте менеджера нет
+type managerChain map[string]string
+
+func (c managerChain) ResolveManager(_ context.Context, userID string) (string, error) {
+	return c[userID], nil
+}
+
+// recipientNotifier запоминает уведомления. Получателям из fail возвращает ошибку,
+// из partial - запоминает уведомление и возвращает ошибку, как при сбое одного из каналов.
+type recipientNotifier struct {
+	mu      sync.Mutex
+	sent    []Notification
+	fail    map[string]bool
+	partial map[string]bool
+}
+
+func (n *recipientNotifier) SendNotification(_ context.Context, notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.fail[notification.RecipientID] {
+		return errors.New("recipient unavailable")
+	}
+	n.sent = append(n.sent, notification)
+	if n.partial[notification.RecipientID] {
+		return errors.New("email unavailable")
+	}
+	return nil
+}
+
+func (n *recipientNotifier) sentTo(recipientID string) []Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var sent []Notification
+	for _, notification := range n.sent {
+		if notification.RecipientID == recipientID {
+			sent = append(sent, notification)
+		}
+	}
+	return sent
+}
+
+type escalationFixture struct {
+	worker, lead, head, director string
+	alerts                       *memoryAlerts
+	digests                      *memoryDigests
+	notifier                     *recipientNotifier
+	scheduler                    *scheduler
+}
+
+// newEscalationFixture - цепочка worker -> lead -> head -> director, эскалация не выше maxLevels
+func newEscalationFixture(t *testing.T, maxLevels int, snapshots ...TaskSnapshot) *escalationFixture {
+	t.Helper()
+	f := &escalationFixture{
+		worker:   uuid.NewString(),
+		lead:     uuid.NewString(),
+		head:     uuid.NewString(),
+		director: uuid.NewString(),
+		alerts:   newMemoryAlerts(),
+		digests:  newMemoryDigests(snapshots...),
+		notifier: &recipientNotifier{fail: make(map[string]bool), partial: make(map[string]bool)},
+	}
+	escalation := EscalationConfig{
+		Types:     []string{"task_needs_help", EscalatedType},
+		Timeout:   time.Hour,
+		MaxLevels: maxLevels,
+	}
+	f.scheduler = NewScheduler(SchedulerConfig{
+		Escalation: escalation,
+		Digests:    f.digests,
+		Alerts:     f.alerts,
+		Resolver:   managerChain{f.worker: f.lead, f.lead: f.head, f.head: f.director},
+		Templates:  mustTemplates(t),
+		// Поднятое уведомление тоже ставится на эскалацию, как в ящике
+		Notifier: NewAlertTracker(f.notifier, f.alerts, escalation, nil),
+	}).(*scheduler)
+	return f
+}
+
+// notifyLead доставляет прямому менеджеру уведомление о задаче taskID в статусе NEEDS_HELP
+func (f *escalationFixture) notifyLead(t *testing.T, taskID string) {
+	t.Helper()
+	err := f.scheduler.config.Notifier.SendNotification(context.Background(), Notification{
+		ID:          uuid.NewString(),
+		Type:        "task_needs_help",
+		TaskID:      taskID,
+		UserID:      f.worker,
+		Status:      TaskStatusNeedsHelp,
+		RecipientID: f.lead,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *escalationFixture) escalate(t *testing.T) {
+	t.Helper()
+	f.alerts.expire()
+	if err := f.scheduler.escalateDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEscalateDueLevels(t *testing.T) {
+	taskID := uuid.NewString()
+	f := newEscalationFixture(t, 2, TaskSnapshot{
+		TaskID:          taskID,
+		UserID:          uuid.NewString(),
+		Status:          TaskStatusNeedsHelp,
+		StatusChangedAt: time.Now().Add(-5 * time.Hour),
+	})
+	f.notifyLead(t, taskID)
+
+	steps := []struct {
+		recipient  string
+		escalation int
+	}{
+		{f.head, 1},
+		{f.director, 2},
+	}
+	for _, step := range steps {
+		f.escalate(t)
+		sent := f.notifier.sentTo(step.recipient)
+		if len(sent) != 1 {
+			t.Fatalf("level %d: recipient got %d notifications, want 1", step.escalation, len(sent))
+		}
+		got := sent[0]
+		if got.Type != EscalatedType || got.Escalation != step.escalation || got.TaskID != taskID || got.UnresolvedHours != 5 {
+			t.Fatalf("level %d: unexpected escalation %+v", step.escalation, got)
+		}
+	}
+
+	// Выше MaxLevels уведомление не поднимается
+	f.escalate(t)
+	if len(f.notifier.sent) != 3 {
+		t.Fatalf("sent %d notifications, want 3", len(f.notifier.sent))
+	}
+	if pending := f.alerts.pending(); len(pending) != 0 {
+		t.Fatalf("%d alerts left after the escalation limit", len(pending))
+	}
+}
+
+func TestEscalateDueStops(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		read   bool
+		top    bool
+	}{
+		{name: "task left the status", status: TaskStatusCompleted},
+		{name: "notification was read", status: TaskStatusNeedsHelp, read: true},
+		{name: "no manager above", status: TaskStatusNeedsHelp, top: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskID := uuid.NewString()
+			f := newEscalationFixture(t, 3, TaskSnapshot{TaskID: taskID, Status: tt.status, StatusChangedAt: time.Now()})
+			if tt.top {
+				f.lead = f.director
+			}
+			f.notifyLead(t, taskID)
+			if tt.read {
+				for _, alert := range f.alerts.pending() {
+					f.alerts.read[alert.ID] = true
+				}
+			}
+
+			f.escalate(t)
+			if len(f.notifier.sent) != 1 {
+				t.Fatalf("sent %d notifications, want only the original one", len(f.notifier.sent))
+			}
+			if pending := f.alerts.pending(); len(pending) != 0 {
+				t.Fatalf("%d alerts left", len(pending))
+			}
+		})
+	}
+}
+
+// Неудачная эскалация повторяется с тем же ID: дошедшее уведомление не дублируется
+func TestEscalateDueRetriesFailure(t *testing.T) {
+	taskID := uuid.NewString()
+	f := newEscalationFixture(t, 3, TaskSnapshot{TaskID: taskID, Status: TaskStatusNeedsHelp, StatusChangedAt: time.Now()})
+	f.notifyLead(t, taskID)
+
+	f.notifier.partial[f.head] = true
+	f.escalate(t)
+	pending := f.alerts.pending()
+	if len(pending) != 1 || pending[0].RecipientID.String() != f.lead || !pending[0].EscalateAt.After(time.Now()) {
+		t.Fatalf("after failure alerts = %+v, want the original one rescheduled", pending)
+	}
+
+	delete(f.notifier.partial, f.head)
+	f.escalate(t)
+	sent := f.notifier.sentTo(f.head)
+	if len(sent) != 2 || sent[0].ID != sent[1].ID || sent[1].Escalation != 1 {
+		t.Fatalf("head got %+v, want the same level 1 escalation twice", sent)
+	}
+	if pending := f.alerts.pending(); len(pending) != 1 || pending[0].RecipientID.String() != f.head {
+		t.Fatalf("after retry alerts = %+v, want the escalation tracked", pending)
+	}
+}
+
+func TestSendDigestsRetriesFailedManagers(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	lead, head := uuid.NewString(), uuid.NewString()
+	leadWorker, headWorker := uuid.NewString(), uuid.NewString()
+	completedAt := at.Add(-time.Hour)
+	digests := newMemoryDigests(
+		TaskSnapshot{TaskID: "needs-help", UserID: leadWorker, Status: TaskStatusNeedsHelp, CreatedAt: at, StatusChangedAt: at},
+		TaskSnapshot{TaskID: "completed", UserID: headWorker, Status: TaskStatusCompleted, CreatedAt: at, StatusChangedAt: completedAt, CompletedAt: &completedAt},
+	)
+	inbox := newMemoryInbox()
+	email := &failingNotifier{}
+	router := NewEventHandler(RoutingConfig{
+		Rules:       &staticRules{},
+		Preferences: defaultPreferences{},
+		Deferred:    noDeferred{},
+		Templates:   mustTemplates(t),
+		Channels:    map[string]Notifier{ChannelInbox: inbox, ChannelEmail: email},
+		Deliveries:  newMemoryDedupStore(),
+	})
+	notifier := &recipientNotifier{fail: map[string]bool{head: true}}
+	s := NewScheduler(SchedulerConfig{
+		Digests:   digests,
+		Resolver:  managerChain{leadWorker: lead, headWorker: head},
+		Templates: mustTemplates(t),
+		Notifier:  NewMultiNotifier(notifier, router),
+	}).(*scheduler)
+
+	if err := s.sendDigests(ctx, at); err == nil {
+		t.Fatal("want error for the failed manager")
+	}
+	if _, ok := digests.runs[digestRunName]; ok {
+		t.Fatal("digest run stays claimed after a failure")
+	}
+	if _, err := digests.Get(ctx, "completed"); err != nil {
+		t.Fatal("completed task of an undelivered digest purged")
+	}
+
+	// Повтор доставляет сводку второму менеджеру; первый её снова не получает
+	delete(notifier.fail, head)
+	if err := s.sendDigests(ctx, at); err != nil {
+		t.Fatal(err)
+	}
+	if got := notifier.sentTo(head); len(got) != 1 || len(got[0].Digest.Completed) != 1 {
+		t.Fatalf("head got %+v, want one digest with the completed task", got)
+	}
+	if len(inbox.items) != 2 || len(email.attempts) != 2 {
+		t.Fatalf("inbox has %d digests, email sent %d; want one per manager", len(inbox.items), len(email.attempts))
+	}
+	if run := digests.runs[digestRunName]; !run.Equal(at) {
+		t.Fatalf("digest run = %s, want %s", run, at)
+	}
+	if err := s.sendDigests(ctx, at); err != nil {
+		t.Fatal(err)
+	}
+	if len(inbox.items) != 2 {
+		t.Fatalf("digest sent again after the run completed")
+	}
+}
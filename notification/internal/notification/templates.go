@@ -0,0 +1,168 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// fallbackLocale - язык, на котором есть шаблоны для всех типов уведомлений
+const fallbackLocale = "ru"
+
+// DigestType - тип письма со сводкой отложенных уведомлений (Notification.Items)
+const DigestType = "digest"
+
+//go:embed templates
+var templateFS embed.FS
+
+// ErrNoTemplate - для типа уведомления нет шаблона ни на одном языке
+var ErrNoTemplate = errors.New("notification template not found")
+
+// Templates рендерит уведомления на выбранном языке. Шаблоны лежат в templates/<язык>/<тип>.txt
+// (text/template: subject, message, text) и <тип>.html (html/template: html, необязателен).
+// Если для языка нет шаблона типа, используется fallbackLocale.
+type Templates struct {
+	locale string
+	text   map[string]*texttemplate.Template
+	html   map[string]*htmltemplate.Template
+}
+
+// TemplateData - данные шаблона: поля уведомления и имя получателя
+type TemplateData struct {
+	Notification
+	RecipientName string
+}
+
+// Rendered - отрисованное письмо
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// NewTemplates разбирает встроенные шаблоны. locale - язык вида "ru" или "en-US".
+func NewTemplates(locale string) (*Templates, error) {
+	t := &Templates{
+		locale: normalizeLocale(locale),
+		text:   make(map[string]*texttemplate.Template),
+		html:   make(map[string]*htmltemplate.Template),
+	}
+	if t.locale == "" {
+		t.locale = fallbackLocale
+	}
+
+	err := fs.WalkDir(templateFS, "templates", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		key := strings.TrimSuffix(strings.TrimPrefix(name, "templates/"), path.Ext(name))
+		switch path.Ext(name) {
+		case ".txt":
+			tmpl, err := texttemplate.ParseFS(templateFS, name)
+			if err != nil {
+				return err
+			}
+			t.text[key] = tmpl
+		case ".html":
+			tmpl, err := htmltemplate.ParseFS(templateFS, name)
+			if err != nil {
+				return err
+			}
+			t.html[key] = tmpl
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parse notification templates: %w", err)
+	}
+
+	if _, err := fs.Stat(templateFS, path.Join("templates", t.locale)); err != nil {
+		return nil, fmt.Errorf("no notification templates for locale %q", t.locale)
+	}
+	return t, nil
+}
+
+func normalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+func (t *Templates) textTemplate(notificationType string) (*texttemplate.Template, error) {
+	for _, locale := range []string{t.locale, fallbackLocale} {
+		if tmpl, ok := t.text[locale+"/"+notificationType]; ok {
+			return tmpl, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoTemplate, notificationType)
+}
+
+// Has возвращает true, если для типа уведомления есть шаблон. Сводка - служебный тип.
+func (t *Templates) Has(notificationType string) bool {
+	if notificationType == DigestType {
+		return false
+	}
+	_, err := t.textTemplate(notificationType)
+	return err == nil
+}
+
+func (t *Templates) htmlTemplate(notificationType string) *htmltemplate.Template {
+	for _, locale := range []string{t.locale, fallbackLocale} {
+		if tmpl, ok := t.html[locale+"/"+notificationType]; ok {
+			return tmpl
+		}
+	}
+	return nil
+}
+
+// Message возвращает короткий текст уведомления для ящика и логов
+func (t *Templates) Message(notification Notification) (string, error) {
+	tmpl, err := t.textTemplate(notification.Type)
+	if err != nil {
+		return "", err
+	}
+	return executeText(tmpl, "message", TemplateData{Notification: notification})
+}
+
+// Render отрисовывает тему, текстовую и HTML версии письма
+func (t *Templates) Render(data TemplateData) (Rendered, error) {
+	tmpl, err := t.textTemplate(data.Type)
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	var rendered Rendered
+	if rendered.Subject, err = executeText(tmpl, "subject", data); err != nil {
+		return Rendered{}, err
+	}
+	// Тема попадает в заголовок письма и не должна содержать переводов строк
+	rendered.Subject = strings.Join(strings.Fields(rendered.Subject), " ")
+	if rendered.Text, err = executeText(tmpl, "text", data); err != nil {
+		return Rendered{}, err
+	}
+
+	if html := t.htmlTemplate(data.Type); html != nil {
+		var buf bytes.Buffer
+		if err := html.ExecuteTemplate(&buf, "html", data); err != nil {
+			return Rendered{}, err
+		}
+		rendered.HTML = buf.String()
+	}
+	return rendered, nil
+}
+
+func executeText(tmpl *texttemplate.Template, name string, data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
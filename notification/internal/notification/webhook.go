@@ -0,0 +1,433 @@
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Статусы доставки webhook
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookAllEvents - подписка на все типы событий
+const WebhookAllEvents = "*"
+
+const (
+	defaultDeliveryPageSize = 50
+	maxDeliveryPageSize     = 200
+	webhookSecretBytes      = 32
+)
+
+// webhookEventTypes - типы событий, на которые можно подписаться
+var webhookEventTypes = map[string]bool{
+	EventTaskCreated:          true,
+	EventTaskStatusChanged:    true,
+	EventDocumentCreated:      true,
+	EventDocumentVersionAdded: true,
+	EventDocumentDeleted:      true,
+	EventDocumentRestored:     true,
+	EventNotificationCreated:  true,
+	WebhookAllEvents:          true,
+}
+
+var (
+	// ErrForbidden - операция доступна только admin
+	ErrForbidden = errors.New("forbidden")
+	// ErrWebhookNotFound - подписка не найдена
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrInvalidWebhookURL - адрес подписки не является абсолютным http(s) URL
+	ErrInvalidWebhookURL = errors.New("webhook url must be an absolute http or https url")
+	// ErrInvalidEventTypes - не указаны типы событий или среди них есть неизвестный
+	ErrInvalidEventTypes = errors.New("webhook event types are invalid")
+	// ErrInvalidDeliveryStatus - неизвестный статус доставки в фильтре
+	ErrInvalidDeliveryStatus = errors.New("unknown delivery status")
+)
+
+// Webhook - подписка на события. Secret - ключ подписи тела запроса.
+type Webhook struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	URL         string    `gorm:"type:text;not null"`
+	Secret      string    `gorm:"type:text;not null"`
+	EventTypes  []string  `gorm:"type:jsonb;serializer:json;not null"`
+	Description string    `gorm:"type:text;not null;default:''"`
+	CreatedBy   uuid.UUID `gorm:"type:uuid;not null"`
+	CreatedAt   time.Time `gorm:"not null;default:now()"`
+}
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Subscribed возвращает true, если подписка получает события типа eventType
+func (w Webhook) Subscribed(eventType string) bool {
+	for _, subscribed := range w.EventTypes {
+		if subscribed == WebhookAllEvents || subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery - запись журнала доставок: одно событие для одной подписки.
+// Payload хранится целиком, поэтому повторная отправка шлёт то же тело.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	WebhookID      uuid.UUID  `gorm:"type:uuid;not null"`
+	Webhook        *Webhook   `gorm:"foreignKey:WebhookID"`
+	EventID        uuid.UUID  `gorm:"type:uuid;not null"`
+	EventType      string     `gorm:"type:text;not null"`
+	Payload        string     `gorm:"type:text;not null"`
+	Status         string     `gorm:"type:text;not null;default:'pending'"`
+	Attempts       int        `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time  `gorm:"not null;default:now()"`
+	LastAttemptAt  *time.Time `gorm:"type:timestamptz"`
+	ResponseStatus int        `gorm:"not null;default:0"`
+	LastError      string     `gorm:"type:text;not null;default:''"`
+	CreatedAt      time.Time  `gorm:"not null;default:now()"`
+	DeliveredAt    *time.Time `gorm:"type:timestamptz"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// DeliveryQuery - параметры выборки журнала. BeforeID - последняя доставка предыдущей страницы.
+type DeliveryQuery struct {
+	Status   string
+	Limit    int
+	BeforeID *uuid.UUID
+}
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	WebhookExists(ctx context.Context, id uuid.UUID) (bool, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	CreateDeliveries(ctx context.Context, deliveries []WebhookDelivery) error
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, query DeliveryQuery) ([]WebhookDelivery, error)
+	// ClaimDeliveries выбирает до limit доставок, время которых пришло, и откладывает их
+	// на lease, чтобы другой экземпляр сервиса не отправил их одновременно
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+	SaveAttempt(ctx context.Context, delivery *WebhookDelivery) error
+	// ReplayDeliveries возвращает неудавшиеся доставки в очередь; пустой ids - все неудавшиеся доставки подписки
+	ReplayDeliveries(ctx context.Context, webhookID uuid.UUID, ids []uuid.UUID, at time.Time) (int64, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+func (r *webhookRepository) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := r.db.WithContext(ctx).Order("created_at, id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *webhookRepository) WebhookExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Webhook{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteWebhook удаляет подписку; журнал доставок удаляется каскадно
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&Webhook{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *webhookRepository) CreateDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(&deliveries).Error
+}
+
+// ListDeliveries возвращает журнал подписки от новых записей к старым, страницы продолжаются по (created_at, id)
+func (r *webhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, query DeliveryQuery) ([]WebhookDelivery, error) {
+	db := r.db.WithContext(ctx).Where("webhook_id = ?", webhookID)
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.BeforeID != nil {
+		var cursor WebhookDelivery
+		err := r.db.WithContext(ctx).
+			Select("id", "created_at").
+			Where("id = ? AND webhook_id = ?", *query.BeforeID, webhookID).
+			Take(&cursor).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCursorNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	var deliveries []WebhookDelivery
+	err := db.Order("created_at DESC, id DESC").Limit(query.Limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *webhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		err := tx.Model(&WebhookDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		err = tx.Model(&WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Preload("Webhook").Where("id IN ?", ids).Order("next_attempt_at").Find(&deliveries).Error
+	})
+	return deliveries, err
+}
+
+func (r *webhookRepository) SaveAttempt(ctx context.Context, delivery *WebhookDelivery) error {
+	return r.db.WithContext(ctx).Model(&WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"last_attempt_at": delivery.LastAttemptAt,
+			"response_status": delivery.ResponseStatus,
+			"last_error":      delivery.LastError,
+			"delivered_at":    delivery.DeliveredAt,
+		}).Error
+}
+
+func (r *webhookRepository) ReplayDeliveries(ctx context.Context, webhookID uuid.UUID, ids []uuid.UUID, at time.Time) (int64, error) {
+	db := r.db.WithContext(ctx).Model(&WebhookDelivery{}).
+		Where("webhook_id = ? AND status = ?", webhookID, DeliveryFailed)
+	if len(ids) > 0 {
+		db = db.Where("id IN ?", ids)
+	}
+	// Счётчик попыток сбрасывается: повтор проходит полный цикл ретраев
+	result := db.Updates(map[string]interface{}{
+		"status":          DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": at,
+	})
+	return result.RowsAffected, result.Error
+}
+
+// CreateWebhookInput - параметры новой подписки. Пустой Secret - сгенерировать.
+type CreateWebhookInput struct {
+	URL         string
+	EventTypes  []string
+	Secret      string
+	Description string
+}
+
+// Webhooks - управление подписками и журналом доставок. Все операции доступны только admin.
+type Webhooks interface {
+	Create(ctx context.Context, input CreateWebhookInput, requester Requester) (Webhook, error)
+	List(ctx context.Context, requester Requester) ([]Webhook, error)
+	Delete(ctx context.Context, id string, requester Requester) error
+	Deliveries(ctx context.Context, webhookID, status string, limit int, beforeID string, requester Requester) ([]WebhookDelivery, string, error)
+	Replay(ctx context.Context, webhookID string, deliveryIDs []string, requester Requester) (int64, error)
+}
+
+type webhooks struct {
+	repo WebhookRepository
+}
+
+func NewWebhooks(repo WebhookRepository) Webhooks {
+	return &webhooks{repo: repo}
+}
+
+func (w *webhooks) Create(ctx context.Context, input CreateWebhookInput, requester Requester) (Webhook, error) {
+	if requester.Role != RoleAdmin {
+		return Webhook{}, ErrForbidden
+	}
+	createdBy, err := uuid.Parse(requester.UserID)
+	if err != nil {
+		return Webhook{}, ErrInvalidRecipient
+	}
+
+	target, err := url.Parse(strings.TrimSpace(input.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return Webhook{}, ErrInvalidWebhookURL
+	}
+	eventTypes, err := normalizeEventTypes(input.EventTypes)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		random := make([]byte, webhookSecretBytes)
+		if _, err := rand.Read(random); err != nil {
+			return Webhook{}, err
+		}
+		secret = hex.EncodeToString(random)
+	}
+
+	webhook := Webhook{
+		ID:          uuid.New(),
+		URL:         target.String(),
+		Secret:      secret,
+		EventTypes:  eventTypes,
+		Description: strings.TrimSpace(input.Description),
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now(),
+	}
+	if err := w.repo.CreateWebhook(ctx, &webhook); err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// normalizeEventTypes проверяет типы событий и убирает повторы
+func normalizeEventTypes(eventTypes []string) ([]string, error) {
+	seen := make(map[string]bool, len(eventTypes))
+	result := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !webhookEventTypes[eventType] {
+			return nil, ErrInvalidEventTypes
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			result = append(result, eventType)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrInvalidEventTypes
+	}
+	return result, nil
+}
+
+func (w *webhooks) List(ctx context.Context, requester Requester) ([]Webhook, error) {
+	if requester.Role != RoleAdmin {
+		return nil, ErrForbidden
+	}
+	return w.repo.ListWebhooks(ctx)
+}
+
+func (w *webhooks) Delete(ctx context.Context, id string, requester Requester) error {
+	if requester.Role != RoleAdmin {
+		return ErrForbidden
+	}
+	webhookID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrWebhookNotFound
+	}
+	return w.repo.DeleteWebhook(ctx, webhookID)
+}
+
+// Deliveries возвращает страницу журнала и before_id следующей страницы (пусто, если страниц больше нет)
+func (w *webhooks) Deliveries(ctx context.Context, webhookID, status string, limit int, beforeID string, requester Requester) ([]WebhookDelivery, string, error) {
+	if requester.Role != RoleAdmin {
+		return nil, "", ErrForbidden
+	}
+	id, err := w.existingWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, "", err
+	}
+	switch status {
+	case "", DeliveryPending, DeliverySucceeded, DeliveryFailed:
+	default:
+		return nil, "", ErrInvalidDeliveryStatus
+	}
+
+	if limit <= 0 {
+		limit = defaultDeliveryPageSize
+	}
+	if limit > maxDeliveryPageSize {
+		limit = maxDeliveryPageSize
+	}
+	query := DeliveryQuery{Status: status, Limit: limit + 1}
+	if beforeID != "" {
+		cursor, err := uuid.Parse(beforeID)
+		if err != nil {
+			return nil, "", ErrCursorNotFound
+		}
+		query.BeforeID = &cursor
+	}
+
+	deliveries, err := w.repo.ListDeliveries(ctx, id, query)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(deliveries) <= limit {
+		return deliveries, "", nil
+	}
+	deliveries = deliveries[:limit]
+	return deliveries, deliveries[limit-1].ID.String(), nil
+}
+
+func (w *webhooks) Replay(ctx context.Context, webhookID string, deliveryIDs []string, requester Requester) (int64, error) {
+	if requester.Role != RoleAdmin {
+		return 0, ErrForbidden
+	}
+	id, err := w.existingWebhook(ctx, webhookID)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(deliveryIDs))
+	for _, deliveryID := range deliveryIDs {
+		value, err := uuid.Parse(deliveryID)
+		if err != nil {
+			// Несуществующие доставки просто не попадают в очередь
+			continue
+		}
+		ids = append(ids, value)
+	}
+	if len(deliveryIDs) > 0 && len(ids) == 0 {
+		return 0, nil
+	}
+	return w.repo.ReplayDeliveries(ctx, id, ids, time.Now())
+}
+
+func (w *webhooks) existingWebhook(ctx context.Context, webhookID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(webhookID)
+	if err != nil {
+		return uuid.Nil, ErrWebhookNotFound
+	}
+	exists, err := w.repo.WebhookExists(ctx, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !exists {
+		return uuid.Nil, ErrWebhookNotFound
+	}
+	return id, nil
+}
@@ -0,0 +1,303 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Заголовки запроса webhook
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const maxWebhookErrorBody = 512
+
+// WebhookConfig - параметры доставки webhook
+type WebhookConfig struct {
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	Timeout      time.Duration
+	PollInterval time.Duration
+	Concurrency  int
+}
+
+// webhookEnvelope - тело запроса webhook
+type webhookEnvelope struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookEventHandler ставит события задач и документов в очередь доставки подписчикам
+type WebhookEventHandler interface {
+	EventHandler
+	DocumentEventHandler
+}
+
+type webhookEventHandler struct {
+	repo WebhookRepository
+}
+
+func NewWebhookEventHandler(repo WebhookRepository) WebhookEventHandler {
+	return &webhookEventHandler{repo: repo}
+}
+
+func (h *webhookEventHandler) HandleEvent(ctx context.Context, event TaskEvent) error {
+	return h.enqueue(ctx, event.EventID, event.EventType(), event.Timestamp, event)
+}
+
+func (h *webhookEventHandler) HandleDocumentEvent(ctx context.Context, event DocumentEvent) error {
+	return h.enqueue(ctx, "", event.Type, event.Timestamp, event)
+}
+
+// NewWebhookNotifier возвращает notifier канала webhook: уведомление ставится в очередь
+// подписчикам события notification.created
+func NewWebhookNotifier(repo WebhookRepository) Notifier {
+	return &webhookEventHandler{repo: repo}
+}
+
+func (h *webhookEventHandler) SendNotification(ctx context.Context, notification Notification) error {
+	return h.enqueue(ctx, notification.ID, EventNotificationCreated, notification.CreatedAt, newNotificationEvent(notification))
+}
+
+// enqueue создаёт доставку для каждой подписки на eventType. Отправкой занимается WebhookDispatcher.
+// id - ID события у источника; если его нет, генерируется новый.
+func (h *webhookEventHandler) enqueue(ctx context.Context, id, eventType string, occurredAt time.Time, data interface{}) error {
+	webhooks, err := h.repo.ListWebhooks(ctx)
+	if err != nil {
+		return fmt.Errorf("list webhooks: %w", err)
+	}
+
+	var subscribed []Webhook
+	for _, webhook := range webhooks {
+		if webhook.Subscribed(eventType) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	eventID, err := uuid.Parse(id)
+	if err != nil {
+		eventID = uuid.New()
+	}
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+	payload, err := json.Marshal(webhookEnvelope{
+		ID:         eventID.String(),
+		Type:       eventType,
+		OccurredAt: occurredAt.UTC(),
+		Data:       data,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]WebhookDelivery, 0, len(subscribed))
+	for _, webhook := range subscribed {
+		deliveries = append(deliveries, WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     webhook.ID,
+			EventID:       eventID,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	if err := h.repo.CreateDeliveries(ctx, deliveries); err != nil {
+		return fmt.Errorf("enqueue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// WebhookDispatcher отправляет доставки из очереди
+type WebhookDispatcher interface {
+	// Run опрашивает очередь до отмены контекста и дожидается начатых отправок
+	Run(ctx context.Context) error
+}
+
+type webhookDispatcher struct {
+	repo   WebhookRepository
+	config WebhookConfig
+	client *http.Client
+	logger *log.Logger
+}
+
+func NewWebhookDispatcher(repo WebhookRepository, config WebhookConfig, logger *log.Logger) WebhookDispatcher {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	return &webhookDispatcher{
+		repo:   repo,
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			// Перенаправление считается неудачной попыткой: подпись привязана к адресу подписки
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger,
+	}
+}
+
+func (d *webhookDispatcher) Run(ctx context.Context) error {
+	d.logger.Printf("webhook dispatcher started (concurrency=%d, max attempts=%d)", d.config.Concurrency, d.config.MaxAttempts)
+	for {
+		claimed, err := d.dispatchBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Printf("claim webhook deliveries: %v", err)
+		}
+		// Полная пачка - в очереди, скорее всего, есть ещё доставки
+		if err == nil && claimed == d.config.Concurrency {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(d.config.PollInterval):
+		}
+	}
+}
+
+func (d *webhookDispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	// Пока доставка отправляется, другие экземпляры её не выбирают
+	lease := d.config.Timeout + time.Minute
+	deliveries, err := d.repo.ClaimDeliveries(ctx, time.Now(), lease, d.config.Concurrency)
+	if err != nil {
+		return 0, err
+	}
+
+	// Начатые отправки завершаются и сохраняются даже при остановке сервиса
+	attemptCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *WebhookDelivery) {
+			defer wg.Done()
+			d.attempt(attemptCtx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+func (d *webhookDispatcher) attempt(ctx context.Context, delivery *WebhookDelivery) {
+	if delivery.Webhook == nil {
+		return
+	}
+
+	now := time.Now()
+	statusCode, err := d.send(ctx, delivery, now)
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = statusCode
+
+	switch {
+	case err == nil:
+		delivery.Status = DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.config.MaxAttempts:
+		delivery.Status = DeliveryFailed
+		delivery.LastError = err.Error()
+		d.logger.Printf("webhook delivery %s to %s failed after %d attempts: %v", delivery.ID, delivery.Webhook.URL, delivery.Attempts, err)
+	default:
+		delivery.Status = DeliveryPending
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+
+	if err := d.repo.SaveAttempt(ctx, delivery); err != nil {
+		d.logger.Printf("save webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// send отправляет payload и возвращает HTTP статус ответа (0 - ответа не было)
+func (d *webhookDispatcher) send(ctx context.Context, delivery *WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "task-system-webhooks")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookErrorBody))
+	return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+}
+
+// backoff возвращает паузу перед следующей попыткой: BackoffBase * 2^(attempts-1),
+// но не больше BackoffMax, плюс до 20% случайной задержки, чтобы повторы не шли пачкой
+func (d *webhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.BackoffBase
+	for i := 1; i < attempts && delay < d.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > d.config.BackoffMax {
+		delay = d.config.BackoffMax
+	}
+	if jitter := int64(delay / 5); jitter > 0 {
+		delay += time.Duration(rand.Int64N(jitter))
+	}
+	return delay
+}
+
+// SignWebhookPayload возвращает значение X-Webhook-Signature:
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
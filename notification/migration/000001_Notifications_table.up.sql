@@ -0,0 +1,17 @@
+-- Create notifications table (ящик уведомлений пользователя)
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id               uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    recipient_id     uuid        NOT NULL,
+    type             text        NOT NULL,
+    task_id          text        NOT NULL DEFAULT '',
+    user_id          text        NOT NULL DEFAULT '',
+    message          text        NOT NULL,
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    read_at          timestamptz NULL          -- NULL - уведомление не прочитано
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_notifications_recipient_created ON notifications (recipient_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_recipient_unread ON notifications (recipient_id) WHERE read_at IS NULL;
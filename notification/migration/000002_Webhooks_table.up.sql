@@ -0,0 +1,30 @@
+-- Create webhook subscriptions and delivery log
+CREATE TABLE IF NOT EXISTS webhooks (
+    id               uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    url              text        NOT NULL,
+    secret           text        NOT NULL,          -- Ключ подписи HMAC-SHA256
+    event_types      jsonb       NOT NULL,          -- ["task.created", ...] или ["*"]
+    description      text        NOT NULL DEFAULT '',
+    created_by       uuid        NOT NULL,
+    created_at       timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    webhook_id       uuid        NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id         uuid        NOT NULL,
+    event_type       text        NOT NULL,
+    payload          text        NOT NULL,          -- Тело запроса, отправляется без изменений при каждой попытке
+    status           text        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts         integer     NOT NULL DEFAULT 0,
+    next_attempt_at  timestamptz NOT NULL DEFAULT now(),
+    last_attempt_at  timestamptz NULL,
+    response_status  integer     NOT NULL DEFAULT 0, -- 0 - ответа не было
+    last_error       text        NOT NULL DEFAULT '',
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    delivered_at     timestamptz NULL
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created ON webhook_deliveries (webhook_id, created_at DESC, id DESC);
@@ -0,0 +1,5 @@
+-- Drop notification preferences, routing rules and delayed deliveries
+DROP TABLE IF EXISTS deferred_notifications;
+DROP TABLE IF EXISTS rule_timers;
+DROP TABLE IF EXISTS notification_rules;
+DROP TABLE IF EXISTS notification_preferences;
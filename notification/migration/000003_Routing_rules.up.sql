@@ -0,0 +1,64 @@
+-- Create notification preferences, routing rules and delayed deliveries
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id            uuid PRIMARY KEY,
+    notification_types jsonb       NOT NULL DEFAULT '[]', -- [] - все типы
+    channels           jsonb       NOT NULL DEFAULT '["inbox", "email"]',
+    quiet_hours_start  text        NOT NULL DEFAULT '',   -- "HH:MM", '' - без тихих часов
+    quiet_hours_end    text        NOT NULL DEFAULT '',
+    timezone           text        NOT NULL DEFAULT '',   -- IANA, '' - UTC
+    digest             text        NOT NULL DEFAULT 'none' CHECK (digest IN ('none', 'hourly', 'daily')),
+    updated_at         timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS notification_rules (
+    id                 uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name               text        NOT NULL,
+    event_type         text        NOT NULL,
+    status             text        NOT NULL DEFAULT '',   -- '' - любой статус
+    recipient          text        NOT NULL CHECK (recipient IN ('worker', 'creator', 'manager')),
+    manager_level      integer     NOT NULL DEFAULT 1 CHECK (manager_level >= 1),
+    delay_seconds      bigint      NOT NULL DEFAULT 0 CHECK (delay_seconds >= 0),
+    notification_type  text        NOT NULL,
+    enabled            boolean     NOT NULL DEFAULT true,
+    created_by         uuid        NULL,                  -- NULL - правило создано миграцией
+    created_at         timestamptz NOT NULL DEFAULT now()
+);
+
+-- Отложенные срабатывания правил с delay_seconds > 0; удаляются, когда задача выходит из статуса правила
+CREATE TABLE IF NOT EXISTS rule_timers (
+    id                 uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    rule_id            uuid        NOT NULL REFERENCES notification_rules (id) ON DELETE CASCADE,
+    task_id            text        NOT NULL,
+    user_id            text        NOT NULL,
+    created_by         text        NOT NULL DEFAULT '',
+    status             text        NOT NULL,
+    reason             text        NOT NULL DEFAULT '',
+    fire_at            timestamptz NOT NULL,
+    created_at         timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (rule_id, task_id, status)
+);
+
+-- Уведомления, отложенные из-за тихих часов или сводки
+CREATE TABLE IF NOT EXISTS deferred_notifications (
+    id                 uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    recipient_id       uuid        NOT NULL,
+    channel            text        NOT NULL CHECK (channel IN ('email', 'webhook')),
+    notification_id    uuid        NOT NULL,
+    type               text        NOT NULL,
+    task_id            text        NOT NULL DEFAULT '',
+    user_id            text        NOT NULL DEFAULT '',
+    reason             text        NOT NULL DEFAULT '',
+    message            text        NOT NULL,
+    created_at         timestamptz NOT NULL,
+    deliver_after      timestamptz NOT NULL
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_rule_timers_fire_at ON rule_timers (fire_at);
+CREATE INDEX IF NOT EXISTS idx_rule_timers_task ON rule_timers (task_id);
+CREATE INDEX IF NOT EXISTS idx_deferred_notifications_deliver_after ON deferred_notifications (deliver_after);
+
+-- Правило по умолчанию: прежнее поведение (NEEDS_HELP - прямому менеджеру)
+INSERT INTO notification_rules (name, event_type, status, recipient, manager_level, delay_seconds, notification_type, enabled)
+VALUES
+    ('NEEDS_HELP - менеджеру', 'task.status_changed', 'NEEDS_HELP', 'manager', 1, 0, 'task_needs_help', true);
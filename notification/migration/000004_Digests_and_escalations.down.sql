@@ -0,0 +1,4 @@
+-- Drop task snapshots and tracked alerts
+DROP TABLE IF EXISTS notification_alerts;
+DROP TABLE IF EXISTS scheduler_runs;
+DROP TABLE IF EXISTS task_snapshots;
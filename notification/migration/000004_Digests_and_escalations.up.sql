@@ -0,0 +1,36 @@
+-- Create task snapshots for manager digests and tracked alerts for escalation
+-- Последнее известное состояние задачи по событиям task-events
+CREATE TABLE IF NOT EXISTS task_snapshots (
+    task_id            text PRIMARY KEY,
+    user_id            text        NOT NULL,
+    created_by         text        NOT NULL DEFAULT '',
+    status             text        NOT NULL,
+    reason             text        NOT NULL DEFAULT '',
+    created_at         timestamptz NOT NULL,
+    status_changed_at  timestamptz NOT NULL,
+    completed_at       timestamptz NULL          -- NULL - задача не завершена
+);
+
+-- Последний запуск задания по расписанию (один запуск на все экземпляры сервиса)
+CREATE TABLE IF NOT EXISTS scheduler_runs (
+    name               text PRIMARY KEY,
+    last_run_at        timestamptz NOT NULL
+);
+
+-- Уведомления в ящике, которые поднимаются к менеджеру выше, если их не прочитали к escalate_at
+CREATE TABLE IF NOT EXISTS notification_alerts (
+    id                 uuid PRIMARY KEY,         -- ID уведомления в notifications
+    recipient_id       uuid        NOT NULL,
+    type               text        NOT NULL,
+    task_id            text        NOT NULL DEFAULT '',
+    user_id            text        NOT NULL DEFAULT '',
+    status             text        NOT NULL DEFAULT '',
+    reason             text        NOT NULL DEFAULT '',
+    escalation         integer     NOT NULL DEFAULT 0,
+    created_at         timestamptz NOT NULL,
+    escalate_at        timestamptz NOT NULL
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_task_snapshots_status ON task_snapshots (status);
+CREATE INDEX IF NOT EXISTS idx_notification_alerts_escalate_at ON notification_alerts (escalate_at);
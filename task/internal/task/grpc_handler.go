@@ -2,11 +2,13 @@ package task
 
 import (
 	"context"
+	"errors"
 
 	pb "github.com/Oniqq60/task_system_control/gen/proto/task"
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
 )
 
 type GrpcHandler struct {
@@ -129,22 +131,44 @@ func (h *GrpcHandler) TaskList(ctx context.Context, req *pb.TaskListRequest) (*p
 
 	pbTasks := make([]*pb.Task, 0, len(tasks))
 	for _, task := range tasks {
-		pbTask := &pb.Task{
-			Id:        task.ID.String(),
-			Message:   task.Message,
-			Status:    string(task.Status),
-			WorkerId:  task.WorkerID.String(),
-			CreatedBy: task.CreatedBy.String(),
-			CreatedAt: task.CreatedAt.Unix(),
-			UpdatedAt: task.UpdatedAt.Unix(),
-		}
-		if task.Reason != nil {
-			pbTask.Reason = *task.Reason
-		}
-		pbTasks = append(pbTasks, pbTask)
+		pbTasks = append(pbTasks, toPbTask(task))
 	}
 
 	return &pb.TaskListResponse{
 		Tasks: pbTasks,
 	}, nil
 }
+
+// GetTask возвращает задачу по идентификатору
+func (h *GrpcHandler) GetTask(ctx context.Context, req *pb.GetTaskRequest) (*pb.Task, error) {
+	id, err := uuid.Parse(req.Id)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+
+	task, err := h.service.GetTask(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, "task not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return toPbTask(task), nil
+}
+
+func toPbTask(task Task) *pb.Task {
+	pbTask := &pb.Task{
+		Id:        task.ID.String(),
+		Message:   task.Message,
+		Status:    string(task.Status),
+		WorkerId:  task.WorkerID.String(),
+		CreatedBy: task.CreatedBy.String(),
+		CreatedAt: task.CreatedAt.Unix(),
+		UpdatedAt: task.UpdatedAt.Unix(),
+	}
+	if task.Reason != nil {
+		pbTask.Reason = *task.Reason
+	}
+	return pbTask
+}
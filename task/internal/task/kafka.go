@@ -8,13 +8,24 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
-// TaskEvent представляет событие изменения задачи для Kafka
+// Типы событий задач
+const (
+	EventTaskCreated       = "task.created"
+	EventTaskStatusChanged = "task.status_changed"
+)
+
+// TaskEvent представляет событие изменения задачи для Kafka.
+// EventID одинаков при повторной доставке события, по нему получатели отбрасывают дубликаты.
 type TaskEvent struct {
-	TaskID    string    `json:"taskId"`
-	UserID    string    `json:"userId"`
-	Status    string    `json:"status"`
-	Reason    string    `json:"reason,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
+	EventID        string    `json:"eventId"`
+	Type           string    `json:"type"`
+	TaskID         string    `json:"taskId"`
+	UserID         string    `json:"userId"`
+	CreatedBy      string    `json:"createdBy,omitempty"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previousStatus,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
 }
 
 type KafkaProducer interface {
@@ -29,9 +40,10 @@ type kafkaProducer struct {
 
 func NewKafkaProducer(brokers []string, topic string) KafkaProducer {
 	writer := &kafka.Writer{
-		Addr:     kafka.TCP(brokers...),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Addr:  kafka.TCP(brokers...),
+		Topic: topic,
+		// Ключ - ID задачи: события одной задачи попадают в одну партицию и читаются по порядку
+		Balancer: &kafka.Hash{},
 	}
 
 	return &kafkaProducer{
@@ -3,6 +3,7 @@ package task
 import (
 	"context"
 	"errors"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +13,7 @@ type TaskService interface {
 	CreateTask(ctx context.Context, message string, workerID, createdBy uuid.UUID) (Task, error)
 	UpdateTask(ctx context.Context, id uuid.UUID, message, status, reason *string) (Task, error)
 	TaskList(ctx context.Context, workerID, createdBy *uuid.UUID, status *string) ([]Task, error)
+	GetTask(ctx context.Context, id uuid.UUID) (Task, error)
 }
 
 type taskService struct {
@@ -45,6 +47,15 @@ func (s *taskService) CreateTask(ctx context.Context, message string, workerID,
 		return Task{}, err
 	}
 
+	s.publish(TaskEvent{
+		Type:      EventTaskCreated,
+		TaskID:    task.ID.String(),
+		UserID:    task.WorkerID.String(),
+		CreatedBy: task.CreatedBy.String(),
+		Status:    string(task.Status),
+		Timestamp: task.CreatedAt,
+	})
+
 	return task, nil
 }
 
@@ -99,22 +110,20 @@ func (s *taskService) UpdateTask(ctx context.Context, id uuid.UUID, message, sta
 		return Task{}, err
 	}
 
-	if status != nil && Status(*status) == StatusNeedsHelp && existingTask.Status != StatusNeedsHelp {
-		if s.kafkaProducer != nil {
-			event := TaskEvent{
-				TaskID:    task.ID.String(),
-				UserID:    task.WorkerID.String(),
-				Status:    string(StatusNeedsHelp),
-				Reason:    *reason,
-				Timestamp: time.Now(),
-			}
-
-			go func() {
-				if err := s.kafkaProducer.SendTaskEvent(context.Background(), event); err != nil {
-
-				}
-			}()
+	if task.Status != existingTask.Status {
+		event := TaskEvent{
+			Type:           EventTaskStatusChanged,
+			TaskID:         task.ID.String(),
+			UserID:         task.WorkerID.String(),
+			CreatedBy:      task.CreatedBy.String(),
+			Status:         string(task.Status),
+			PreviousStatus: string(existingTask.Status),
+			Timestamp:      time.Now(),
+		}
+		if task.Reason != nil {
+			event.Reason = *task.Reason
 		}
+		s.publish(event)
 	}
 
 	return task, nil
@@ -131,3 +140,22 @@ func (s *taskService) TaskList(ctx context.Context, workerID, createdBy *uuid.UU
 
 	return s.repo.TaskList(ctx, workerID, createdBy, status)
 }
+
+func (s *taskService) GetTask(ctx context.Context, id uuid.UUID) (Task, error) {
+	return s.repo.GetTask(ctx, id)
+}
+
+// publish отправляет событие в Kafka в фоне: ошибка доставки события не отменяет изменение задачи
+func (s *taskService) publish(event TaskEvent) {
+	if s.kafkaProducer == nil {
+		return
+	}
+	event.EventID = uuid.NewString()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.kafkaProducer.SendTaskEvent(ctx, event); err != nil {
+			log.Printf("send task event %s for task %s: %v", event.Type, event.TaskID, err)
+		}
+	}()
+}